@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"flights/pkg/types"
+	"strconv"
+)
+
+// itinerary output of the itinerary command
+type itinerary struct {
+	Start    string   `json:"start"`
+	End      string   `json:"end"`
+	Flights  int      `json:"flights"`
+	Airports []string `json:"airports"`
+}
+
+// count output of the count command
+type count struct {
+	Flights   int   `json:"flights"`
+	Airports  int   `json:"airports"`
+	SubRoutes int64 `json:"subRoutes"`
+}
+
+// validation output of the validate command
+type validation struct {
+	Valid bool               `json:"valid"`
+	Error *types.ErrorDetail `json:"error,omitempty"`
+}
+
+// sorted validates and sorts the flights
+func sorted(flights types.Flights) (types.Flights, error) {
+	if err := flights.Validate(); err != nil {
+		return nil, err
+	}
+
+	flights.Sort()
+	return flights, nil
+}
+
+// airports returns the airports of the sorted chain, in order. A closed loop ends at the first airport
+func airports(flights types.Flights) []string {
+	list := make([]string, 0, len(flights)+1)
+	for _, flight := range flights {
+		list = append(list, flight[0])
+	}
+	return append(list, flights[len(flights)-1][1])
+}
+
+// commandSort sorts the flights into a single chain
+func commandSort(flights types.Flights) (output result, err error) {
+	if flights, err = sorted(flights); err != nil {
+		return
+	}
+
+	output = result{Value: flights, Header: []string{"src", "dst"}, Rows: flights}
+	return
+}
+
+// commandSubRoutes lists all sub routes, one row per flight of each sub route
+func commandSubRoutes(flights types.Flights) (output result, err error) {
+	if err = flights.Validate(); err != nil {
+		return
+	}
+
+	routes := make([][][]string, 0, types.CountSubRoutes(len(flights)))
+	rows := make([][]string, 0)
+	flights.WalkSubRoutes(func(route [][]string) bool {
+		routes = append(routes, route)
+		for k, flight := range route {
+			rows = append(rows, []string{strconv.Itoa(len(routes)), strconv.Itoa(k + 1), flight[0], flight[1]})
+		}
+		return true
+	})
+
+	output = result{Value: routes, Header: []string{"route", "flight", "src", "dst"}, Rows: rows}
+	return
+}
+
+// commandItinerary shows the start, the end and the airports of the chain
+func commandItinerary(flights types.Flights) (output result, err error) {
+	if flights, err = sorted(flights); err != nil {
+		return
+	}
+
+	value := itinerary{
+		Start:    flights[0][0],
+		End:      flights[len(flights)-1][1],
+		Flights:  len(flights),
+		Airports: airports(flights),
+	}
+
+	rows := make([][]string, len(value.Airports))
+	for k, airport := range value.Airports {
+		rows[k] = []string{strconv.Itoa(k + 1), airport}
+	}
+
+	output = result{Value: value, Header: []string{"stop", "airport"}, Rows: rows}
+	return
+}
+
+// commandValidate checks if the flights form a single chain. The output is written for valid and invalid flights
+func commandValidate(flights types.Flights) (output result, err error) {
+	err = flights.Validate()
+	if err == nil {
+		output = result{Value: validation{Valid: true}, Header: []string{"valid", "code", "pointer", "message"}, Rows: [][]string{{"true", "", "", ""}}}
+		return
+	}
+
+	detail := types.ErrorDetail{Code: types.ErrorCodeInternal, Message: err.Error()}
+	var typed types.Error
+	if errors.As(err, &typed) {
+		detail = types.ErrorDetail{Code: typed.Code, Pointer: typed.Pointer, Message: typed.Message}
+	}
+
+	output = result{
+		Value:  validation{Valid: false, Error: &detail},
+		Header: []string{"valid", "code", "pointer", "message"},
+		Rows:   [][]string{{"false", detail.Code, detail.Pointer, detail.Message}},
+	}
+	return
+}
+
+// commandCount counts the flights, the airports and the sub routes
+func commandCount(flights types.Flights) (output result, err error) {
+	if flights, err = sorted(flights); err != nil {
+		return
+	}
+
+	unique := make(map[string]bool)
+	for _, airport := range airports(flights) {
+		unique[airport] = true
+	}
+
+	value := count{Flights: len(flights), Airports: len(unique), SubRoutes: types.CountSubRoutes(len(flights))}
+	output = result{
+		Value:  value,
+		Header: []string{"flights", "airports", "subRoutes"},
+		Rows:   [][]string{{strconv.Itoa(value.Flights), strconv.Itoa(value.Airports), strconv.FormatInt(value.SubRoutes, 10)}},
+	}
+	return
+}
+
+// commandExport draws the flights, sorted or not. The forks and the disconnected fragments are highlighted, so only
+// the lists with invalid flights are rejected
+func commandExport(flights types.Flights) (output result, err error) {
+	err = flights.Validate()
+
+	var typed types.Error
+	if errors.As(err, &typed) && (typed.Code == types.ErrorCodeFork || typed.Code == types.ErrorCodeDisconnectedChain) {
+		err = nil
+	}
+	if err != nil {
+		return
+	}
+
+	output = result{Value: flights, Header: []string{"src", "dst"}, Rows: flights, Flights: flights}
+	return
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flights/pkg/types"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	formatJSON   = "json"
+	formatNDJSON = "ndjson"
+	formatCSV    = "csv"
+	formatTable  = "table"
+
+	formatDOT     = "dot"
+	formatGeoJSON = "geojson"
+	formatSVG     = "svg"
+)
+
+// leg flight of the ndjson format, as an object
+type leg struct {
+	Src string `json:"src"`
+	Dst string `json:"dst"`
+}
+
+// detectInputFormat returns the format of the file by extension, or by the first character of the data
+func detectInputFormat(name string, data []byte) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		return formatJSON
+	case ".ndjson", ".jsonl":
+		return formatNDJSON
+	case ".csv":
+		return formatCSV
+	}
+
+	// a json list starts with "[[" and a ndjson line with "[" followed by a string, or with "{"
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		return formatNDJSON
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		next := bytes.TrimSpace(trimmed[1:])
+		if len(next) == 0 || next[0] == '[' || next[0] == ']' {
+			return formatJSON
+		}
+		return formatNDJSON
+	default:
+		return formatCSV
+	}
+}
+
+// readFlights decodes the flights in the format. The format is detected when empty
+func readFlights(name string, data []byte, format string) (flights types.Flights, err error) {
+	if format == "" {
+		format = detectInputFormat(name, data)
+	}
+
+	switch format {
+	case formatJSON:
+		flights = types.Flights{}
+		err = json.Unmarshal(data, &flights)
+	case formatNDJSON:
+		flights, err = readNDJSON(data)
+	case formatCSV:
+		flights, err = readCSV(data)
+	default:
+		err = fmt.Errorf("unknown input format: %v", format)
+	}
+
+	return
+}
+
+// readNDJSON decodes one flight per line, as ["SFO","EWR"] or {"src":"SFO","dst":"EWR"}
+func readNDJSON(data []byte) (flights types.Flights, err error) {
+	flights = types.Flights{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line += 1 {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		if text[0] == '{' {
+			var object leg
+			if err = json.Unmarshal(text, &object); err != nil {
+				return nil, fmt.Errorf("line %v: %v", line, err)
+			}
+			flights = append(flights, []string{object.Src, object.Dst})
+			continue
+		}
+
+		var flight []string
+		if err = json.Unmarshal(text, &flight); err != nil {
+			return nil, fmt.Errorf("line %v: %v", line, err)
+		}
+		flights = append(flights, flight)
+	}
+
+	err = scanner.Err()
+	return
+}
+
+// readCSV decodes one flight per record, src and dst. The header "src,dst" is optional
+func readCSV(data []byte) (flights types.Flights, err error) {
+	flights = types.Flights{}
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return flights, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if first && len(record) == 2 && strings.EqualFold(record[0], "src") && strings.EqualFold(record[1], "dst") {
+			continue
+		}
+
+		flights = append(flights, record)
+	}
+}
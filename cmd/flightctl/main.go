@@ -0,0 +1,227 @@
+// main.go
+package main
+
+import (
+	"errors"
+	"flag"
+	"flights/pkg/export"
+	"flights/pkg/types"
+	"fmt"
+	"io"
+	"os"
+)
+
+// exit codes
+const (
+	// exitOK the command succeeded and the itinerary is valid
+	exitOK = 0
+
+	// exitInvalid the input can't be decoded or the flights don't form a single chain
+	exitInvalid = 1
+
+	// exitUsage unknown command, flag or format
+	exitUsage = 2
+
+	// exitIO the input or output file can't be read or written
+	exitIO = 3
+)
+
+const usage = `flightctl computes the routes of a list of flights, without the server.
+
+Usage:
+  flightctl <command> [flags] [file]
+
+Commands:
+  sort        sorts the flights into a single chain
+  subroutes   lists all sub routes of the chain
+  itinerary   shows the start, the end and the airports of the chain
+  validate    checks if the flights form a single chain
+  count       counts the flights, the airports and the sub routes
+  export      draws the flights, sorted or not, highlighting forks and disconnected fragments
+
+The flights are read from the file, or from stdin when the file is "-" or missing, as json [["SFO","EWR"]],
+ndjson, one ["SFO","EWR"] or {"src":"SFO","dst":"EWR"} per line, or csv, one src,dst per line.
+
+The output is json, csv or table, default json. The output of export is dot, geojson or svg, default dot, and the
+coordinates of the airports missing from the embedded list are informed by -airports, a csv with
+code,latitude,longitude[,name] per line.
+
+Exit codes:
+  0  success
+  1  the input is malformed or the flights don't form a single chain
+  2  usage error
+  3  i/o error
+
+Flags:
+`
+
+// commands functions of the commands, by name
+var commands = map[string]func(flights types.Flights) (result, error){
+	"sort":      commandSort,
+	"subroutes": commandSubRoutes,
+	"itinerary": commandItinerary,
+	"validate":  commandValidate,
+	"count":     commandCount,
+	"export":    commandExport,
+}
+
+// exitError error with the exit code of the command
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e exitError) Error() string {
+	return e.err.Error()
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs the command and returns the exit code
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("flightctl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	inputFormat := flags.String("input-format", "", "format of the input: json, ndjson or csv. Default detected by the extension or the content")
+	format := flags.String("format", "", "format of the output: json, csv or table. dot, geojson or svg for export")
+	airportsFile := flags.String("airports", "", "csv file with the coordinates of extra airports, used by export")
+	output := flags.String("o", "-", "output file, \"-\" is stdout")
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+
+	if len(args) == 0 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	command, found := commands[args[0]]
+	if !found {
+		if args[0] == "-h" || args[0] == "-help" || args[0] == "help" {
+			flags.Usage()
+			return exitOK
+		}
+		fmt.Fprintf(stderr, "flightctl: unknown command %q\n", args[0])
+		flags.Usage()
+		return exitUsage
+	}
+
+	if err := flags.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	if flags.NArg() > 1 {
+		fmt.Fprintf(stderr, "flightctl: only one input file is accepted\n")
+		return exitUsage
+	}
+
+	formats := outputFormats
+	if args[0] == "export" {
+		formats = exportFormats
+	}
+	if *format == "" {
+		*format = formats[0]
+	}
+	if !contains(formats, *format) {
+		fmt.Fprintf(stderr, "flightctl %v: unknown output format %q\n", args[0], *format)
+		return exitUsage
+	}
+
+	if *inputFormat != "" && *inputFormat != formatJSON && *inputFormat != formatNDJSON && *inputFormat != formatCSV {
+		fmt.Fprintf(stderr, "flightctl: unknown input format %q\n", *inputFormat)
+		return exitUsage
+	}
+
+	var airports export.Airports
+	if args[0] == "export" {
+		airports = export.DefaultAirports()
+		if *airportsFile != "" {
+			var err error
+			if airports, err = export.LoadAirports(*airportsFile); err != nil {
+				fmt.Fprintf(stderr, "flightctl %v: %v\n", args[0], err)
+				return exitIO
+			}
+		}
+	}
+
+	err := execute(command, flags.Arg(0), *inputFormat, *format, *output, airports, stdin, stdout)
+	if err == nil {
+		return exitOK
+	}
+
+	fmt.Fprintf(stderr, "flightctl %v: %v\n", args[0], err)
+
+	var exit exitError
+	if errors.As(err, &exit) {
+		return exit.code
+	}
+	return exitIO
+}
+
+// execute reads the input, runs the command and writes the output
+func execute(command func(flights types.Flights) (result, error), input, inputFormat, format, output string, airports export.Airports, stdin io.Reader, stdout io.Writer) (err error) {
+	var data []byte
+	if input == "" || input == "-" {
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = os.ReadFile(input)
+	}
+	if err != nil {
+		return exitError{code: exitIO, err: err}
+	}
+
+	flights, err := readFlights(input, data, inputFormat)
+	if err != nil {
+		return exitError{code: exitInvalid, err: types.NewError(types.ErrorCodeMalformedPayload, "", err.Error())}
+	}
+
+	// the output of validate is written even for an invalid itinerary
+	value, err := command(flights)
+	if value.Header == nil {
+		return exitError{code: exitInvalid, err: err}
+	}
+	value.Airports = airports
+
+	if output == "" || output == "-" {
+		if writeErr := writeResult(stdout, format, value); writeErr != nil {
+			return exitError{code: exitIO, err: writeErr}
+		}
+	} else if writeErr := writeFile(output, format, value); writeErr != nil {
+		return exitError{code: exitIO, err: writeErr}
+	}
+
+	if err != nil {
+		return exitError{code: exitInvalid, err: err}
+	}
+	return nil
+}
+
+// contains returns true when the list contains the value
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// writeFile writes the result in the output file
+func writeFile(path, format string, value result) (err error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return
+	}
+
+	if err = writeResult(file, format, value); err != nil {
+		_ = file.Close()
+		return
+	}
+
+	return file.Close()
+}
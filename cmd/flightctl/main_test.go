@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	csvFile := filepath.Join(dir, "flights.csv")
+	if err := os.WriteFile(csvFile, []byte("src,dst\nIND,EWR\nSFO,ATL\nGSO,IND\nATL,GSO\n"), 0o600); err != nil {
+		t.Logf("os.WriteFile().error: %v", err)
+		t.FailNow()
+	}
+
+	airportsFile := filepath.Join(dir, "airports.csv")
+	if err := os.WriteFile(airportsFile, []byte("XYZ,1,2,Test\n"), 0o600); err != nil {
+		t.Logf("os.WriteFile().error: %v", err)
+		t.FailNow()
+	}
+
+	tests := []struct {
+		args     []string
+		stdin    string
+		exitCode int
+		stdout   string
+	}{
+		{args: []string{"sort"}, stdin: `[["IND","EWR"],["SFO","ATL"],["GSO","IND"],["ATL","GSO"]]`, stdout: `[["SFO","ATL"],["ATL","GSO"],["GSO","IND"],["IND","EWR"]]`},
+		{args: []string{"sort", "-format", "csv", csvFile}, stdout: "src,dst\nSFO,ATL\nATL,GSO\nGSO,IND\nIND,EWR\n"},
+		{args: []string{"itinerary"}, stdin: "[\"GSO\",\"IND\"]\n{\"src\":\"SFO\",\"dst\":\"GSO\"}\n", stdout: `{"start":"SFO","end":"IND","flights":2,"airports":["SFO","GSO","IND"]}`},
+		{args: []string{"subroutes", "-format", "csv"}, stdin: "SFO,GSO\nGSO,IND\n", stdout: "route,flight,src,dst\n1,1,SFO,GSO\n2,1,SFO,GSO\n2,2,GSO,IND\n3,1,GSO,IND\n"},
+		{args: []string{"count", "-format", "table", csvFile}, stdout: "FLIGHTS  AIRPORTS  SUBROUTES\n4        5         10\n"},
+		{args: []string{"validate"}, stdin: `[["SFO","GSO"]]`, stdout: `{"valid":true}`},
+
+		// exports, the forks and the disconnected fragments aren't rejected
+		{args: []string{"export"}, stdin: `[["SFO","GSO"]]`, stdout: "digraph flights {\n  rankdir=LR;\n  node [shape=box, style=rounded];\n  \"SFO\" [color=\"#1f77b4\"];\n  \"GSO\" [color=\"#1f77b4\"];\n  \"SFO\" -> \"GSO\" [label=\"/0\", color=\"#1f77b4\"];\n}\n"},
+		{args: []string{"export", "-format", "geojson", "-airports", airportsFile}, stdin: `[["XYZ","GSO"],["GRU","JFK"]]`, stdout: `{"type":"FeatureCollection","features":[{"type":"Feature","geometry":{"type":"Point","coordinates":[2,1]},"properties":{"airport":"XYZ","fork":false,"fragment":0,"name":"Test"}},{"type":"Feature","geometry":{"type":"Point","coordinates":[-79.9373,36.0978]},"properties":{"airport":"GSO","fork":false,"fragment":0,"name":"GreensboroPiedmontTriad"}},{"type":"Feature","geometry":{"type":"Point","coordinates":[-46.4731,-23.4356]},"properties":{"airport":"GRU","fork":false,"fragment":1,"name":"SaoPauloGuarulhos"}},{"type":"Feature","geometry":{"type":"Point","coordinates":[-73.7781,40.6413]},"properties":{"airport":"JFK","fork":false,"fragment":1,"name":"NewYorkJohnF.Kennedy"}},{"type":"Feature","geometry":{"type":"LineString","coordinates":[[2,1],[-79.9373,36.0978]]},"properties":{"dst":"GSO","fork":false,"fragment":0,"pointer":"/0","src":"XYZ"}},{"type":"Feature","geometry":{"type":"LineString","coordinates":[[-46.4731,-23.4356],[-73.7781,40.6413]]},"properties":{"dst":"JFK","fork":false,"fragment":1,"pointer":"/1","src":"GRU"}}]}`},
+		{args: []string{"export", "-format", "svg", "-o", filepath.Join(dir, "flights.svg")}, stdin: `[["SFO","GSO"],["SFO","JFK"]]`},
+		{args: []string{"export"}, stdin: `[["SFO","SFO"]]`, exitCode: exitInvalid},
+		{args: []string{"export", "-airports", filepath.Join(dir, "missing.csv")}, stdin: `[["SFO","GSO"]]`, exitCode: exitIO},
+		{args: []string{"export", "-format", "csv"}, stdin: `[["SFO","GSO"]]`, exitCode: exitUsage},
+		{args: []string{"sort", "-format", "svg"}, stdin: `[["SFO","GSO"]]`, exitCode: exitUsage},
+
+		// validation errors
+		{args: []string{"validate"}, stdin: `[["SFO","GSO"],["GRU","JFK"]]`, exitCode: exitInvalid, stdout: `{"valid":false,"error":{"code":"disconnected_chain","pointer":"/1","message":"the flight GRU-JFK is not connected to the chain that starts at SFO"}}`},
+		{args: []string{"sort"}, stdin: `[["SFO","GSO"],["SFO","JFK"]]`, exitCode: exitInvalid},
+		{args: []string{"sort", "-input-format", "json"}, stdin: `[["SFO"`, exitCode: exitInvalid},
+
+		// i/o errors
+		{args: []string{"count", filepath.Join(dir, "missing.csv")}, exitCode: exitIO},
+		{args: []string{"count", "-o", filepath.Join(dir, "missing", "out.json"), csvFile}, exitCode: exitIO},
+
+		// usage errors
+		{args: []string{}, exitCode: exitUsage},
+		{args: []string{"route"}, exitCode: exitUsage},
+		{args: []string{"count", "-format", "xml", csvFile}, exitCode: exitUsage},
+	}
+
+	for _, test := range tests {
+		var stdout, stderr bytes.Buffer
+		exitCode := run(test.args, strings.NewReader(test.stdin), &stdout, &stderr)
+
+		if exitCode != test.exitCode {
+			t.Logf("%v: exit code %v, expected %v. stderr: %v", test.args, exitCode, test.exitCode, stderr.String())
+			t.FailNow()
+		}
+
+		// the json output is indented
+		output := stdout.String()
+		if strings.HasPrefix(output, "[") || strings.HasPrefix(output, "{") {
+			output = strings.NewReplacer("\n", "", " ", "").Replace(output)
+			test.stdout = strings.ReplaceAll(test.stdout, " ", "")
+		}
+
+		if test.stdout != "" && output != test.stdout {
+			t.Logf("%v: output\n%v\nexpected\n%v", test.args, output, test.stdout)
+			t.FailNow()
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flights/pkg/export"
+	"flights/pkg/types"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// outputFormats formats of the commands, the first one is the default
+var outputFormats = []string{formatJSON, formatCSV, formatTable}
+
+// exportFormats formats of the export command, the first one is the default
+var exportFormats = []string{formatDOT, formatGeoJSON, formatSVG}
+
+// result output of a command. Value is written as json, Header and Rows as csv or table, and Flights as dot, geojson
+// or svg, with the coordinates of Airports
+type result struct {
+	Value  any
+	Header []string
+	Rows   [][]string
+
+	Flights  types.Flights
+	Airports export.Airports
+}
+
+// writeResult writes the result in the format
+func writeResult(w io.Writer, format string, output result) (err error) {
+	switch format {
+	case formatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(output.Value)
+
+	case formatCSV:
+		writer := csv.NewWriter(w)
+		if err = writer.Write(output.Header); err != nil {
+			return
+		}
+		if err = writer.WriteAll(output.Rows); err != nil {
+			return
+		}
+		return writer.Error()
+
+	case formatTable:
+		writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		if _, err = fmt.Fprintln(writer, strings.ToUpper(strings.Join(output.Header, "\t"))); err != nil {
+			return
+		}
+		for _, row := range output.Rows {
+			if _, err = fmt.Fprintln(writer, strings.Join(row, "\t")); err != nil {
+				return
+			}
+		}
+		return writer.Flush()
+
+	case formatDOT:
+		return export.WriteDOT(w, output.Flights)
+
+	case formatGeoJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(export.GeoJSON(output.Flights, output.Airports))
+
+	case formatSVG:
+		return export.WriteSVG(w, output.Flights, output.Airports)
+
+	default:
+		return fmt.Errorf("unknown output format: %v", format)
+	}
+}
@@ -2,10 +2,15 @@
 package main
 
 import (
+	"flights/pkg/cache"
+	"flights/pkg/export"
+	"flights/pkg/jobs"
 	"flights/pkg/server"
 	"fmt"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 )
 
 func main() {
@@ -20,13 +25,162 @@ func serverStart() {
 		port = ":8080"
 	}
 
-	mux := http.NewServeMux()
+	var config server.Config
 
-	calculateHandler := server.MiddlewarePost(http.HandlerFunc(server.GeneratesSubRoutesOfRoute))
-	mux.Handle("/calculate", calculateHandler)
+	// memoization cache of the sub routes. SERVER_CACHE_MAX_ENTRIES=0 disables the cache
+	cacheMaxEntries := 1000
+	if value := os.Getenv("SERVER_CACHE_MAX_ENTRIES"); value != "" {
+		if cacheMaxEntries, err = strconv.Atoi(value); err != nil {
+			panic(fmt.Errorf("main.strconv.Atoi(SERVER_CACHE_MAX_ENTRIES).error: %v", err))
+		}
+	}
+
+	cacheTTL := 10 * time.Minute
+	if value := os.Getenv("SERVER_CACHE_TTL"); value != "" {
+		if cacheTTL, err = time.ParseDuration(value); err != nil {
+			panic(fmt.Errorf("main.time.ParseDuration(SERVER_CACHE_TTL).error: %v", err))
+		}
+	}
+
+	if cacheMaxEntries > 0 {
+		// the weight limit keeps a few huge itineraries from using all the memory
+		config.Cache = cache.NewSubRoutes(cache.NewLRU(cacheMaxEntries, 10_000_000, cacheTTL))
+	}
+
+	// responses of requests with the Idempotency-Key header. SERVER_IDEMPOTENCY_TTL=0 disables the replay
+	idempotencyConfig := server.IdempotencyConfig{TTL: 24 * time.Hour}
+	if value := os.Getenv("SERVER_IDEMPOTENCY_TTL"); value != "" {
+		if idempotencyConfig.TTL, err = time.ParseDuration(value); err != nil {
+			panic(fmt.Errorf("main.time.ParseDuration(SERVER_IDEMPOTENCY_TTL).error: %v", err))
+		}
+	}
+
+	if value := os.Getenv("SERVER_IDEMPOTENCY_MAX_ENTRIES"); value != "" {
+		if idempotencyConfig.MaxEntries, err = strconv.Atoi(value); err != nil {
+			panic(fmt.Errorf("main.strconv.Atoi(SERVER_IDEMPOTENCY_MAX_ENTRIES).error: %v", err))
+		}
+	}
+
+	if idempotencyConfig.TTL > 0 {
+		config.Idempotency = server.NewIdempotency(idempotencyConfig)
+	}
+
+	// asynchronous jobs. SERVER_JOBS_WORKERS=0 disables the /v1/jobs endpoints
+	jobsConfig := jobs.Config{Workers: 2}
+	if value := os.Getenv("SERVER_JOBS_WORKERS"); value != "" {
+		if jobsConfig.Workers, err = strconv.Atoi(value); err != nil {
+			panic(fmt.Errorf("main.strconv.Atoi(SERVER_JOBS_WORKERS).error: %v", err))
+		}
+	}
+
+	if value := os.Getenv("SERVER_JOBS_QUEUE_SIZE"); value != "" {
+		if jobsConfig.QueueSize, err = strconv.Atoi(value); err != nil {
+			panic(fmt.Errorf("main.strconv.Atoi(SERVER_JOBS_QUEUE_SIZE).error: %v", err))
+		}
+	}
+
+	if value := os.Getenv("SERVER_JOBS_TTL"); value != "" {
+		if jobsConfig.TTL, err = time.ParseDuration(value); err != nil {
+			panic(fmt.Errorf("main.time.ParseDuration(SERVER_JOBS_TTL).error: %v", err))
+		}
+	}
+
+	// results are kept in memory unless a directory is informed
+	if jobsDir := os.Getenv("SERVER_JOBS_DIR"); jobsDir != "" {
+		if jobsConfig.Store, err = jobs.NewDiskStore(jobsDir); err != nil {
+			panic(fmt.Errorf("main.jobs.NewDiskStore().error: %v", err))
+		}
+	}
+
+	if jobsConfig.Workers > 0 {
+		config.Jobs = jobs.NewManager(jobsConfig)
+	}
+
+	// coordinates of the airports of ?format=geojson and svg, added to the airports embedded in the binary
+	if airportsFile := os.Getenv("SERVER_AIRPORTS_FILE"); airportsFile != "" {
+		if config.Airports, err = export.LoadAirports(airportsFile); err != nil {
+			panic(fmt.Errorf("main.export.LoadAirports().error: %v", err))
+		}
+	}
+
+	// rate limit is enabled when the configuration file is informed
+	if rateLimitConfigFile := os.Getenv("SERVER_RATE_LIMIT_CONFIG_FILE"); rateLimitConfigFile != "" {
+		rateLimitConfig, err := server.LoadRateLimitConfig(rateLimitConfigFile)
+		if err != nil {
+			panic(fmt.Errorf("main.server.LoadRateLimitConfig().error: %v", err))
+		}
+
+		config.RateLimiter, err = server.NewRateLimiter(rateLimitConfig)
+		if err != nil {
+			panic(fmt.Errorf("main.server.NewRateLimiter().error: %v", err))
+		}
+	}
+
+	// authentication is enabled when the configuration file is informed
+	if authConfigFile := os.Getenv("SERVER_AUTH_CONFIG_FILE"); authConfigFile != "" {
+		authConfig, err := server.LoadAuthConfig(authConfigFile)
+		if err != nil {
+			panic(fmt.Errorf("main.server.LoadAuthConfig().error: %v", err))
+		}
+
+		config.Auth, err = server.NewAuth(authConfig)
+		if err != nil {
+			panic(fmt.Errorf("main.server.NewAuth().error: %v", err))
+		}
+	}
+
+	// cors is enabled when the configuration file is informed
+	if corsConfigFile := os.Getenv("SERVER_CORS_CONFIG_FILE"); corsConfigFile != "" {
+		corsConfig, err := server.LoadCORSConfig(corsConfigFile)
+		if err != nil {
+			panic(fmt.Errorf("main.server.LoadCORSConfig().error: %v", err))
+		}
+
+		config.CORS, err = server.NewCORS(corsConfig)
+		if err != nil {
+			panic(fmt.Errorf("main.server.NewCORS().error: %v", err))
+		}
+	}
+
+	mux, err := server.NewMux(config)
+	if err != nil {
+		panic(fmt.Errorf("main.server.NewMux().error: %v", err))
+	}
+
+	// tls is enabled when the certificate files are informed
+	// SERVER_TLS_CLIENT_CA_FILE is optional and enables the client certificate authentication (mTLS)
+	tlsConfig := server.TLSConfig{
+		CertFile:     os.Getenv("SERVER_TLS_CERT_FILE"),
+		KeyFile:      os.Getenv("SERVER_TLS_KEY_FILE"),
+		ClientCAFile: os.Getenv("SERVER_TLS_CLIENT_CA_FILE"),
+	}
+
+	enabled, err := tlsConfig.Enabled()
+	if err != nil {
+		panic(fmt.Errorf("main.server.TLSConfig.Enabled().error: %v", err))
+	}
+
+	if !enabled {
+		fmt.Printf("Server started at http://localhost%v\n", port)
+		if err = http.ListenAndServe(port, mux); err != nil {
+			panic(fmt.Errorf("main.http.ListenAndServe().error: %v", err))
+		}
+		return
+	}
+
+	httpServer := &http.Server{
+		Addr:    port,
+		Handler: mux,
+	}
+
+	httpServer.TLSConfig, err = server.NewTLSConfig(tlsConfig)
+	if err != nil {
+		panic(fmt.Errorf("main.server.NewTLSConfig().error: %v", err))
+	}
 
-	fmt.Printf("Server started at http://localhost%v\n", port)
-	if err = http.ListenAndServe(port, mux); err != nil {
-		panic(fmt.Errorf("main.http.ListenAndServe().error: %v", err))
+	fmt.Printf("Server started at https://localhost%v\n", port)
+	// the certificate files are loaded by TLSConfig, so they are reloaded when changed on disk
+	if err = httpServer.ListenAndServeTLS("", ""); err != nil {
+		panic(fmt.Errorf("main.httpServer.ListenAndServeTLS().error: %v", err))
 	}
 }
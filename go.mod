@@ -2,7 +2,12 @@ module flights
 
 go 1.18
 
-require github.com/helmutkemper/chaos v0.1.4
+require (
+	github.com/helmutkemper/chaos v0.1.4
+	github.com/klauspost/compress v1.16.5
+	golang.org/x/net v0.9.0
+	golang.org/x/sync v0.1.0
+)
 
 require (
 	github.com/Microsoft/go-winio v0.5.2 // indirect
@@ -29,7 +34,6 @@ require (
 	github.com/sirupsen/logrus v1.8.1 // indirect
 	github.com/xanzy/ssh-agent v0.3.0 // indirect
 	golang.org/x/crypto v0.8.0 // indirect
-	golang.org/x/net v0.9.0 // indirect
 	golang.org/x/sys v0.7.0 // indirect
 	golang.org/x/text v0.9.0 // indirect
 	gopkg.in/warnings.v0 v0.1.2 // indirect
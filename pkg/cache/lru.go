@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Backend storage of the computed sub routes.
+// LRU is the in-process implementation; a shared cache only needs to implement this interface.
+type Backend interface {
+	// Get returns the sub routes stored with the key
+	Get(key string) (routes [][][]string, found bool)
+
+	// Set stores the sub routes with the key
+	Set(key string, routes [][][]string)
+}
+
+// lruEntry element of the LRU list
+type lruEntry struct {
+	key       string
+	routes    [][][]string
+	weight    int64
+	expiresAt time.Time
+}
+
+// LRU in-process least recently used cache with size and TTL limits. It is safe for concurrent use
+type LRU struct {
+	maxEntries int
+	maxWeight  int64
+	ttl        time.Duration
+	now        func() time.Time
+
+	mutex     sync.Mutex
+	list      *list.List
+	items     map[string]*list.Element
+	weight    int64
+	evictions int64
+}
+
+// NewLRU returns a LRU cache.
+//
+//	maxEntries: maximum number of itineraries. Zero means no limit
+//	maxWeight: maximum number of flights inside all stored sub routes, a measure of memory usage. Zero means no limit
+//	ttl: time to live of each entry. Zero means no expiration
+func NewLRU(maxEntries int, maxWeight int64, ttl time.Duration) *LRU {
+	return &LRU{
+		maxEntries: maxEntries,
+		maxWeight:  maxWeight,
+		ttl:        ttl,
+		now:        time.Now,
+		list:       list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// weightOf number of flights inside all sub routes
+func weightOf(routes [][][]string) (weight int64) {
+	for _, route := range routes {
+		weight += int64(len(route))
+	}
+	return
+}
+
+// Get returns the sub routes stored with the key, if not expired
+func (e *LRU) Get(key string) (routes [][][]string, found bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	element, found := e.items[key]
+	if !found {
+		return
+	}
+
+	entry := element.Value.(*lruEntry)
+	if e.ttl != 0 && e.now().After(entry.expiresAt) {
+		e.remove(element)
+		return nil, false
+	}
+
+	e.list.MoveToFront(element)
+	return entry.routes, true
+}
+
+// Set stores the sub routes with the key and removes the least recently used entries above the limits.
+// Entries heavier than maxWeight are not stored.
+func (e *LRU) Set(key string, routes [][][]string) {
+	weight := weightOf(routes)
+	if e.maxWeight != 0 && weight > e.maxWeight {
+		return
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if element, found := e.items[key]; found {
+		e.remove(element)
+	}
+
+	entry := &lruEntry{
+		key:       key,
+		routes:    routes,
+		weight:    weight,
+		expiresAt: e.now().Add(e.ttl),
+	}
+	e.items[key] = e.list.PushFront(entry)
+	e.weight += weight
+
+	for (e.maxEntries != 0 && e.list.Len() > e.maxEntries) || (e.maxWeight != 0 && e.weight > e.maxWeight) {
+		e.remove(e.list.Back())
+		e.evictions += 1
+	}
+}
+
+// remove deletes the element. The mutex must be locked
+func (e *LRU) remove(element *list.Element) {
+	entry := element.Value.(*lruEntry)
+	e.list.Remove(element)
+	delete(e.items, entry.key)
+	e.weight -= entry.weight
+}
+
+// Len number of stored entries
+func (e *LRU) Len() int {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	return e.list.Len()
+}
+
+// Evictions number of entries removed by the size limits
+func (e *LRU) Evictions() int64 {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	return e.evictions
+}
@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"flights/pkg/types"
+	"sync/atomic"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Statistics hit and miss counters of the cache
+type Statistics struct {
+	// Hits sub routes found in the cache
+	Hits int64 `json:"hits"`
+
+	// Misses sub routes computed because they weren't in the cache
+	Misses int64 `json:"misses"`
+
+	// Shared requests that shared the result with identical requests computed at the same time
+	Shared int64 `json:"shared"`
+
+	// Entries number of itineraries in the cache. Only reported by backends like LRU
+	Entries int `json:"entries"`
+
+	// Evictions entries removed by the size limits. Only reported by backends like LRU
+	Evictions int64 `json:"evictions"`
+}
+
+// backendStatistics optional interface of backends able to report their size, implemented by LRU
+type backendStatistics interface {
+	Len() int
+	Evictions() int64
+}
+
+// SubRoutes memoization cache in front of Flights.GetSubRoutes().
+// The key is the canonical hash of the flight list, so the same flights in any order share the same entry, and
+// concurrent identical requests are computed only once.
+type SubRoutes struct {
+	backend Backend
+	group   singleflight.Group
+
+	hits   int64
+	misses int64
+	shared int64
+}
+
+// NewSubRoutes returns a cache with the backend
+func NewSubRoutes(backend Backend) *SubRoutes {
+	return &SubRoutes{backend: backend}
+}
+
+// GetSubRoutes returns all sub routes of a route, from the cache when possible.
+// The returned value is shared by all callers and must not be modified.
+func (e *SubRoutes) GetSubRoutes(flights types.Flights) (routes [][][]string) {
+	key := flights.Hash()
+
+	if routes, found := e.backend.Get(key); found {
+		atomic.AddInt64(&e.hits, 1)
+		return routes
+	}
+
+	value, _, shared := e.group.Do(key, func() (interface{}, error) {
+		atomic.AddInt64(&e.misses, 1)
+
+		routes := flights.GetSubRoutes()
+		e.backend.Set(key, routes)
+		return routes, nil
+	})
+
+	if shared {
+		atomic.AddInt64(&e.shared, 1)
+	}
+
+	return value.([][][]string)
+}
+
+// Statistics returns the hit and miss counters
+func (e *SubRoutes) Statistics() (statistics Statistics) {
+	statistics = Statistics{
+		Hits:   atomic.LoadInt64(&e.hits),
+		Misses: atomic.LoadInt64(&e.misses),
+		Shared: atomic.LoadInt64(&e.shared),
+	}
+
+	if backend, ok := e.backend.(backendStatistics); ok {
+		statistics.Entries = backend.Len()
+		statistics.Evictions = backend.Evictions()
+	}
+
+	return
+}
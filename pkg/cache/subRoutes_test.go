@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"flights/pkg/types"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLRU_Limits(t *testing.T) {
+	now := time.Unix(1000, 0)
+	lru := NewLRU(2, 0, time.Minute)
+	lru.now = func() time.Time { return now }
+
+	lru.Set("a", [][][]string{{{"A", "B"}}})
+	lru.Set("b", [][][]string{{{"B", "C"}}})
+
+	// "a" becomes the most recently used, so "b" is evicted
+	if _, found := lru.Get("a"); !found {
+		t.Log("entry 'a' not found")
+		t.FailNow()
+	}
+	lru.Set("c", [][][]string{{{"C", "D"}}})
+
+	if _, found := lru.Get("b"); found || lru.Len() != 2 || lru.Evictions() != 1 {
+		t.Logf("the least recently used entry must be evicted. len: %v, evictions: %v", lru.Len(), lru.Evictions())
+		t.FailNow()
+	}
+
+	now = now.Add(time.Minute + time.Second)
+	if _, found := lru.Get("a"); found {
+		t.Log("expired entry was returned")
+		t.FailNow()
+	}
+
+	// the weight limit counts the flights inside all sub routes
+	lru = NewLRU(0, 3, 0)
+	lru.Set("a", [][][]string{{{"A", "B"}}, {{"A", "B"}, {"B", "C"}}})
+	lru.Set("b", [][][]string{{{"B", "C"}}, {{"B", "C"}, {"C", "D"}}})
+	if _, found := lru.Get("a"); found || lru.Len() != 1 {
+		t.Logf("the weight limit was not respected. len: %v", lru.Len())
+		t.FailNow()
+	}
+}
+
+// countingBackend counts the computed sub routes and holds them until released
+type countingBackend struct {
+	*LRU
+	sets    int64
+	release chan struct{}
+}
+
+func (e *countingBackend) Set(key string, routes [][][]string) {
+	<-e.release
+	atomic.AddInt64(&e.sets, 1)
+	e.LRU.Set(key, routes)
+}
+
+func TestSubRoutes_GetSubRoutes(t *testing.T) {
+	backend := &countingBackend{LRU: NewLRU(10, 0, 0), release: make(chan struct{})}
+	subRoutes := NewSubRoutes(backend)
+
+	expected := (&types.Flights{{"IND", "EWR"}, {"SFO", "ATL"}, {"GSO", "IND"}, {"ATL", "GSO"}}).GetSubRoutes()
+
+	var wg sync.WaitGroup
+	for i := 0; i != 10; i += 1 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			routes := subRoutes.GetSubRoutes(types.Flights{{"IND", "EWR"}, {"SFO", "ATL"}, {"GSO", "IND"}, {"ATL", "GSO"}})
+			if !reflect.DeepEqual(routes, expected) {
+				t.Log("sub routes error")
+				t.Fail()
+			}
+		}()
+	}
+
+	// gives time to the identical requests to join the computation in progress
+	time.Sleep(50 * time.Millisecond)
+	close(backend.release)
+	wg.Wait()
+
+	// same flights in other order
+	routes := subRoutes.GetSubRoutes(types.Flights{{"ATL", "GSO"}, {"GSO", "IND"}, {"SFO", "ATL"}, {"IND", "EWR"}})
+	if !reflect.DeepEqual(routes, expected) {
+		t.Log("sub routes error")
+		t.FailNow()
+	}
+
+	statistics := subRoutes.Statistics()
+	if atomic.LoadInt64(&backend.sets) != 1 || statistics.Misses != 1 || statistics.Hits == 0 || statistics.Entries != 1 {
+		t.Logf("the sub routes must be computed once. sets: %v, statistics: %+v", backend.sets, statistics)
+		t.FailNow()
+	}
+}
+
+func TestSubRoutes_GetSubRoutesLoop(t *testing.T) {
+	subRoutes := NewSubRoutes(NewLRU(10, 0, 0))
+
+	// the rotations of a closed loop share the cache entry and must have the same sub routes of a fresh computation
+	for _, flights := range []types.Flights{
+		{{"A", "B"}, {"B", "C"}, {"C", "A"}},
+		{{"B", "C"}, {"C", "A"}, {"A", "B"}},
+	} {
+		expected := (&types.Flights{{"B", "C"}, {"C", "A"}, {"A", "B"}}).GetSubRoutes()
+		if routes := subRoutes.GetSubRoutes(flights); !reflect.DeepEqual(routes, expected) {
+			t.Logf("%v: sub routes %v, expected %v", flights, routes, expected)
+			t.FailNow()
+		}
+	}
+}
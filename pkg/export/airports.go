@@ -0,0 +1,96 @@
+package export
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+//go:embed airports.csv
+var airportsCSV []byte
+
+// Coordinates position of an airport, in decimal degrees
+type Coordinates struct {
+	Latitude  float64
+	Longitude float64
+
+	// Name [optional] name of the airport
+	Name string
+}
+
+// Airports coordinates of the airports, by code. Ex.: Airports{"SFO": {37.6213, -122.3790, "San Francisco"}}
+type Airports map[string]Coordinates
+
+// DefaultAirports returns the coordinates of the major airports, embedded in the binary
+func DefaultAirports() Airports {
+	airports, err := ParseAirports(bytes.NewReader(airportsCSV))
+	if err != nil {
+		panic(fmt.Errorf("export.DefaultAirports().ParseAirports().error: %v", err))
+	}
+	return airports
+}
+
+// ParseAirports decodes a csv with one airport per line, in the code,latitude,longitude[,name] format.
+// Lines starting with # are comments
+func ParseAirports(r io.Reader) (airports Airports, err error) {
+	airports = make(Airports)
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return airports, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		if len(record) < 3 {
+			return nil, fmt.Errorf("line %v: the airport must be in the code,latitude,longitude[,name] format", line)
+		}
+
+		var coordinates Coordinates
+		if coordinates.Latitude, err = strconv.ParseFloat(record[1], 64); err != nil || coordinates.Latitude < -90 || coordinates.Latitude > 90 {
+			return nil, fmt.Errorf("line %v: invalid latitude %q", line, record[1])
+		}
+		if coordinates.Longitude, err = strconv.ParseFloat(record[2], 64); err != nil || coordinates.Longitude < -180 || coordinates.Longitude > 180 {
+			return nil, fmt.Errorf("line %v: invalid longitude %q", line, record[2])
+		}
+		if len(record) > 3 {
+			coordinates.Name = record[3]
+		}
+
+		airports[strings.TrimSpace(record[0])] = coordinates
+	}
+}
+
+// LoadAirports loads the csv file of ParseAirports() and adds its airports to DefaultAirports().
+// The coordinates of the file replace the default ones of the same airport
+func LoadAirports(path string) (airports Airports, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer func() { _ = file.Close() }()
+
+	loaded, err := ParseAirports(file)
+	if err != nil {
+		return nil, err
+	}
+
+	airports = DefaultAirports()
+	for code, coordinates := range loaded {
+		airports[code] = coordinates
+	}
+	return
+}
@@ -0,0 +1,32 @@
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseAirports(t *testing.T) {
+	airports, err := ParseAirports(strings.NewReader("# code,latitude,longitude,name\nSFO,37.6213,-122.3790,San Francisco\nXYZ, -10.5, 20\n"))
+	if err != nil || len(airports) != 2 || airports["SFO"].Name != "San Francisco" || airports["XYZ"].Latitude != -10.5 || airports["XYZ"].Longitude != 20 {
+		t.Logf("ParseAirports(): %v, error: %v", airports, err)
+		t.FailNow()
+	}
+
+	for _, data := range []string{"SFO,37.6", "SFO,91,0", "SFO,0,abc"} {
+		if _, err = ParseAirports(strings.NewReader(data)); err == nil {
+			t.Logf("ParseAirports(%v) must fail", data)
+			t.FailNow()
+		}
+	}
+
+	// the airports of the file are added to the default ones
+	path := filepath.Join(t.TempDir(), "airports.csv")
+	_ = os.WriteFile(path, []byte("XYZ,1,2\nSFO,0,0\n"), 0o600)
+	airports, err = LoadAirports(path)
+	if err != nil || airports["XYZ"].Longitude != 2 || airports["SFO"].Latitude != 0 || airports["GRU"].Latitude == 0 {
+		t.Logf("LoadAirports(): error: %v", err)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,86 @@
+package export
+
+import (
+	"bufio"
+	"flights/pkg/types"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	// colorFork color of the forks and of their flights
+	colorFork = "#d62728"
+)
+
+// palette colors of the fragments, the fragment 0 is blue. Fragments beyond the palette repeat the colors
+var palette = []string{"#1f77b4", "#2ca02c", "#ff7f0e", "#9467bd", "#8c564b", "#e377c2", "#17becf", "#7f7f7f"}
+
+// fragmentColor returns the color of the fragment
+func fragmentColor(fragment int) string {
+	return palette[fragment%len(palette)]
+}
+
+// dotEscaper escapes the quoted strings of DOT. Other characters, including non-ASCII, are written as they are
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// quoteDOT returns the DOT quoted string of the text
+func quoteDOT(text string) string {
+	return `"` + dotEscaper.Replace(text) + `"`
+}
+
+// WriteDOT writes the flights as a Graphviz DOT digraph. The label of each flight is its json pointer in the list.
+// When the flights have more than one fragment, each fragment is a cluster with its own color.
+// The forks, airports with more than one departure or arrival, and their flights are red and bold
+func WriteDOT(w io.Writer, flights types.Flights) error {
+	graph := NewGraph(flights)
+	writer := bufio.NewWriter(w)
+
+	fmt.Fprintf(writer, "digraph flights {\n")
+	fmt.Fprintf(writer, "  rankdir=LR;\n")
+	fmt.Fprintf(writer, "  node [shape=box, style=rounded];\n")
+	if graph.Fragments > 1 {
+		fmt.Fprintf(writer, "  label=%v;\n", quoteDOT(fmt.Sprintf("%v disconnected fragments", graph.Fragments)))
+	}
+
+	for fragment := 0; fragment < graph.Fragments; fragment += 1 {
+		indent := "  "
+		if graph.Fragments > 1 {
+			indent = "    "
+			fmt.Fprintf(writer, "  subgraph cluster_%v {\n", fragment)
+			fmt.Fprintf(writer, "    label=%v;\n", quoteDOT("fragment "+strconv.Itoa(fragment+1)))
+			fmt.Fprintf(writer, "    color=%q;\n", fragmentColor(fragment))
+		}
+
+		for _, airport := range graph.Airports {
+			if graph.Fragment[airport] != fragment {
+				continue
+			}
+
+			if graph.Forks[airport] {
+				fmt.Fprintf(writer, "%v%v [color=%q, fontcolor=%q, penwidth=2];\n", indent, quoteDOT(airport), colorFork, colorFork)
+			} else {
+				fmt.Fprintf(writer, "%v%v [color=%q];\n", indent, quoteDOT(airport), fragmentColor(fragment))
+			}
+		}
+
+		if graph.Fragments > 1 {
+			fmt.Fprintf(writer, "  }\n")
+		}
+	}
+
+	for _, k := range graph.Flights {
+		flight := flights[k]
+		pointer := "/" + strconv.Itoa(k)
+
+		if graph.Fork(flight) {
+			fmt.Fprintf(writer, "  %v -> %v [label=%v, color=%q, fontcolor=%q, penwidth=2];\n", quoteDOT(flight[kSrc]), quoteDOT(flight[kDst]), quoteDOT(pointer), colorFork, colorFork)
+		} else {
+			fmt.Fprintf(writer, "  %v -> %v [label=%v, color=%q];\n", quoteDOT(flight[kSrc]), quoteDOT(flight[kDst]), quoteDOT(pointer), fragmentColor(graph.Fragment[flight[kSrc]]))
+		}
+	}
+
+	fmt.Fprintf(writer, "}\n")
+	return writer.Flush()
+}
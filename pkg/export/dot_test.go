@@ -0,0 +1,70 @@
+package export
+
+import (
+	"bytes"
+	"flights/pkg/types"
+	"strings"
+	"testing"
+)
+
+func TestWriteDOT(t *testing.T) {
+	var output bytes.Buffer
+	if err := WriteDOT(&output, types.Flights{{"IND", "EWR"}, {"GSO", "IND"}}); err != nil {
+		t.Logf("WriteDOT().error: %v", err)
+		t.FailNow()
+	}
+
+	expected := `digraph flights {
+  rankdir=LR;
+  node [shape=box, style=rounded];
+  "IND" [color="#1f77b4"];
+  "EWR" [color="#1f77b4"];
+  "GSO" [color="#1f77b4"];
+  "IND" -> "EWR" [label="/0", color="#1f77b4"];
+  "GSO" -> "IND" [label="/1", color="#1f77b4"];
+}
+`
+	if output.String() != expected {
+		t.Logf("output\n%v\nexpected\n%v", output.String(), expected)
+		t.FailNow()
+	}
+
+	// the fragments are clusters and the forks are red
+	output.Reset()
+	if err := WriteDOT(&output, types.Flights{{"SFO", "GSO"}, {"SFO", "JFK"}, {"GRU", "LIM"}, {"AB\"C", "DEF"}}); err != nil {
+		t.Logf("WriteDOT().error: %v", err)
+		t.FailNow()
+	}
+
+	for _, line := range []string{
+		`label="3 disconnected fragments";`,
+		`subgraph cluster_0 {`,
+		`subgraph cluster_2 {`,
+		`"SFO" [color="#d62728", fontcolor="#d62728", penwidth=2];`,
+		`"SFO" -> "JFK" [label="/1", color="#d62728", fontcolor="#d62728", penwidth=2];`,
+		`"GRU" -> "LIM" [label="/2", color="#2ca02c"];`,
+		`"AB\"C" -> "DEF" [label="/3", color="#ff7f0e"];`,
+	} {
+		if !strings.Contains(output.String(), line) {
+			t.Logf("the output must contain %v\n%v", line, output.String())
+			t.FailNow()
+		}
+	}
+
+	// only the quote and the backslash are escaped, Graphviz prints the other characters as they are
+	output.Reset()
+	if err := WriteDOT(&output, types.Flights{{"São Paulo", "Zürich"}, {`C:\`, "東京"}}); err != nil {
+		t.Logf("WriteDOT().error: %v", err)
+		t.FailNow()
+	}
+
+	for _, line := range []string{
+		`"São Paulo" -> "Zürich" [label="/0", color="#1f77b4"];`,
+		`"C:\\" -> "東京" [label="/1", color="#2ca02c"];`,
+	} {
+		if !strings.Contains(output.String(), line) {
+			t.Logf("the output must contain %v\n%v", line, output.String())
+			t.FailNow()
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package export
+
+import (
+	"encoding/json"
+	"flights/pkg/types"
+	"io"
+	"strconv"
+)
+
+// FeatureCollection GeoJSON document, RFC 7946
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+// Feature GeoJSON feature. The geometry is null when an airport of the flight doesn't have coordinates
+type Feature struct {
+	Type       string         `json:"type"`
+	Geometry   *Geometry      `json:"geometry"`
+	Properties map[string]any `json:"properties"`
+}
+
+// Geometry GeoJSON Point or LineString, the positions are [longitude, latitude]
+type Geometry struct {
+	Type        string `json:"type"`
+	Coordinates any    `json:"coordinates"`
+}
+
+// position returns the GeoJSON position of the coordinates, longitude first
+func position(coordinates Coordinates) []float64 {
+	return []float64{coordinates.Longitude, coordinates.Latitude}
+}
+
+// GeoJSON returns one Point per airport and one LineString per flight, from the source to the destination.
+// The properties of the flights have the json pointer of the flight in the list, the fragment and the fork flag,
+// and the flights with airports without coordinates list them in "missing"
+func GeoJSON(flights types.Flights, airports Airports) (collection FeatureCollection) {
+	graph := NewGraph(flights)
+	collection = FeatureCollection{Type: "FeatureCollection", Features: make([]Feature, 0, len(graph.Airports)+len(graph.Flights))}
+
+	for _, airport := range graph.Airports {
+		feature := Feature{Type: "Feature", Properties: map[string]any{
+			"airport":  airport,
+			"fragment": graph.Fragment[airport],
+			"fork":     graph.Forks[airport],
+		}}
+
+		if coordinates, found := airports[airport]; found {
+			feature.Geometry = &Geometry{Type: "Point", Coordinates: position(coordinates)}
+			if coordinates.Name != "" {
+				feature.Properties["name"] = coordinates.Name
+			}
+		}
+
+		collection.Features = append(collection.Features, feature)
+	}
+
+	for _, k := range graph.Flights {
+		flight := flights[k]
+		feature := Feature{Type: "Feature", Properties: map[string]any{
+			"pointer":  "/" + strconv.Itoa(k),
+			"src":      flight[kSrc],
+			"dst":      flight[kDst],
+			"fragment": graph.Fragment[flight[kSrc]],
+			"fork":     graph.Fork(flight),
+		}}
+
+		src, srcFound := airports[flight[kSrc]]
+		dst, dstFound := airports[flight[kDst]]
+		if srcFound && dstFound {
+			feature.Geometry = &Geometry{Type: "LineString", Coordinates: [][]float64{position(src), position(dst)}}
+		} else {
+			missing := make([]string, 0, 2)
+			if !srcFound {
+				missing = append(missing, flight[kSrc])
+			}
+			if !dstFound {
+				missing = append(missing, flight[kDst])
+			}
+			feature.Properties["missing"] = missing
+		}
+
+		collection.Features = append(collection.Features, feature)
+	}
+
+	return
+}
+
+// WriteGeoJSON writes the GeoJSON() of the flights
+func WriteGeoJSON(w io.Writer, flights types.Flights, airports Airports) error {
+	return json.NewEncoder(w).Encode(GeoJSON(flights, airports))
+}
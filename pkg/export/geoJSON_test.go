@@ -0,0 +1,33 @@
+package export
+
+import (
+	"encoding/json"
+	"flights/pkg/types"
+	"reflect"
+	"testing"
+)
+
+func TestGeoJSON(t *testing.T) {
+	airports := Airports{"SFO": {Latitude: 37.6213, Longitude: -122.3790, Name: "San Francisco"}, "ATL": {Latitude: 33.6407, Longitude: -84.4277}}
+	collection := GeoJSON(types.Flights{{"SFO", "ATL"}, {"ATL", "XXX"}}, airports)
+
+	data, _ := json.Marshal(collection)
+	var decoded map[string]any
+	_ = json.Unmarshal(data, &decoded)
+
+	expected := map[string]any{
+		"type": "FeatureCollection",
+		"features": []any{
+			map[string]any{"type": "Feature", "geometry": map[string]any{"type": "Point", "coordinates": []any{-122.3790, 37.6213}}, "properties": map[string]any{"airport": "SFO", "name": "San Francisco", "fragment": 0.0, "fork": false}},
+			map[string]any{"type": "Feature", "geometry": map[string]any{"type": "Point", "coordinates": []any{-84.4277, 33.6407}}, "properties": map[string]any{"airport": "ATL", "fragment": 0.0, "fork": false}},
+			map[string]any{"type": "Feature", "geometry": nil, "properties": map[string]any{"airport": "XXX", "fragment": 0.0, "fork": false}},
+			map[string]any{"type": "Feature", "geometry": map[string]any{"type": "LineString", "coordinates": []any{[]any{-122.3790, 37.6213}, []any{-84.4277, 33.6407}}}, "properties": map[string]any{"pointer": "/0", "src": "SFO", "dst": "ATL", "fragment": 0.0, "fork": false}},
+			map[string]any{"type": "Feature", "geometry": nil, "properties": map[string]any{"pointer": "/1", "src": "ATL", "dst": "XXX", "fragment": 0.0, "fork": false, "missing": []any{"XXX"}}},
+		},
+	}
+
+	if !reflect.DeepEqual(decoded, expected) {
+		t.Logf("GeoJSON()\n%s", data)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,109 @@
+package export
+
+import (
+	"flights/pkg/types"
+	"sort"
+)
+
+const (
+	// source of flight
+	kSrc = 0
+	// destination of flight
+	kDst = 1
+)
+
+// Graph flights as a directed graph, with the disconnected fragments and the forks that prevent Flights.Sort().
+// The flights don't need to be sorted or valid, the flights that aren't in the [src, dst] format are ignored
+type Graph struct {
+	// Airports airports in the order they appear in the flights
+	Airports []string
+
+	// Flights index of the valid flights in the original list
+	Flights []int
+
+	// Fragment index of the fragment of each airport. The fragment 0 has the most flights
+	Fragment map[string]int
+
+	// Fragments number of disconnected fragments. A single chain has one fragment
+	Fragments int
+
+	// Forks airports with more than one departure or more than one arrival
+	Forks map[string]bool
+
+	departures map[string]int
+	arrivals   map[string]int
+}
+
+// NewGraph returns the graph of the flights
+func NewGraph(flights types.Flights) (graph Graph) {
+	graph.Fragment = make(map[string]int)
+	graph.Forks = make(map[string]bool)
+
+	// union-find of the airports, the fragments are the weakly connected components of the graph
+	parent := make(map[string]string)
+	var find func(airport string) string
+	find = func(airport string) string {
+		if parent[airport] != airport {
+			parent[airport] = find(parent[airport])
+		}
+		return parent[airport]
+	}
+
+	departures := make(map[string]int)
+	arrivals := make(map[string]int)
+	graph.departures, graph.arrivals = departures, arrivals
+	for k, flight := range flights {
+		if len(flight) != 2 || flight[kSrc] == "" || flight[kDst] == "" {
+			continue
+		}
+		graph.Flights = append(graph.Flights, k)
+
+		for _, airport := range flight {
+			if _, found := parent[airport]; !found {
+				parent[airport] = airport
+				graph.Airports = append(graph.Airports, airport)
+			}
+		}
+		parent[find(flight[kSrc])] = find(flight[kDst])
+
+		departures[flight[kSrc]] += 1
+		arrivals[flight[kDst]] += 1
+		if departures[flight[kSrc]] > 1 {
+			graph.Forks[flight[kSrc]] = true
+		}
+		if arrivals[flight[kDst]] > 1 {
+			graph.Forks[flight[kDst]] = true
+		}
+	}
+
+	// the fragments are numbered by the number of flights, then by the first appearance
+	size := make(map[string]int)
+	roots := make([]string, 0)
+	for _, k := range graph.Flights {
+		root := find(flights[k][kSrc])
+		if _, found := size[root]; !found {
+			roots = append(roots, root)
+		}
+		size[root] += 1
+	}
+
+	sort.SliceStable(roots, func(i, j int) bool {
+		return size[roots[i]] > size[roots[j]]
+	})
+
+	index := make(map[string]int, len(roots))
+	for k, root := range roots {
+		index[root] = k
+	}
+	for _, airport := range graph.Airports {
+		graph.Fragment[airport] = index[find(airport)]
+	}
+	graph.Fragments = len(roots)
+
+	return
+}
+
+// Fork returns true when the flight is one of the departures or arrivals of a fork
+func (e Graph) Fork(flight []string) bool {
+	return e.departures[flight[kSrc]] > 1 || e.arrivals[flight[kDst]] > 1
+}
@@ -0,0 +1,63 @@
+package export
+
+import (
+	"flights/pkg/types"
+	"reflect"
+	"testing"
+)
+
+func TestNewGraph(t *testing.T) {
+	tests := []struct {
+		flights   types.Flights
+		airports  []string
+		fragments int
+		fragment  map[string]int
+		forks     []string
+	}{
+		{
+			flights:   types.Flights{{"IND", "EWR"}, {"SFO", "ATL"}, {"GSO", "IND"}, {"ATL", "GSO"}},
+			airports:  []string{"IND", "EWR", "SFO", "ATL", "GSO"},
+			fragments: 1,
+			fragment:  map[string]int{"IND": 0, "EWR": 0, "SFO": 0, "ATL": 0, "GSO": 0},
+			forks:     []string{},
+		},
+		{
+			// the largest fragment is the first one
+			flights:   types.Flights{{"GRU", "JFK"}, {"IND", "EWR"}, {"EWR", "SFO"}},
+			airports:  []string{"GRU", "JFK", "IND", "EWR", "SFO"},
+			fragments: 2,
+			fragment:  map[string]int{"GRU": 1, "JFK": 1, "IND": 0, "EWR": 0, "SFO": 0},
+			forks:     []string{},
+		},
+		{
+			// the invalid flights are ignored
+			flights:   types.Flights{{"SFO", "GSO"}, {"SFO", "JFK"}, {"GRU"}, {"LIM", "JFK"}, {"", "MEX"}},
+			airports:  []string{"SFO", "GSO", "JFK", "LIM"},
+			fragments: 1,
+			fragment:  map[string]int{"SFO": 0, "GSO": 0, "JFK": 0, "LIM": 0},
+			forks:     []string{"JFK", "SFO"},
+		},
+	}
+
+	for _, test := range tests {
+		graph := NewGraph(test.flights)
+
+		forks := make([]string, 0)
+		for _, airport := range test.airports {
+			if graph.Forks[airport] {
+				forks = append(forks, airport)
+			}
+		}
+
+		if !reflect.DeepEqual(graph.Airports, test.airports) || graph.Fragments != test.fragments || !reflect.DeepEqual(graph.Fragment, test.fragment) || len(forks) != len(test.forks) || len(graph.Forks) != len(test.forks) {
+			t.Logf("%v: airports %v, fragments %v %v, forks %v", test.flights, graph.Airports, graph.Fragments, graph.Fragment, graph.Forks)
+			t.FailNow()
+		}
+	}
+
+	graph := NewGraph(types.Flights{{"SFO", "GSO"}, {"SFO", "JFK"}, {"GSO", "IND"}})
+	if !graph.Fork([]string{"SFO", "GSO"}) || !graph.Fork([]string{"SFO", "JFK"}) || graph.Fork([]string{"GSO", "IND"}) {
+		t.Log("only the departures of the fork must be flagged")
+		t.FailNow()
+	}
+}
@@ -0,0 +1,144 @@
+package export
+
+import (
+	"bufio"
+	"flights/pkg/types"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"strconv"
+)
+
+const (
+	// svgWidth and svgHeight size of the image
+	svgWidth  = 960
+	svgHeight = 540
+
+	// svgMargin space around the drawing, for the labels and the legend
+	svgMargin = 48
+
+	// svgRadius radius of the airports
+	svgRadius = 6
+)
+
+// point position in the image
+type point struct {
+	X float64
+	Y float64
+}
+
+// coordinate formats a position of the image
+func coordinate(value float64) string {
+	return strconv.FormatFloat(value, 'f', 1, 64)
+}
+
+// mapLayout places the airports on an equirectangular projection of the bounding box of their coordinates.
+// Returns false when an airport doesn't have coordinates
+func mapLayout(graph Graph, airports Airports) (layout map[string]point, ok bool) {
+	minLon, maxLon, minLat, maxLat := math.Inf(1), math.Inf(-1), math.Inf(1), math.Inf(-1)
+	for _, airport := range graph.Airports {
+		coordinates, found := airports[airport]
+		if !found {
+			return nil, false
+		}
+		minLon, maxLon = math.Min(minLon, coordinates.Longitude), math.Max(maxLon, coordinates.Longitude)
+		minLat, maxLat = math.Min(minLat, coordinates.Latitude), math.Max(maxLat, coordinates.Latitude)
+	}
+
+	// the same scale on both axes keeps the proportions of the map, a single airport is placed at the center
+	width, height := float64(svgWidth-2*svgMargin), float64(svgHeight-2*svgMargin)
+	scale := math.Min(width/math.Max(maxLon-minLon, 1e-9), height/math.Max(maxLat-minLat, 1e-9))
+	offsetX := svgMargin + (width-(maxLon-minLon)*scale)/2
+	offsetY := svgMargin + (height-(maxLat-minLat)*scale)/2
+
+	layout = make(map[string]point, len(graph.Airports))
+	for _, airport := range graph.Airports {
+		coordinates := airports[airport]
+		layout[airport] = point{
+			X: offsetX + (coordinates.Longitude-minLon)*scale,
+			Y: offsetY + (maxLat-coordinates.Latitude)*scale,
+		}
+	}
+	return layout, true
+}
+
+// circleLayout places the airports on a circle, in the order they appear in the flights
+func circleLayout(graph Graph) (layout map[string]point) {
+	center := point{X: svgWidth / 2, Y: svgHeight / 2}
+	radius := float64(svgHeight)/2 - svgMargin
+
+	layout = make(map[string]point, len(graph.Airports))
+	for k, airport := range graph.Airports {
+		angle := 2*math.Pi*float64(k)/float64(len(graph.Airports)) - math.Pi/2
+		layout[airport] = point{X: center.X + radius*math.Cos(angle), Y: center.Y + radius*math.Sin(angle)}
+	}
+	return
+}
+
+// WriteSVG writes a self-contained SVG image of the flights, sorted or not.
+// When every airport has coordinates the image is a map, an equirectangular projection of the bounding box of the
+// airports, otherwise the airports are placed on a circle. The fragments have the colors of WriteDOT() and the
+// forks and their flights are red
+func WriteSVG(w io.Writer, flights types.Flights, airports Airports) error {
+	graph := NewGraph(flights)
+
+	layout, isMap := mapLayout(graph, airports)
+	if !isMap {
+		layout = circleLayout(graph)
+	}
+
+	writer := bufio.NewWriter(w)
+	fmt.Fprintf(writer, `<svg xmlns="http://www.w3.org/2000/svg" width="%v" height="%v" viewBox="0 0 %v %v" font-family="sans-serif" font-size="12">`+"\n", svgWidth, svgHeight, svgWidth, svgHeight)
+	fmt.Fprintf(writer, `<rect width="100%%" height="100%%" fill="#ffffff"/>`+"\n")
+
+	// one arrow head per color, the markers don't inherit the color of the line
+	colors := append([]string{colorFork}, palette...)
+	fmt.Fprintf(writer, "<defs>\n")
+	for k, color := range colors {
+		fmt.Fprintf(writer, `<marker id="arrow-%v" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="6" markerHeight="6" orient="auto"><path d="M0,0L10,5L0,10z" fill="%v"/></marker>`+"\n", k, color)
+	}
+	fmt.Fprintf(writer, "</defs>\n")
+
+	for _, k := range graph.Flights {
+		flight := flights[k]
+		src, dst := layout[flight[kSrc]], layout[flight[kDst]]
+
+		// the line ends at the border of the airports
+		dx, dy := dst.X-src.X, dst.Y-src.Y
+		length := math.Hypot(dx, dy)
+		if length <= 2*svgRadius {
+			continue
+		}
+		dx, dy = dx/length*svgRadius, dy/length*svgRadius
+
+		marker, width, dash := 1+graph.Fragment[flight[kSrc]]%len(palette), 1.5, ""
+		if graph.Fork(flight) {
+			marker, width, dash = 0, 3, ` stroke-dasharray="6 3"`
+		}
+
+		fmt.Fprintf(writer, `<line x1="%v" y1="%v" x2="%v" y2="%v" stroke="%v" stroke-width="%v"%v marker-end="url(#arrow-%v)"><title>/%v %v-%v</title></line>`+"\n",
+			coordinate(src.X+dx), coordinate(src.Y+dy), coordinate(dst.X-dx), coordinate(dst.Y-dy), colors[marker], width, dash, marker,
+			k, html.EscapeString(flight[kSrc]), html.EscapeString(flight[kDst]))
+	}
+
+	for _, airport := range graph.Airports {
+		position := layout[airport]
+		color := fragmentColor(graph.Fragment[airport])
+		if graph.Forks[airport] {
+			color = colorFork
+		}
+
+		title := html.EscapeString(airport)
+		if name := airports[airport].Name; name != "" {
+			title += " " + html.EscapeString(name)
+		}
+
+		fmt.Fprintf(writer, `<circle cx="%v" cy="%v" r="%v" fill="%v"><title>%v</title></circle>`+"\n", coordinate(position.X), coordinate(position.Y), svgRadius, color, title)
+		fmt.Fprintf(writer, `<text x="%v" y="%v" text-anchor="middle" fill="%v">%v</text>`+"\n", coordinate(position.X), coordinate(position.Y-svgRadius-4), color, html.EscapeString(airport))
+	}
+
+	fmt.Fprintf(writer, `<text x="%v" y="%v" fill="#333333">%v flights, %v airports, %v fragments, %v forks</text>`+"\n", svgMargin/2, svgHeight-svgMargin/2, len(graph.Flights), len(graph.Airports), graph.Fragments, len(graph.Forks))
+	fmt.Fprintf(writer, "</svg>\n")
+	return writer.Flush()
+}
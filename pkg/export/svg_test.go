@@ -0,0 +1,56 @@
+package export
+
+import (
+	"bytes"
+	"encoding/xml"
+	"flights/pkg/types"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestWriteSVG(t *testing.T) {
+	tests := []struct {
+		flights  types.Flights
+		contains []string
+	}{
+		{
+			// every airport has coordinates, the image is a map: SFO is on the left and EWR on the right
+			flights:  types.Flights{{"IND", "EWR"}, {"SFO", "ATL"}, {"GSO", "IND"}, {"ATL", "GSO"}},
+			contains: []string{`<circle cx="48.0" `, `<title>SFO San Francisco</title>`, `4 flights, 5 airports, 1 fragments, 0 forks`},
+		},
+		{
+			// XYZ doesn't have coordinates, the airports are placed on a circle
+			flights:  types.Flights{{"SFO", "GSO"}, {"SFO", "XYZ"}, {"<A>", "B&C"}},
+			contains: []string{`stroke="#d62728"`, `&lt;A&gt;`, `B&amp;C`, `3 flights, 5 airports, 2 fragments, 1 forks`},
+		},
+	}
+
+	for _, test := range tests {
+		var output bytes.Buffer
+		if err := WriteSVG(&output, test.flights, DefaultAirports()); err != nil {
+			t.Logf("WriteSVG().error: %v", err)
+			t.FailNow()
+		}
+
+		// the image must be well-formed xml
+		decoder := xml.NewDecoder(bytes.NewReader(output.Bytes()))
+		for {
+			_, err := decoder.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Logf("%v: invalid xml: %v\n%v", test.flights, err, output.String())
+				t.FailNow()
+			}
+		}
+
+		for _, text := range test.contains {
+			if !strings.Contains(output.String(), text) {
+				t.Logf("%v: the image must contain %v\n%v", test.flights, text, output.String())
+				t.FailNow()
+			}
+		}
+	}
+}
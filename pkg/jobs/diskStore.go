@@ -0,0 +1,217 @@
+package jobs
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// validID prevents ids from escaping the directory of the store
+var validID = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// DiskStore keeps the results in a local directory, so big results don't use the memory of the server.
+// Each result is a ndjson file, one sub route per line, and an index file with the offset of each line, used to read
+// a chunk without reading the whole result.
+type DiskStore struct {
+	dir string
+}
+
+// NewDiskStore returns a store in the directory, creating it when necessary
+func NewDiskStore(dir string) (store *DiskStore, err error) {
+	if err = os.MkdirAll(dir, 0o700); err != nil {
+		return
+	}
+
+	return &DiskStore{dir: dir}, nil
+}
+
+// paths returns the data and index files of the result
+func (e *DiskStore) paths(id string) (data, index string, err error) {
+	if !validID.MatchString(id) {
+		return "", "", fmt.Errorf("invalid job id: %v", id)
+	}
+
+	return filepath.Join(e.dir, id+".ndjson"), filepath.Join(e.dir, id+".index"), nil
+}
+
+// diskWriter writes the result in temporary files, renamed when the result is closed
+type diskWriter struct {
+	dataPath  string
+	indexPath string
+
+	dataFile  *os.File
+	indexFile *os.File
+	data      *bufio.Writer
+	index     *bufio.Writer
+	offset    int64
+}
+
+func (e *diskWriter) Write(route [][]string) (err error) {
+	line, err := json.Marshal(route)
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+
+	var offset [8]byte
+	binary.LittleEndian.PutUint64(offset[:], uint64(e.offset))
+	if _, err = e.index.Write(offset[:]); err != nil {
+		return
+	}
+
+	if _, err = e.data.Write(line); err != nil {
+		return
+	}
+
+	e.offset += int64(len(line))
+	return
+}
+
+func (e *diskWriter) Close() (err error) {
+	for _, closeErr := range []error{e.data.Flush(), e.index.Flush(), e.dataFile.Close(), e.indexFile.Close()} {
+		if err == nil {
+			err = closeErr
+		}
+	}
+
+	if err != nil {
+		_ = os.Remove(e.dataFile.Name())
+		_ = os.Remove(e.indexFile.Name())
+		return
+	}
+
+	// the index is renamed last, a result without index doesn't exist for Read()
+	if err = os.Rename(e.dataFile.Name(), e.dataPath); err != nil {
+		return
+	}
+	return os.Rename(e.indexFile.Name(), e.indexPath)
+}
+
+// Create returns the writer of the result of the job
+func (e *DiskStore) Create(id string) (writer ResultWriter, err error) {
+	dataPath, indexPath, err := e.paths(id)
+	if err != nil {
+		return
+	}
+
+	dataFile, err := os.CreateTemp(e.dir, id+".ndjson.*")
+	if err != nil {
+		return
+	}
+
+	indexFile, err := os.CreateTemp(e.dir, id+".index.*")
+	if err != nil {
+		_ = dataFile.Close()
+		_ = os.Remove(dataFile.Name())
+		return
+	}
+
+	writer = &diskWriter{
+		dataPath:  dataPath,
+		indexPath: indexPath,
+		dataFile:  dataFile,
+		indexFile: indexFile,
+		data:      bufio.NewWriter(dataFile),
+		index:     bufio.NewWriter(indexFile),
+	}
+	return
+}
+
+// Read returns up to limit sub routes starting at offset
+func (e *DiskStore) Read(id string, offset, limit int64) (routes [][][]string, total int64, err error) {
+	dataPath, indexPath, err := e.paths(id)
+	if err != nil {
+		return
+	}
+
+	indexFile, err := os.Open(indexPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 0, ErrResultNotFound
+	}
+	if err != nil {
+		return
+	}
+	defer indexFile.Close()
+
+	dataFile, err := os.Open(dataPath)
+	if err != nil {
+		return
+	}
+	defer dataFile.Close()
+
+	indexInfo, err := indexFile.Stat()
+	if err != nil {
+		return
+	}
+
+	total = indexInfo.Size() / 8
+	routes = make([][][]string, 0)
+	if offset >= total || limit <= 0 {
+		return
+	}
+
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+
+	// reads the offset of the first line of the chunk and the offset of the line after the chunk
+	offsets := make([]byte, 8)
+	if _, err = indexFile.ReadAt(offsets, offset*8); err != nil {
+		return
+	}
+	start := int64(binary.LittleEndian.Uint64(offsets))
+
+	var stop int64
+	if end < total {
+		if _, err = indexFile.ReadAt(offsets, end*8); err != nil {
+			return
+		}
+		stop = int64(binary.LittleEndian.Uint64(offsets))
+	} else {
+		dataInfo, err := dataFile.Stat()
+		if err != nil {
+			return nil, 0, err
+		}
+		stop = dataInfo.Size()
+	}
+
+	chunk := make([]byte, stop-start)
+	if _, err = dataFile.ReadAt(chunk, start); err != nil && err != io.EOF {
+		return
+	}
+	err = nil
+
+	for _, line := range bytes.Split(bytes.TrimSuffix(chunk, []byte{'\n'}), []byte{'\n'}) {
+		var route [][]string
+		if err = json.Unmarshal(line, &route); err != nil {
+			return
+		}
+		routes = append(routes, route)
+	}
+
+	return
+}
+
+// Delete removes the result of the job
+func (e *DiskStore) Delete(id string) (err error) {
+	dataPath, indexPath, err := e.paths(id)
+	if err != nil {
+		return
+	}
+
+	for _, path := range []string{indexPath, dataPath} {
+		if err = os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return
+		}
+	}
+
+	return nil
+}
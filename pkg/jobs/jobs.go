@@ -0,0 +1,369 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flights/pkg/types"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// StatusQueued the job is waiting for a free worker
+	StatusQueued = "queued"
+
+	// StatusRunning the sub routes are being generated
+	StatusRunning = "running"
+
+	// StatusSucceeded the result is ready to be downloaded
+	StatusSucceeded = "succeeded"
+
+	// StatusFailed the job finished with error
+	StatusFailed = "failed"
+
+	// StatusCancelled the job was cancelled by the client
+	StatusCancelled = "cancelled"
+)
+
+var (
+	// ErrJobNotFound the job doesn't exist or expired
+	ErrJobNotFound = errors.New("job not found")
+
+	// ErrQueueFull all workers are busy and the queue is full
+	ErrQueueFull = errors.New("the job queue is full")
+
+	// ErrJobFinished the job can't be cancelled because it already finished
+	ErrJobFinished = errors.New("the job already finished")
+
+	// ErrJobNotSucceeded the result can't be downloaded because the job didn't succeed
+	ErrJobNotSucceeded = errors.New("the job didn't succeed")
+
+	// ErrManagerClosed the manager doesn't accept new jobs after Close()
+	ErrManagerClosed = errors.New("the job manager is closed")
+)
+
+// Config configuration of the job manager
+type Config struct {
+	// Workers number of jobs running at the same time. Default 2
+	Workers int
+
+	// QueueSize number of jobs waiting for a worker. Default 100
+	QueueSize int
+
+	// Store [optional] store of the results. Default NewMemoryStore()
+	Store ResultStore
+
+	// TTL time the finished jobs and their results are kept. Default 1h
+	TTL time.Duration
+}
+
+// Job status of an asynchronous sub routes job
+type Job struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+
+	// Done sub routes generated so far
+	Done int64 `json:"done"`
+
+	// Total sub routes of the itinerary, n(n+1)/2 for n flights
+	Total int64 `json:"total"`
+
+	// Progress Done / Total, from 0 to 1
+	Progress float64 `json:"progress"`
+
+	CreatedAt  time.Time  `json:"createdAt"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+
+	// ExpiresAt the job and its result are removed after this time
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+
+	// Error [optional] reason of the failure
+	Error string `json:"error,omitempty"`
+}
+
+// job internal state of a job
+type job struct {
+	id      string
+	flights types.Flights
+	total   int64
+	done    int64
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// fields below are protected by Manager.mutex
+	status     string
+	createdAt  time.Time
+	startedAt  time.Time
+	finishedAt time.Time
+	err        string
+}
+
+// Manager runs the sub routes jobs in a bounded pool of workers
+type Manager struct {
+	config Config
+	now    func() time.Time
+	queue  chan *job
+	wait   sync.WaitGroup
+
+	mutex  sync.Mutex
+	jobs   map[string]*job
+	closed bool
+}
+
+// NewManager starts the workers of the manager
+func NewManager(config Config) *Manager {
+	if config.Workers <= 0 {
+		config.Workers = 2
+	}
+
+	if config.QueueSize <= 0 {
+		config.QueueSize = 100
+	}
+
+	if config.Store == nil {
+		config.Store = NewMemoryStore()
+	}
+
+	if config.TTL <= 0 {
+		config.TTL = time.Hour
+	}
+
+	manager := &Manager{
+		config: config,
+		now:    time.Now,
+		queue:  make(chan *job, config.QueueSize),
+		jobs:   make(map[string]*job),
+	}
+
+	manager.wait.Add(config.Workers)
+	for i := 0; i != config.Workers; i += 1 {
+		go manager.worker()
+	}
+
+	return manager
+}
+
+// newID returns a random job id
+func newID() string {
+	data := make([]byte, 16)
+	_, _ = rand.Read(data)
+	return hex.EncodeToString(data)
+}
+
+// Submit queues the flights. The flights must be valid, see Flights.Validate()
+func (e *Manager) Submit(flights types.Flights) (status Job, err error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.closed {
+		return Job{}, ErrManagerClosed
+	}
+
+	e.expire()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		id:        newID(),
+		flights:   flights,
+		total:     types.CountSubRoutes(len(flights)),
+		ctx:       ctx,
+		cancel:    cancel,
+		status:    StatusQueued,
+		createdAt: e.now(),
+	}
+
+	select {
+	case e.queue <- j:
+	default:
+		cancel()
+		return Job{}, ErrQueueFull
+	}
+
+	e.jobs[j.id] = j
+	return e.status(j), nil
+}
+
+// Get returns the status of the job
+func (e *Manager) Get(id string) (status Job, err error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.expire()
+
+	j, found := e.jobs[id]
+	if !found {
+		return Job{}, ErrJobNotFound
+	}
+
+	return e.status(j), nil
+}
+
+// Cancel stops a queued or running job and removes its partial result
+func (e *Manager) Cancel(id string) (status Job, err error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	j, found := e.jobs[id]
+	if !found {
+		return Job{}, ErrJobNotFound
+	}
+
+	if j.status != StatusQueued && j.status != StatusRunning {
+		return e.status(j), ErrJobFinished
+	}
+
+	// the worker sees the cancelled context and removes the partial result
+	j.cancel()
+	j.status = StatusCancelled
+	j.finishedAt = e.now()
+	return e.status(j), nil
+}
+
+// Result returns up to limit sub routes of the result of the job, starting at offset
+func (e *Manager) Result(id string, offset, limit int64) (routes [][][]string, total int64, err error) {
+	status, err := e.Get(id)
+	if err != nil {
+		return
+	}
+
+	if status.Status != StatusSucceeded {
+		return nil, 0, ErrJobNotSucceeded
+	}
+
+	return e.config.Store.Read(id, offset, limit)
+}
+
+// Close stops accepting jobs, cancels the running jobs and waits for the workers
+func (e *Manager) Close() {
+	e.mutex.Lock()
+	if e.closed {
+		e.mutex.Unlock()
+		return
+	}
+	e.closed = true
+
+	for _, j := range e.jobs {
+		j.cancel()
+	}
+	close(e.queue)
+	e.mutex.Unlock()
+
+	e.wait.Wait()
+}
+
+// status returns the public status of the job. Must be called with the mutex locked
+func (e *Manager) status(j *job) (status Job) {
+	status = Job{
+		ID:        j.id,
+		Status:    j.status,
+		Done:      atomic.LoadInt64(&j.done),
+		Total:     j.total,
+		CreatedAt: j.createdAt,
+		Error:     j.err,
+	}
+
+	if j.total > 0 {
+		status.Progress = float64(status.Done) / float64(j.total)
+	}
+
+	if !j.startedAt.IsZero() {
+		startedAt := j.startedAt
+		status.StartedAt = &startedAt
+	}
+
+	if !j.finishedAt.IsZero() {
+		finishedAt := j.finishedAt
+		expiresAt := j.finishedAt.Add(e.config.TTL)
+		status.FinishedAt = &finishedAt
+		status.ExpiresAt = &expiresAt
+	}
+
+	return
+}
+
+// expire removes the finished jobs older than the ttl. Must be called with the mutex locked
+func (e *Manager) expire() {
+	now := e.now()
+	for id, j := range e.jobs {
+		if j.finishedAt.IsZero() || now.Sub(j.finishedAt) < e.config.TTL {
+			continue
+		}
+
+		// a cancelled job can still be in the worker, which removes the result when it stops
+		if j.status != StatusCancelled {
+			_ = e.config.Store.Delete(id)
+		}
+		delete(e.jobs, id)
+	}
+}
+
+// worker runs the jobs of the queue until the manager is closed
+func (e *Manager) worker() {
+	defer e.wait.Done()
+
+	for j := range e.queue {
+		e.mutex.Lock()
+		if j.status != StatusQueued {
+			e.mutex.Unlock()
+			continue
+		}
+		j.status = StatusRunning
+		j.startedAt = e.now()
+		e.mutex.Unlock()
+
+		err := e.run(j)
+
+		e.mutex.Lock()
+		j.flights = nil
+		switch {
+		case j.ctx.Err() != nil:
+			// cancelled by the client or by Close()
+			_ = e.config.Store.Delete(j.id)
+			j.status = StatusCancelled
+		case err != nil:
+			_ = e.config.Store.Delete(j.id)
+			j.status = StatusFailed
+			j.err = err.Error()
+		default:
+			j.status = StatusSucceeded
+		}
+
+		if j.finishedAt.IsZero() {
+			j.finishedAt = e.now()
+		}
+		e.mutex.Unlock()
+	}
+}
+
+// run generates the sub routes of the job into the store
+func (e *Manager) run(j *job) (err error) {
+	writer, err := e.config.Store.Create(j.id)
+	if err != nil {
+		return
+	}
+
+	j.flights.WalkSubRoutes(func(route [][]string) bool {
+		if j.ctx.Err() != nil {
+			return false
+		}
+
+		if err = writer.Write(route); err != nil {
+			return false
+		}
+
+		atomic.AddInt64(&j.done, 1)
+		return true
+	})
+
+	closeErr := writer.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	return
+}
@@ -0,0 +1,175 @@
+package jobs
+
+import (
+	"flights/pkg/types"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// chain returns a valid itinerary with n flights
+func chain(n int) types.Flights {
+	flights := make(types.Flights, n)
+	for k := range flights {
+		flights[k] = []string{fmt.Sprintf("A%05d", k), fmt.Sprintf("A%05d", k+1)}
+	}
+	return flights
+}
+
+// waitStatus waits until the job leaves the queued and running status
+func waitStatus(t *testing.T, manager *Manager, id string) Job {
+	for i := 0; i != 500; i += 1 {
+		status, err := manager.Get(id)
+		if err != nil {
+			t.Logf("manager.Get().error: %v", err)
+			t.FailNow()
+		}
+
+		if status.Status != StatusQueued && status.Status != StatusRunning {
+			return status
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Logf("the job didn't finish")
+	t.FailNow()
+	return Job{}
+}
+
+func TestManager_Submit(t *testing.T) {
+	disk, err := NewDiskStore(t.TempDir())
+	if err != nil {
+		t.Logf("NewDiskStore().error: %v", err)
+		t.FailNow()
+	}
+
+	for name, store := range map[string]ResultStore{"memory": NewMemoryStore(), "disk": disk} {
+		manager := NewManager(Config{Workers: 1, Store: store})
+
+		flights := types.Flights{{"IND", "EWR"}, {"SFO", "ATL"}, {"GSO", "IND"}, {"ATL", "GSO"}}
+		expected := types.Flights{{"IND", "EWR"}, {"SFO", "ATL"}, {"GSO", "IND"}, {"ATL", "GSO"}}
+
+		job, err := manager.Submit(flights)
+		if err != nil {
+			t.Logf("%v: manager.Submit().error: %v", name, err)
+			t.FailNow()
+		}
+
+		status := waitStatus(t, manager, job.ID)
+		if status.Status != StatusSucceeded || status.Done != 10 || status.Total != 10 || status.Progress != 1 {
+			t.Logf("%v: invalid status: %+v", name, status)
+			t.FailNow()
+		}
+
+		// downloads the result in chunks of three sub routes
+		routes := make([][][]string, 0)
+		for offset := int64(0); ; offset += 3 {
+			chunk, total, err := manager.Result(job.ID, offset, 3)
+			if err != nil {
+				t.Logf("%v: manager.Result().error: %v", name, err)
+				t.FailNow()
+			}
+
+			if total != 10 {
+				t.Logf("%v: total %v, expected 10", name, total)
+				t.FailNow()
+			}
+
+			if len(chunk) == 0 {
+				break
+			}
+			routes = append(routes, chunk...)
+		}
+
+		if !reflect.DeepEqual(routes, expected.GetSubRoutes()) {
+			t.Logf("%v: the result must be equal to GetSubRoutes(). result: %v", name, routes)
+			t.FailNow()
+		}
+
+		if _, _, err = manager.Result("unknown", 0, 3); err != ErrJobNotFound {
+			t.Logf("%v: unknown job must return ErrJobNotFound, found: %v", name, err)
+			t.FailNow()
+		}
+
+		manager.Close()
+	}
+}
+
+func TestManager_Cancel(t *testing.T) {
+	store := NewMemoryStore()
+	manager := NewManager(Config{Workers: 1, QueueSize: 1, Store: store})
+	defer manager.Close()
+
+	// 3000 flights generate 4.5 million sub routes, enough time to cancel the running job
+	running, err := manager.Submit(chain(3000))
+	if err != nil {
+		t.Logf("manager.Submit().error: %v", err)
+		t.FailNow()
+	}
+
+	for i := 0; i != 500; i += 1 {
+		if status, _ := manager.Get(running.ID); status.Status == StatusRunning {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	queued, err := manager.Submit(chain(10))
+	if err != nil {
+		t.Logf("manager.Submit().error: %v", err)
+		t.FailNow()
+	}
+
+	if _, err = manager.Submit(chain(10)); err != ErrQueueFull {
+		t.Logf("the queue must be full, found: %v", err)
+		t.FailNow()
+	}
+
+	for _, id := range []string{queued.ID, running.ID} {
+		if _, err = manager.Cancel(id); err != nil {
+			t.Logf("manager.Cancel().error: %v", err)
+			t.FailNow()
+		}
+	}
+
+	status := waitStatus(t, manager, running.ID)
+	if status.Status != StatusCancelled || status.Done == status.Total {
+		t.Logf("the job must be cancelled before the end: %+v", status)
+		t.FailNow()
+	}
+
+	if _, err = manager.Cancel(running.ID); err != ErrJobFinished {
+		t.Logf("a finished job can't be cancelled, found: %v", err)
+		t.FailNow()
+	}
+
+	if _, _, err = manager.Result(running.ID, 0, 10); err != ErrJobNotSucceeded {
+		t.Logf("a cancelled job doesn't have result, found: %v", err)
+		t.FailNow()
+	}
+}
+
+func TestManager_Expire(t *testing.T) {
+	store := NewMemoryStore()
+	manager := NewManager(Config{Workers: 1, Store: store, TTL: time.Minute})
+	defer manager.Close()
+
+	job, _ := manager.Submit(chain(5))
+	waitStatus(t, manager, job.ID)
+
+	now := time.Now().Add(2 * time.Minute)
+	manager.mutex.Lock()
+	manager.now = func() time.Time { return now }
+	manager.mutex.Unlock()
+
+	if _, err := manager.Get(job.ID); err != ErrJobNotFound {
+		t.Logf("the job must expire after the ttl, found: %v", err)
+		t.FailNow()
+	}
+
+	if _, _, err := store.Read(job.ID, 0, 1); err != ErrResultNotFound {
+		t.Logf("the result must be removed with the job, found: %v", err)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,102 @@
+package jobs
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrResultNotFound the store doesn't have the result of the job
+var ErrResultNotFound = errors.New("result not found")
+
+// ResultWriter receives the sub routes of a job, in order
+type ResultWriter interface {
+	// Write appends a sub route to the result. The route can't be kept after the call returns
+	Write(route [][]string) error
+
+	// Close finishes the result. The result is readable only after Close
+	Close() error
+}
+
+// ResultStore keeps the results of the jobs until they are downloaded or expired
+type ResultStore interface {
+	// Create returns the writer of the result of the job, replacing an older result with the same id
+	Create(id string) (ResultWriter, error)
+
+	// Read returns up to limit sub routes starting at offset, and the total number of sub routes of the result
+	Read(id string, offset, limit int64) (routes [][][]string, total int64, err error)
+
+	// Delete removes the result of the job
+	Delete(id string) error
+}
+
+// MemoryStore keeps the results in memory. Results are lost when the server restarts
+type MemoryStore struct {
+	mutex   sync.RWMutex
+	results map[string][][][]string
+}
+
+// NewMemoryStore returns an empty memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{results: make(map[string][][][]string)}
+}
+
+// memoryWriter accumulates the sub routes until the result is closed
+type memoryWriter struct {
+	store  *MemoryStore
+	id     string
+	routes [][][]string
+}
+
+func (e *memoryWriter) Write(route [][]string) error {
+	copied := make([][]string, len(route))
+	for k, flight := range route {
+		copied[k] = []string{flight[0], flight[1]}
+	}
+	e.routes = append(e.routes, copied)
+	return nil
+}
+
+func (e *memoryWriter) Close() error {
+	e.store.mutex.Lock()
+	defer e.store.mutex.Unlock()
+
+	e.store.results[e.id] = e.routes
+	return nil
+}
+
+// Create returns the writer of the result of the job
+func (e *MemoryStore) Create(id string) (ResultWriter, error) {
+	return &memoryWriter{store: e, id: id, routes: make([][][]string, 0)}, nil
+}
+
+// Read returns up to limit sub routes starting at offset
+func (e *MemoryStore) Read(id string, offset, limit int64) (routes [][][]string, total int64, err error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	result, found := e.results[id]
+	if !found {
+		return nil, 0, ErrResultNotFound
+	}
+
+	total = int64(len(result))
+	if offset >= total {
+		return make([][][]string, 0), total, nil
+	}
+
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+
+	return result[offset:end], total, nil
+}
+
+// Delete removes the result of the job
+func (e *MemoryStore) Delete(id string) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	delete(e.results, id)
+	return nil
+}
@@ -0,0 +1,213 @@
+package proxy
+
+import (
+	"net/url"
+	"sync"
+	"time"
+)
+
+// BackendStatistics usage of a backend, see Proxy.Statistics()
+type BackendStatistics struct {
+	// Url address of the backend
+	Url string `json:"url"`
+
+	// Name name of the backend
+	Name string `json:"name"`
+
+	// TotalTime sum of the response times of the backend
+	TotalTime time.Duration `json:"totalTime"`
+
+	// UsedSuccessfully requests answered without error
+	UsedSuccessfully int64 `json:"usedSuccessfully"`
+
+	// Enabled false while the backend is disabled by consecutive errors
+	Enabled bool `json:"enabled"`
+
+	// ErrorCounter requests that failed
+	ErrorCounter int64 `json:"errorCounter"`
+
+	// ErrorConsecutiveCounter requests that failed since the last success
+	ErrorConsecutiveCounter int64 `json:"errorConsecutiveCounter"`
+
+	// Health healthy or unhealthy, by the active health check. Empty when the route doesn't have health check
+	Health string `json:"health,omitempty"`
+
+	// LastProbe time of the last probe of the health check
+	LastProbe time.Time `json:"lastProbe"`
+
+	// LastProbeError error of the last probe, empty when it passed
+	LastProbeError string `json:"lastProbeError,omitempty"`
+
+	// Circuit closed, open or halfOpen. Empty when the route doesn't have circuit breaker
+	Circuit string `json:"circuit,omitempty"`
+
+	// CircuitTransitions number of changes of the circuit to each state
+	CircuitTransitions map[string]int64 `json:"circuitTransitions,omitempty"`
+}
+
+const (
+	// HealthHealthy the backend passed the health check
+	HealthHealthy = "healthy"
+
+	// HealthUnhealthy the backend failed the health check
+	HealthUnhealthy = "unhealthy"
+)
+
+// backend backend of a route and its statistics. The statistics are shared by the requests of all goroutines and
+// are protected by the mutex
+type backend struct {
+	config  ProxyUrl
+	url     *url.URL
+	breaker *circuitBreaker
+
+	mutex                   sync.Mutex
+	totalTime               time.Duration
+	usedSuccessfully        int64
+	enabled                 bool
+	errorCounter            int64
+	errorConsecutiveCounter int64
+	disabledSince           time.Time
+
+	// health check state, see probed()
+	checked        bool
+	healthy        bool
+	probing        bool
+	nextProbe      time.Time
+	lastProbe      time.Time
+	lastProbeError string
+	probesPassed   int
+	probesFailed   int
+}
+
+// newBackend returns an enabled and healthy backend. checked is true when the route has health check, and breaker is
+// nil when the route doesn't have circuit breaker
+func newBackend(config ProxyUrl, checked bool, breaker *circuitBreaker) (e *backend, err error) {
+	e = &backend{config: config, breaker: breaker, enabled: true, checked: checked, healthy: true}
+	e.url, err = url.Parse(config.Url)
+	return
+}
+
+// available returns true when the backend can receive requests
+func (e *backend) available() bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.enabled && e.healthy && e.breaker.ready(time.Now())
+}
+
+// success records a request answered without error
+func (e *backend) success(elapsed time.Duration) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.errorConsecutiveCounter = 0
+	e.usedSuccessfully += 1
+	e.totalTime += elapsed
+}
+
+// failure records a request that failed and disables the backend after consecutiveErrorsToDisable errors, when it
+// doesn't have circuit breaker
+func (e *backend) failure(now time.Time, consecutiveErrorsToDisable int64) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.errorCounter += 1
+	e.errorConsecutiveCounter += 1
+	if e.breaker == nil && e.enabled && e.errorConsecutiveCounter >= consecutiveErrorsToDisable {
+		e.enabled = false
+		e.disabledSince = now
+		e.probesPassed = 0
+	}
+}
+
+// enable enables the backend when it is disabled for more than timeToKeepDisabled, or always when force is true
+func (e *backend) enable(now time.Time, timeToKeepDisabled time.Duration, force bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if !e.enabled && (force || now.Sub(e.disabledSince) >= timeToKeepDisabled) {
+		e.enabled = true
+		e.errorConsecutiveCounter = 0
+	}
+}
+
+// probe returns true when the backend must be probed, and marks the probe in progress
+func (e *backend) probe(now time.Time) bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.probing || now.Before(e.nextProbe) {
+		return false
+	}
+	e.probing = true
+	return true
+}
+
+// probed records the result of a probe. The backend becomes unhealthy after fall failed probes, and healthy after
+// rise passed probes. A backend disabled by errors is enabled again after rise passed probes since it was disabled
+func (e *backend) probed(now time.Time, err error, config ProxyHealthCheck) (changed bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.probing = false
+	e.lastProbe = now
+	e.nextProbe = now.Add(config.Interval)
+
+	if err != nil {
+		e.lastProbeError = err.Error()
+		e.probesPassed = 0
+		e.probesFailed += 1
+		if e.healthy && e.probesFailed >= config.Fall {
+			e.healthy = false
+			return true
+		}
+		return false
+	}
+
+	e.lastProbeError = ""
+	e.probesFailed = 0
+	e.probesPassed += 1
+	if e.probesPassed < config.Rise {
+		return false
+	}
+
+	if !e.enabled {
+		e.enabled = true
+		e.errorConsecutiveCounter = 0
+		changed = true
+	}
+	if !e.healthy {
+		e.healthy = true
+		changed = true
+	}
+	return
+}
+
+// statistics returns a copy of the statistics
+func (e *backend) statistics() BackendStatistics {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	statistics := BackendStatistics{
+		Url:                     e.config.Url,
+		Name:                    e.config.Name,
+		TotalTime:               e.totalTime,
+		UsedSuccessfully:        e.usedSuccessfully,
+		Enabled:                 e.enabled,
+		ErrorCounter:            e.errorCounter,
+		ErrorConsecutiveCounter: e.errorConsecutiveCounter,
+	}
+
+	if e.checked {
+		statistics.Health = HealthUnhealthy
+		if e.healthy {
+			statistics.Health = HealthHealthy
+		}
+		statistics.LastProbe = e.lastProbe
+		statistics.LastProbeError = e.lastProbeError
+	}
+
+	if e.breaker != nil {
+		statistics.Circuit, statistics.CircuitTransitions = e.breaker.statistics()
+	}
+	return statistics
+}
@@ -0,0 +1,351 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// BalancerRoundRobin each backend in turn
+	BalancerRoundRobin = "roundRobin"
+
+	// BalancerWeightedRoundRobin each backend in turn, in proportion to ProxyUrl.Weight
+	BalancerWeightedRoundRobin = "weightedRoundRobin"
+
+	// BalancerLeastConnections the backend with fewer requests in progress
+	BalancerLeastConnections = "leastConnections"
+
+	// BalancerPowerOfTwoChoices the backend with fewer requests in progress between two random backends
+	BalancerPowerOfTwoChoices = "powerOfTwoChoices"
+
+	// BalancerEWMA the backend with the lower moving average of the response time, weighted by the requests in progress
+	BalancerEWMA = "ewma"
+
+	// BalancerConsistentHash the same backend for the same header, cookie or client ip
+	BalancerConsistentHash = "consistentHash"
+)
+
+const (
+	// HashByHeader consistentHash key taken from the ProxyBalancer.HashKey header
+	HashByHeader = "header"
+
+	// HashByCookie consistentHash key taken from the ProxyBalancer.HashKey cookie
+	HashByCookie = "cookie"
+
+	// HashByIP consistentHash key taken from the client ip
+	HashByIP = "ip"
+)
+
+// ErrAttemptAborted error of Balancer.Done for a request the backend didn't answer: the circuit breaker refused it or
+// the client went away. The elapsed time isn't a measure of the backend
+var ErrAttemptAborted = errors.New("the attempt was aborted before the backend answered")
+
+// Balancer chooses the backend of each request. Implementations are used by concurrent requests.
+// The backends are identified by their index in ProxyRoute.ProxyServers
+type Balancer interface {
+	// Next returns one of the candidates, the enabled backends that didn't fail in this request. candidates isn't
+	// empty and is sorted
+	Next(request *ProxyRequest, candidates []int) int
+
+	// Done records the end of a request sent to the backend returned by Next. It is called for every Next, with
+	// ErrAttemptAborted when the request wasn't answered
+	Done(k int, elapsed time.Duration, err error)
+}
+
+// NewBalancer returns the balancer of the strategy for the backends
+func NewBalancer(config ProxyBalancer, backends []ProxyUrl) (Balancer, error) {
+	switch config.Strategy {
+	case "", BalancerRoundRobin:
+		return &roundRobin{size: uint64(len(backends))}, nil
+	case BalancerWeightedRoundRobin:
+		return newWeightedRoundRobin(backends), nil
+	case BalancerLeastConnections:
+		return &leastConnections{inFlight: make([]int64, len(backends))}, nil
+	case BalancerPowerOfTwoChoices:
+		return &powerOfTwoChoices{inFlight: make([]int64, len(backends)), random: rand.New(rand.NewSource(time.Now().UnixNano()))}, nil
+	case BalancerEWMA:
+		return &ewma{latency: make([]float64, len(backends)), inFlight: make([]int64, len(backends))}, nil
+	case BalancerConsistentHash:
+		return newConsistentHash(config, backends)
+	}
+	return nil, fmt.Errorf("unknown balancer strategy %v", config.Strategy)
+}
+
+// weight returns the weight of the backend, at least 1
+func weight(backend ProxyUrl) int {
+	if backend.Weight < 1 {
+		return 1
+	}
+	return backend.Weight
+}
+
+// roundRobin uses each backend in turn. A backend that isn't a candidate is skipped and the next one is used
+type roundRobin struct {
+	size    uint64
+	counter uint64
+}
+
+func (e *roundRobin) Next(_ *ProxyRequest, candidates []int) int {
+	position := int((atomic.AddUint64(&e.counter, 1) - 1) % e.size)
+
+	// the first candidate from the position, the candidates are sorted
+	k := sort.SearchInts(candidates, position)
+	if k == len(candidates) {
+		k = 0
+	}
+	return candidates[k]
+}
+
+func (e *roundRobin) Done(int, time.Duration, error) {}
+
+// weightedRoundRobin smooth weighted round-robin: the heavier backends are used more, interleaved with the others
+type weightedRoundRobin struct {
+	weights []int
+
+	mutex   sync.Mutex
+	current []int
+}
+
+// newWeightedRoundRobin returns the balancer with the weights of the backends
+func newWeightedRoundRobin(backends []ProxyUrl) *weightedRoundRobin {
+	e := &weightedRoundRobin{weights: make([]int, len(backends)), current: make([]int, len(backends))}
+	for k, backend := range backends {
+		e.weights[k] = weight(backend)
+	}
+	return e
+}
+
+func (e *weightedRoundRobin) Next(_ *ProxyRequest, candidates []int) int {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	total, best := 0, candidates[0]
+	for _, k := range candidates {
+		e.current[k] += e.weights[k]
+		total += e.weights[k]
+		if e.current[k] > e.current[best] {
+			best = k
+		}
+	}
+	e.current[best] -= total
+	return best
+}
+
+func (e *weightedRoundRobin) Done(int, time.Duration, error) {}
+
+// leastConnections uses the backend with fewer requests in progress. Ties are broken in round-robin
+type leastConnections struct {
+	inFlight []int64
+	counter  uint64
+}
+
+func (e *leastConnections) Next(_ *ProxyRequest, candidates []int) int {
+	least, leastInFlight := make([]int, 0, len(candidates)), int64(math.MaxInt64)
+	for _, k := range candidates {
+		inFlight := atomic.LoadInt64(&e.inFlight[k])
+		if inFlight < leastInFlight {
+			least, leastInFlight = least[:0], inFlight
+		}
+		if inFlight == leastInFlight {
+			least = append(least, k)
+		}
+	}
+
+	best := least[int((atomic.AddUint64(&e.counter, 1)-1)%uint64(len(least)))]
+	atomic.AddInt64(&e.inFlight[best], 1)
+	return best
+}
+
+func (e *leastConnections) Done(k int, _ time.Duration, _ error) {
+	atomic.AddInt64(&e.inFlight[k], -1)
+}
+
+// powerOfTwoChoices compares two random backends and uses the one with fewer requests in progress. It is almost as
+// good as leastConnections without having every request go to the same idle backend
+type powerOfTwoChoices struct {
+	inFlight []int64
+
+	mutex  sync.Mutex
+	random *rand.Rand
+}
+
+func (e *powerOfTwoChoices) Next(_ *ProxyRequest, candidates []int) int {
+	best := candidates[0]
+	if len(candidates) > 1 {
+		e.mutex.Lock()
+		i := e.random.Intn(len(candidates))
+		j := e.random.Intn(len(candidates) - 1)
+		e.mutex.Unlock()
+
+		if j >= i {
+			j += 1
+		}
+
+		best = candidates[i]
+		if atomic.LoadInt64(&e.inFlight[candidates[j]]) < atomic.LoadInt64(&e.inFlight[best]) {
+			best = candidates[j]
+		}
+	}
+
+	atomic.AddInt64(&e.inFlight[best], 1)
+	return best
+}
+
+func (e *powerOfTwoChoices) Done(k int, _ time.Duration, _ error) {
+	atomic.AddInt64(&e.inFlight[k], -1)
+}
+
+// ewmaDecay weight of the last response time in the moving average
+const ewmaDecay = 0.3
+
+// ewmaErrorPenalty times the slowest average of the other backends used as the response time of a failed request, so
+// a backend that fails fast doesn't look faster than the healthy ones
+const ewmaErrorPenalty = 10
+
+// ewma uses the backend with the lower moving average of the response time, multiplied by the requests in progress.
+// A backend without response time is used first, to measure it
+type ewma struct {
+	mutex    sync.Mutex
+	latency  []float64
+	inFlight []int64
+}
+
+func (e *ewma) Next(_ *ProxyRequest, candidates []int) int {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	best, bestCost := -1, math.Inf(1)
+	for _, k := range candidates {
+		cost := e.latency[k] * float64(e.inFlight[k]+1)
+		if cost < bestCost {
+			best, bestCost = k, cost
+		}
+	}
+
+	e.inFlight[best] += 1
+	return best
+}
+
+func (e *ewma) Done(k int, elapsed time.Duration, err error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.inFlight[k] -= 1
+	if errors.Is(err, ErrAttemptAborted) {
+		return
+	}
+
+	sample := float64(elapsed)
+	if err != nil {
+		sample = e.penalty(k, sample)
+	}
+
+	if e.latency[k] == 0 {
+		e.latency[k] = sample
+		return
+	}
+	e.latency[k] = e.latency[k]*(1-ewmaDecay) + sample*ewmaDecay
+}
+
+// penalty returns the response time of a failed request of the backend k. The average of k itself isn't used, so the
+// penalty doesn't grow while the backend keeps failing
+func (e *ewma) penalty(k int, elapsed float64) float64 {
+	slowest := elapsed
+	for j, latency := range e.latency {
+		if j != k && latency > slowest {
+			slowest = latency
+		}
+	}
+	return slowest * ewmaErrorPenalty
+}
+
+// consistentHashReplicas points of each unit of weight in the ring
+const consistentHashReplicas = 100
+
+// consistentHash ring of the backends. The key of the request is sent to the first backend of the ring after the hash
+// of the key, so the same key uses the same backend, and only the keys of a backend that leaves move to another one
+type consistentHash struct {
+	config ProxyBalancer
+	hashes []uint32
+	ring   map[uint32]int
+	round  roundRobin
+}
+
+// newConsistentHash returns the ring of the backends
+func newConsistentHash(config ProxyBalancer, backends []ProxyUrl) (*consistentHash, error) {
+	switch config.HashBy {
+	case "":
+		config.HashBy = HashByIP
+	case HashByHeader, HashByCookie:
+		if config.HashKey == "" {
+			return nil, fmt.Errorf("the %v of the consistentHash balancer must have a name in hashKey", config.HashBy)
+		}
+	case HashByIP:
+	default:
+		return nil, fmt.Errorf("unknown consistentHash key %v", config.HashBy)
+	}
+
+	e := &consistentHash{config: config, ring: make(map[uint32]int), round: roundRobin{size: uint64(len(backends))}}
+	for k, backend := range backends {
+		for i := 0; i < weight(backend)*consistentHashReplicas; i += 1 {
+			hash := hashKey(backend.Url + "#" + strconv.Itoa(i))
+			if _, found := e.ring[hash]; !found {
+				e.ring[hash] = k
+				e.hashes = append(e.hashes, hash)
+			}
+		}
+	}
+	sort.Slice(e.hashes, func(i, j int) bool { return e.hashes[i] < e.hashes[j] })
+	return e, nil
+}
+
+// key returns the key of the request, empty when the header or the cookie are missing
+func (e *consistentHash) key(request *ProxyRequest) string {
+	switch e.config.HashBy {
+	case HashByHeader:
+		return request.Header.Get(e.config.HashKey)
+	case HashByCookie:
+		cookie, err := request.Cookie(e.config.HashKey)
+		if err != nil {
+			return ""
+		}
+		return cookie.Value
+	}
+
+	return clientIP(request.Request)
+}
+
+// Next returns the first candidate of the ring after the key. Requests without key are balanced in round-robin
+func (e *consistentHash) Next(request *ProxyRequest, candidates []int) int {
+	key := e.key(request)
+	if key == "" || len(e.hashes) == 0 {
+		return e.round.Next(request, candidates)
+	}
+
+	hash := hashKey(key)
+	start := sort.Search(len(e.hashes), func(i int) bool { return e.hashes[i] >= hash })
+	for i := 0; i < len(e.hashes); i += 1 {
+		k := e.ring[e.hashes[(start+i)%len(e.hashes)]]
+		if position := sort.SearchInts(candidates, k); position < len(candidates) && candidates[position] == k {
+			return k
+		}
+	}
+	return candidates[0]
+}
+
+func (e *consistentHash) Done(int, time.Duration, error) {}
+
+// hashKey returns the position of the key in the ring
+func hashKey(key string) uint32 {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(key))
+	return hash.Sum32()
+}
@@ -0,0 +1,254 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// simulate sends the requests to the balancer and returns how many requests each backend received.
+// Each backend answers in its latency, with an error when it is failing, and keeps the requests in progress for
+// inFlight calls of Next
+func simulate(balancer Balancer, requests int, latency []time.Duration, inFlight []int, failing []bool, newRequest func(i int) *ProxyRequest) []int {
+	counters := make([]int, len(latency))
+	candidates := make([]int, len(latency))
+	for k := range candidates {
+		candidates[k] = k
+	}
+
+	var pending [][]int
+	for i := 0; i < requests; i += 1 {
+		k := balancer.Next(newRequest(i), candidates)
+		counters[k] += 1
+
+		// the request ends after inFlight other requests
+		pending = append(pending, []int{k, inFlight[k]})
+		for j := 0; j < len(pending); {
+			if pending[j][1] > 0 {
+				pending[j][1] -= 1
+				j += 1
+				continue
+			}
+			var err error
+			if k := pending[j][0]; k < len(failing) && failing[k] {
+				err = errBackendFailed
+			}
+			balancer.Done(pending[j][0], latency[pending[j][0]], err)
+			pending = append(pending[:j], pending[j+1:]...)
+		}
+	}
+	return counters
+}
+
+// errBackendFailed error of the requests of a failing backend
+var errBackendFailed = errors.New("backend failed")
+
+// newClientRequest returns a request of the client ip
+func newClientRequest(i int) *ProxyRequest {
+	r := httptest.NewRequest(http.MethodGet, "/calculate", nil)
+	r.RemoteAddr = fmt.Sprintf("10.0.%v.%v:5000", i/256, i%256)
+	return &ProxyRequest{Request: r}
+}
+
+func TestNewBalancer(t *testing.T) {
+	backends := []ProxyUrl{{Url: "http://localhost:8081"}, {Url: "http://localhost:8082"}}
+
+	tests := []struct {
+		config ProxyBalancer
+		err    bool
+	}{
+		{config: ProxyBalancer{}},
+		{config: ProxyBalancer{Strategy: BalancerEWMA}},
+		{config: ProxyBalancer{Strategy: BalancerConsistentHash}},
+		{config: ProxyBalancer{Strategy: BalancerConsistentHash, HashBy: HashByCookie, HashKey: "session"}},
+		{config: ProxyBalancer{Strategy: BalancerConsistentHash, HashBy: HashByHeader}, err: true},
+		{config: ProxyBalancer{Strategy: BalancerConsistentHash, HashBy: "path"}, err: true},
+		{config: ProxyBalancer{Strategy: "random"}, err: true},
+	}
+
+	for _, test := range tests {
+		if _, err := NewBalancer(test.config, backends); (err != nil) != test.err {
+			t.Logf("%+v: NewBalancer().error: %v", test.config, err)
+			t.FailNow()
+		}
+	}
+}
+
+func TestBalancer_Distribution(t *testing.T) {
+	fast, slow := 10*time.Millisecond, 100*time.Millisecond
+
+	tests := []struct {
+		name     string
+		config   ProxyBalancer
+		backends []ProxyUrl
+		latency  []time.Duration
+		inFlight []int
+		failing  []bool
+
+		// want minimum and maximum share of the requests of each backend, in percent
+		min []int
+		max []int
+	}{
+		{
+			name:     "round-robin uses each backend the same",
+			config:   ProxyBalancer{Strategy: BalancerRoundRobin},
+			backends: []ProxyUrl{{Url: "a"}, {Url: "b"}, {Url: "c"}},
+			latency:  []time.Duration{fast, slow, slow},
+			inFlight: []int{0, 5, 5},
+			min:      []int{33, 33, 33},
+			max:      []int{34, 34, 34},
+		},
+		{
+			name:     "weighted round-robin follows the weights",
+			config:   ProxyBalancer{Strategy: BalancerWeightedRoundRobin},
+			backends: []ProxyUrl{{Url: "a", Weight: 1}, {Url: "b", Weight: 2}, {Url: "c", Weight: 7}},
+			latency:  []time.Duration{fast, fast, fast},
+			inFlight: []int{0, 0, 0},
+			min:      []int{10, 20, 70},
+			max:      []int{10, 20, 70},
+		},
+		{
+			name:     "least connections avoids the busy backend",
+			config:   ProxyBalancer{Strategy: BalancerLeastConnections},
+			backends: []ProxyUrl{{Url: "a"}, {Url: "b"}, {Url: "c"}},
+			latency:  []time.Duration{fast, fast, fast},
+			inFlight: []int{0, 0, 10},
+			min:      []int{40, 40, 0},
+			max:      []int{50, 50, 10},
+		},
+		{
+			name:     "power of two choices avoids the busy backend",
+			config:   ProxyBalancer{Strategy: BalancerPowerOfTwoChoices},
+			backends: []ProxyUrl{{Url: "a"}, {Url: "b"}, {Url: "c"}},
+			latency:  []time.Duration{fast, fast, fast},
+			inFlight: []int{0, 0, 10},
+			min:      []int{35, 35, 0},
+			max:      []int{60, 60, 20},
+		},
+		{
+			name:     "ewma prefers the fast backend",
+			config:   ProxyBalancer{Strategy: BalancerEWMA},
+			backends: []ProxyUrl{{Url: "a"}, {Url: "b"}, {Url: "c"}},
+			latency:  []time.Duration{fast, slow, 10 * slow},
+			inFlight: []int{1, 1, 1},
+			min:      []int{80, 0, 0},
+			max:      []int{100, 20, 5},
+		},
+		{
+			name:     "ewma avoids the backend that fails fast",
+			config:   ProxyBalancer{Strategy: BalancerEWMA},
+			backends: []ProxyUrl{{Url: "a"}, {Url: "b"}, {Url: "c"}},
+			latency:  []time.Duration{time.Millisecond, fast, fast},
+			inFlight: []int{1, 1, 1},
+			failing:  []bool{true, false, false},
+			min:      []int{0, 45, 45},
+			max:      []int{5, 55, 55},
+		},
+		{
+			name:     "consistent hash spreads the clients",
+			config:   ProxyBalancer{Strategy: BalancerConsistentHash},
+			backends: []ProxyUrl{{Url: "a"}, {Url: "b"}, {Url: "c"}},
+			latency:  []time.Duration{fast, fast, fast},
+			inFlight: []int{0, 0, 0},
+			min:      []int{20, 20, 20},
+			max:      []int{47, 47, 47},
+		},
+	}
+
+	const requests = 1000
+	for _, test := range tests {
+		balancer, err := NewBalancer(test.config, test.backends)
+		if err != nil {
+			t.Logf("%v: NewBalancer().error: %v", test.name, err)
+			t.FailNow()
+		}
+
+		counters := simulate(balancer, requests, test.latency, test.inFlight, test.failing, newClientRequest)
+		for k, counter := range counters {
+			if share := counter * 100 / requests; share < test.min[k] || share > test.max[k] {
+				t.Logf("%v: backend %v received %v%% of the requests, want %v%% to %v%%: %v", test.name, k, share, test.min[k], test.max[k], counters)
+				t.FailNow()
+			}
+		}
+	}
+}
+
+func TestBalancer_Candidates(t *testing.T) {
+	backends := []ProxyUrl{{Url: "a"}, {Url: "b"}, {Url: "c"}}
+
+	// only the candidates are used
+	for _, strategy := range []string{BalancerRoundRobin, BalancerWeightedRoundRobin, BalancerLeastConnections, BalancerPowerOfTwoChoices, BalancerEWMA, BalancerConsistentHash} {
+		balancer, _ := NewBalancer(ProxyBalancer{Strategy: strategy}, backends)
+		for i := 0; i < 100; i += 1 {
+			if k := balancer.Next(newClientRequest(i), []int{0, 2}); k != 0 && k != 2 {
+				t.Logf("%v: Next() returned %v, that isn't a candidate", strategy, k)
+				t.FailNow()
+			}
+			balancer.Done(0, time.Millisecond, nil)
+			balancer.Done(2, time.Millisecond, nil)
+		}
+	}
+}
+
+func TestBalancer_Aborted(t *testing.T) {
+	backends := []ProxyUrl{{Url: "a"}, {Url: "b"}}
+
+	// the aborted requests release the backends and aren't measured
+	for _, strategy := range []string{BalancerLeastConnections, BalancerPowerOfTwoChoices, BalancerEWMA} {
+		balancer, _ := NewBalancer(ProxyBalancer{Strategy: strategy}, backends)
+		for i := 0; i < 10; i += 1 {
+			balancer.Done(balancer.Next(newClientRequest(i), []int{0, 1}), 0, ErrAttemptAborted)
+		}
+
+		var inFlight []int64
+		switch balancer := balancer.(type) {
+		case *leastConnections:
+			inFlight = balancer.inFlight
+		case *powerOfTwoChoices:
+			inFlight = balancer.inFlight
+		case *ewma:
+			inFlight = balancer.inFlight
+			if balancer.latency[0] != 0 || balancer.latency[1] != 0 {
+				t.Logf("%v: the aborted requests must not be measured: %v", strategy, balancer.latency)
+				t.FailNow()
+			}
+		}
+
+		if inFlight[0] != 0 || inFlight[1] != 0 {
+			t.Logf("%v: the aborted requests must release the backends: %v", strategy, inFlight)
+			t.FailNow()
+		}
+	}
+}
+
+func TestConsistentHash_Next(t *testing.T) {
+	backends := []ProxyUrl{{Url: "a"}, {Url: "b"}, {Url: "c"}}
+	balancer, _ := NewBalancer(ProxyBalancer{Strategy: BalancerConsistentHash, HashBy: HashByHeader, HashKey: "X-User"}, backends)
+
+	newRequest := func(user string) *ProxyRequest {
+		r := httptest.NewRequest(http.MethodGet, "/calculate", nil)
+		r.Header.Set("X-User", user)
+		return &ProxyRequest{Request: r}
+	}
+
+	all, withoutB := []int{0, 1, 2}, []int{0, 2}
+	for i := 0; i < 500; i += 1 {
+		user := fmt.Sprintf("user%v", i)
+		k := balancer.Next(newRequest(user), all)
+
+		// the same key uses the same backend
+		if again := balancer.Next(newRequest(user), all); again != k {
+			t.Logf("%v: backend %v and %v", user, k, again)
+			t.FailNow()
+		}
+
+		// only the keys of the backend that left move
+		if moved := balancer.Next(newRequest(user), withoutB); k != 1 && moved != k {
+			t.Logf("%v: moved from %v to %v", user, k, moved)
+			t.FailNow()
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+)
+
+// bufferedBody request body kept in memory or in a temp file, so it can be sent again to another backend
+type bufferedBody struct {
+	memory []byte
+	file   *os.File
+	size   int64
+
+	// rest [optional] part of the body bigger than the buffer, streamed to the first backend only
+	rest io.Reader
+}
+
+// bufferBody reads the body of the request. Bodies up to memoryLimit are kept in memory and bigger ones in a temp
+// file of dir. Bodies bigger than maxBuffer aren't read until the end, the rest is streamed to the first backend and
+// the body isn't replayable
+func bufferBody(r *http.Request, memoryLimit, maxBuffer int64, dir string) (body *bufferedBody, err error) {
+	body = &bufferedBody{}
+	if r.Body == nil || r.Body == http.NoBody {
+		return
+	}
+
+	if memoryLimit > maxBuffer {
+		memoryLimit = maxBuffer
+	}
+
+	var buffer bytes.Buffer
+	n, err := io.CopyN(&buffer, r.Body, memoryLimit+1)
+	if err == io.EOF {
+		body.memory, body.size = buffer.Bytes(), n
+		return body, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// the body is bigger than the buffer, the read part is sent before the rest
+	if n > maxBuffer {
+		body.memory, body.size, body.rest = buffer.Bytes(), n, r.Body
+		return body, nil
+	}
+
+	if body.file, err = os.CreateTemp(dir, "proxy-body-*"); err != nil {
+		return nil, err
+	}
+
+	if _, err = body.file.Write(buffer.Bytes()); err != nil {
+		_ = body.Close()
+		return nil, err
+	}
+
+	n, err = io.CopyN(body.file, r.Body, maxBuffer-n+1)
+	body.size = int64(buffer.Len()) + n
+	if err == io.EOF {
+		return body, nil
+	}
+	if err != nil {
+		_ = body.Close()
+		return nil, err
+	}
+
+	body.rest = r.Body
+	return body, nil
+}
+
+// Replayable returns true when the whole body is buffered and can be sent again
+func (e *bufferedBody) Replayable() bool {
+	return e.rest == nil
+}
+
+// Reader returns the body from the beginning. A body that isn't replayable can be read only once
+func (e *bufferedBody) Reader() io.ReadCloser {
+	if e.size == 0 && e.rest == nil {
+		return http.NoBody
+	}
+
+	var reader io.Reader = bytes.NewReader(e.memory)
+	if e.file != nil {
+		reader = io.NewSectionReader(e.file, 0, e.size)
+	}
+
+	if e.rest != nil {
+		reader = io.MultiReader(reader, e.rest)
+		e.rest = eofReader{}
+	}
+
+	return io.NopCloser(reader)
+}
+
+// Close removes the temp file
+func (e *bufferedBody) Close() error {
+	if e.file == nil {
+		return nil
+	}
+
+	err := e.file.Close()
+	if removeErr := os.Remove(e.file.Name()); err == nil {
+		err = removeErr
+	}
+	return err
+}
+
+// eofReader empty body of a request that was already streamed
+type eofReader struct{}
+
+func (eofReader) Read([]byte) (int, error) {
+	return 0, io.EOF
+}
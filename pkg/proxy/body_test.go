@@ -0,0 +1,67 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestBufferBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		memoryLimit int64
+		maxBuffer   int64
+		file        bool
+		replayable  bool
+	}{
+		{name: "empty", body: "", memoryLimit: 10, maxBuffer: 100, replayable: true},
+		{name: "memory", body: "0123456789", memoryLimit: 10, maxBuffer: 100, replayable: true},
+		{name: "temp file", body: strings.Repeat("0123456789", 10), memoryLimit: 10, maxBuffer: 100, file: true, replayable: true},
+		{name: "too big", body: strings.Repeat("0123456789", 11), memoryLimit: 10, maxBuffer: 100, file: true},
+		{name: "too big for memory", body: "0123456789", memoryLimit: 100, maxBuffer: 5},
+	}
+
+	for _, test := range tests {
+		dir := t.TempDir()
+		r := httptest.NewRequest(http.MethodPost, "/calculate", strings.NewReader(test.body))
+
+		body, err := bufferBody(r, test.memoryLimit, test.maxBuffer, dir)
+		if err != nil {
+			t.Logf("%v: bufferBody().error: %v", test.name, err)
+			t.FailNow()
+		}
+
+		if body.Replayable() != test.replayable || (body.file != nil) != test.file {
+			t.Logf("%v: replayable %v, file %v", test.name, body.Replayable(), body.file != nil)
+			t.FailNow()
+		}
+
+		// a replayable body is read again from the beginning, otherwise only once
+		for i := 0; i < 2; i += 1 {
+			data, err := io.ReadAll(body.Reader())
+			if err != nil {
+				t.Logf("%v: Reader().error: %v", test.name, err)
+				t.FailNow()
+			}
+
+			if want := test.body; string(data) != want && (i == 0 || test.replayable) {
+				t.Logf("%v: read %v: %q, want %q", test.name, i, data, want)
+				t.FailNow()
+			}
+		}
+
+		if err = body.Close(); err != nil {
+			t.Logf("%v: Close().error: %v", test.name, err)
+			t.FailNow()
+		}
+
+		if files, _ := os.ReadDir(dir); len(files) != 0 {
+			t.Logf("%v: the temp file must be removed", test.name)
+			t.FailNow()
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package proxy
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// CircuitClosed the backend receives every request
+	CircuitClosed = "closed"
+
+	// CircuitOpen the backend doesn't receive requests until ProxyCircuitBreaker.OpenTime passes
+	CircuitOpen = "open"
+
+	// CircuitHalfOpen the backend receives up to ProxyCircuitBreaker.HalfOpenRequests probe requests
+	CircuitHalfOpen = "halfOpen"
+)
+
+// circuitBuckets buckets of the sliding window
+const circuitBuckets = 10
+
+// CircuitEvent change of the state of the circuit breaker of a backend, see ProxyConfig.OnCircuitChange
+type CircuitEvent struct {
+	// Route name of the route
+	Route string `json:"route"`
+
+	// Backend name of the backend
+	Backend string `json:"backend"`
+
+	// From previous state
+	From string `json:"from"`
+
+	// To new state
+	To string `json:"to"`
+
+	// Time time of the change
+	Time time.Time `json:"time"`
+
+	// ErrorRate share of failed requests in the window when the circuit opened
+	ErrorRate float64 `json:"errorRate"`
+
+	// SlowRate share of slow requests in the window when the circuit opened
+	SlowRate float64 `json:"slowRate"`
+}
+
+// circuitBucket requests of a part of the sliding window
+type circuitBucket struct {
+	id       int64
+	requests int64
+	failures int64
+	slow     int64
+}
+
+// circuitBreaker circuit breaker of a backend. The circuit opens when the error rate or the share of slow requests of
+// the sliding window reach the thresholds, stays open for OpenTime, and then lets HalfOpenRequests probe requests
+// through. The circuit closes when all of them succeed, and opens again when one of them fails
+type circuitBreaker struct {
+	config   ProxyCircuitBreaker
+	route    string
+	backend  string
+	onChange func(CircuitEvent)
+
+	mutex             sync.Mutex
+	state             string
+	openedAt          time.Time
+	buckets           [circuitBuckets]circuitBucket
+	halfOpenInFlight  int
+	halfOpenSucceeded int
+	transitions       map[string]int64
+}
+
+// newCircuitBreaker returns the closed circuit breaker of the backend, or nil when the route doesn't have one
+func newCircuitBreaker(config ProxyCircuitBreaker, route, backend string, onChange func(CircuitEvent)) *circuitBreaker {
+	if !config.enabled() {
+		return nil
+	}
+
+	return &circuitBreaker{
+		config:      config,
+		route:       route,
+		backend:     backend,
+		onChange:    onChange,
+		state:       CircuitClosed,
+		transitions: make(map[string]int64),
+	}
+}
+
+// bucket returns the bucket of the time, cleared when it belongs to an older part of the window
+func (e *circuitBreaker) bucket(now time.Time) *circuitBucket {
+	id := now.UnixNano() / int64(e.config.Window/circuitBuckets)
+	bucket := &e.buckets[id%circuitBuckets]
+	if bucket.id != id {
+		*bucket = circuitBucket{id: id}
+	}
+	return bucket
+}
+
+// rates returns the requests of the window and the shares of failed and slow requests
+func (e *circuitBreaker) rates(now time.Time) (requests int64, errorRate, slowRate float64) {
+	id := now.UnixNano() / int64(e.config.Window/circuitBuckets)
+
+	var failures, slow int64
+	for _, bucket := range e.buckets {
+		if id-bucket.id < circuitBuckets {
+			requests += bucket.requests
+			failures += bucket.failures
+			slow += bucket.slow
+		}
+	}
+
+	if requests == 0 {
+		return
+	}
+	return requests, float64(failures) / float64(requests), float64(slow) / float64(requests)
+}
+
+// transition changes the state. Must be called with the mutex locked, the event is sent by the caller after unlocking
+func (e *circuitBreaker) transition(now time.Time, to string, errorRate, slowRate float64) *CircuitEvent {
+	event := &CircuitEvent{Route: e.route, Backend: e.backend, From: e.state, To: to, Time: now, ErrorRate: errorRate, SlowRate: slowRate}
+
+	e.state = to
+	e.transitions[to] += 1
+	e.halfOpenInFlight, e.halfOpenSucceeded = 0, 0
+
+	switch to {
+	case CircuitOpen:
+		e.openedAt = now
+	case CircuitClosed:
+		e.buckets = [circuitBuckets]circuitBucket{}
+	}
+	return event
+}
+
+// emit logs the event and sends it to onChange
+func (e *circuitBreaker) emit(event *CircuitEvent) {
+	if event == nil {
+		return
+	}
+
+	log.Printf("proxy.circuitBreaker().route %v: backend %v: %v -> %v, error rate %.2f, slow rate %.2f", event.Route, event.Backend, event.From, event.To, event.ErrorRate, event.SlowRate)
+	if e.onChange != nil {
+		e.onChange(*event)
+	}
+}
+
+// ready returns true when allow() would let a request through. It doesn't change the state
+func (e *circuitBreaker) ready(now time.Time) bool {
+	if e == nil {
+		return true
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	switch e.state {
+	case CircuitOpen:
+		return now.Sub(e.openedAt) >= e.config.OpenTime && e.config.HalfOpenRequests > 0
+	case CircuitHalfOpen:
+		return e.halfOpenInFlight < e.config.HalfOpenRequests
+	}
+	return true
+}
+
+// allow returns true when the request can be sent to the backend. probe is true for the requests of the half-open
+// state, that must be informed to done()
+func (e *circuitBreaker) allow(now time.Time) (allowed, probe bool) {
+	if e == nil {
+		return true, false
+	}
+
+	var event *CircuitEvent
+	defer func() { e.emit(event) }()
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.state == CircuitOpen {
+		if now.Sub(e.openedAt) < e.config.OpenTime {
+			return false, false
+		}
+		event = e.transition(now, CircuitHalfOpen, 0, 0)
+	}
+
+	if e.state == CircuitHalfOpen {
+		if e.halfOpenInFlight >= e.config.HalfOpenRequests {
+			return false, false
+		}
+		e.halfOpenInFlight += 1
+		return true, true
+	}
+
+	return true, false
+}
+
+// done records the result of a request allowed by allow()
+func (e *circuitBreaker) done(now time.Time, elapsed time.Duration, failed, probe bool) {
+	if e == nil {
+		return
+	}
+
+	var event *CircuitEvent
+	defer func() { e.emit(event) }()
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	slow := e.config.SlowThreshold > 0 && elapsed >= e.config.SlowThreshold
+
+	switch e.state {
+	case CircuitHalfOpen:
+		if !probe {
+			return
+		}
+
+		if failed || slow {
+			errorRate, slowRate := 0.0, 0.0
+			if failed {
+				errorRate = 1
+			} else {
+				slowRate = 1
+			}
+			event = e.transition(now, CircuitOpen, errorRate, slowRate)
+			return
+		}
+
+		e.halfOpenInFlight -= 1
+		e.halfOpenSucceeded += 1
+		if e.halfOpenSucceeded >= e.config.HalfOpenRequests {
+			event = e.transition(now, CircuitClosed, 0, 0)
+		}
+
+	case CircuitClosed:
+		bucket := e.bucket(now)
+		bucket.requests += 1
+		if failed {
+			bucket.failures += 1
+		}
+		if slow {
+			bucket.slow += 1
+		}
+
+		requests, errorRate, slowRate := e.rates(now)
+		if requests < e.config.MinRequests {
+			return
+		}
+
+		if (e.config.ErrorRate > 0 && errorRate >= e.config.ErrorRate) || (e.config.SlowThreshold > 0 && slowRate >= e.config.SlowRate) {
+			event = e.transition(now, CircuitOpen, errorRate, slowRate)
+		}
+	}
+}
+
+// release frees the half-open slot of a request allowed by allow() that wasn't answered, without recording a result
+func (e *circuitBreaker) release(probe bool) {
+	if e == nil || !probe {
+		return
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.state == CircuitHalfOpen && e.halfOpenInFlight > 0 {
+		e.halfOpenInFlight -= 1
+	}
+}
+
+// statistics returns the state and the number of transitions to each state
+func (e *circuitBreaker) statistics() (state string, transitions map[string]int64) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	transitions = make(map[string]int64, len(e.transitions))
+	for to, counter := range e.transitions {
+		transitions[to] = counter
+	}
+	return e.state, transitions
+}
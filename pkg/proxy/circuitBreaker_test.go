@@ -0,0 +1,182 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var events []CircuitEvent
+	config := ProxyCircuitBreaker{ErrorRate: 0.5, SlowThreshold: time.Second, Window: 10 * time.Second, MinRequests: 4, OpenTime: 5 * time.Second, HalfOpenRequests: 2}
+	config.prepare()
+	breaker := newCircuitBreaker(config, "flights", "backend", func(event CircuitEvent) { events = append(events, event) })
+
+	now := time.Unix(1000, 0)
+	fast := 10 * time.Millisecond
+
+	type step struct {
+		name    string
+		advance time.Duration
+
+		// allow when true, release when the probe was abandoned, done otherwise
+		allow   bool
+		release bool
+		allowed bool
+		elapsed time.Duration
+		failed  bool
+		state   string
+	}
+
+	steps := []step{
+		{name: "success", elapsed: fast, state: CircuitClosed},
+		{name: "error under min requests", failed: true, state: CircuitClosed},
+		{name: "error under min requests", failed: true, state: CircuitClosed},
+		{name: "old requests leave the window", advance: 11 * time.Second, elapsed: fast, state: CircuitClosed},
+		{name: "success", elapsed: fast, state: CircuitClosed},
+		{name: "error", failed: true, state: CircuitClosed},
+		{name: "error rate reached", failed: true, state: CircuitOpen},
+		{name: "open", allow: true, allowed: false, state: CircuitOpen},
+		{name: "half-open after open time", advance: 5 * time.Second, allow: true, allowed: true, state: CircuitHalfOpen},
+		{name: "second probe", allow: true, allowed: true, state: CircuitHalfOpen},
+		{name: "probes limited", allow: true, allowed: false, state: CircuitHalfOpen},
+		{name: "abandoned probe", release: true, state: CircuitHalfOpen},
+		{name: "probe in its place", allow: true, allowed: true, state: CircuitHalfOpen},
+		{name: "slow probe opens again", elapsed: 2 * time.Second, state: CircuitOpen},
+		{name: "half-open again", advance: 5 * time.Second, allow: true, allowed: true, state: CircuitHalfOpen},
+		{name: "second probe", allow: true, allowed: true, state: CircuitHalfOpen},
+		{name: "first probe succeeded", elapsed: fast, state: CircuitHalfOpen},
+		{name: "all probes succeeded", elapsed: fast, state: CircuitClosed},
+		{name: "slow", elapsed: 2 * time.Second, state: CircuitClosed},
+		{name: "slow", elapsed: 2 * time.Second, state: CircuitClosed},
+		{name: "success", elapsed: fast, state: CircuitClosed},
+		{name: "slow rate reached", elapsed: 2 * time.Second, state: CircuitOpen},
+	}
+
+	for k, step := range steps {
+		now = now.Add(step.advance)
+		if step.allow {
+			if allowed, probe := breaker.allow(now); allowed != step.allowed || probe != (allowed && step.state == CircuitHalfOpen) {
+				t.Logf("step %v %v: allowed %v, probe %v", k, step.name, allowed, probe)
+				t.FailNow()
+			}
+		} else if step.release {
+			breaker.release(true)
+		} else {
+			// the requests sent while half-open are probes
+			breaker.done(now, step.elapsed, step.failed, breaker.state == CircuitHalfOpen)
+		}
+
+		if state, _ := breaker.statistics(); state != step.state {
+			t.Logf("step %v %v: state %v, want %v", k, step.name, state, step.state)
+			t.FailNow()
+		}
+	}
+
+	// every transition is sent as an event and counted
+	transitions := []string{CircuitOpen, CircuitHalfOpen, CircuitOpen, CircuitHalfOpen, CircuitClosed, CircuitOpen}
+	if len(events) != len(transitions) {
+		t.Logf("events: %+v", events)
+		t.FailNow()
+	}
+	for k, to := range transitions {
+		if events[k].To != to || events[k].Route != "flights" || events[k].Backend != "backend" || (k > 0 && events[k].From != events[k-1].To) {
+			t.Logf("event %v: %+v, want to %v", k, events[k], to)
+			t.FailNow()
+		}
+	}
+
+	if _, counters := breaker.statistics(); counters[CircuitOpen] != 3 || counters[CircuitHalfOpen] != 2 || counters[CircuitClosed] != 1 {
+		t.Logf("transitions: %v", counters)
+		t.FailNow()
+	}
+}
+
+func TestProxy_CircuitBreaker(t *testing.T) {
+	var failing sync.Mutex
+	broken := true
+	unstable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		failing.Lock()
+		defer failing.Unlock()
+
+		if broken {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			_ = conn.Close()
+			return
+		}
+		_, _ = w.Write([]byte("unstable"))
+	}))
+	defer unstable.Close()
+
+	backend := newBackendServer("backend")
+	defer backend.Close()
+
+	var mutex sync.Mutex
+	var events []CircuitEvent
+	proxy, err := New(ProxyConfig{
+		ConsecutiveErrorsToDisable: 1,
+		OnCircuitChange: func(event CircuitEvent) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			events = append(events, event)
+		},
+		Routes: []ProxyRoute{{
+			Name:           "flights",
+			ProxyEnable:    true,
+			ProxyServers:   []ProxyUrl{{Name: "unstable", Url: unstable.URL}, {Name: "backend", Url: backend.URL}},
+			CircuitBreaker: ProxyCircuitBreaker{ErrorRate: 0.5, MinRequests: 2, OpenTime: 50 * time.Millisecond, HalfOpenRequests: 1},
+		}},
+	})
+	if err != nil {
+		t.Logf("New().error: %v", err)
+		t.FailNow()
+	}
+
+	send := func() string {
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/route", nil))
+		return w.Body.String()
+	}
+
+	// the circuit opens after two errors, the backend isn't disabled by ConsecutiveErrorsToDisable
+	for i := 0; i < 4; i += 1 {
+		send()
+	}
+	statistics := proxy.Statistics()[0].ProxyServers[0]
+	if statistics.Circuit != CircuitOpen || !statistics.Enabled || statistics.ErrorCounter != 2 {
+		t.Logf("the circuit must be open: %+v", statistics)
+		t.FailNow()
+	}
+
+	// the open circuit doesn't receive requests
+	for i := 0; i < 4; i += 1 {
+		send()
+	}
+	if statistics = proxy.Statistics()[0].ProxyServers[0]; statistics.ErrorCounter != 2 {
+		t.Logf("the open circuit received requests: %+v", statistics)
+		t.FailNow()
+	}
+
+	// the probe of the half-open circuit succeeds and the circuit closes
+	failing.Lock()
+	broken = false
+	failing.Unlock()
+	time.Sleep(60 * time.Millisecond)
+	for i := 0; i < 4; i += 1 {
+		send()
+	}
+
+	if statistics = proxy.Statistics()[0].ProxyServers[0]; statistics.Circuit != CircuitClosed || statistics.UsedSuccessfully == 0 {
+		t.Logf("the circuit must be closed: %+v", statistics)
+		t.FailNow()
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(events) != 3 || events[0].To != CircuitOpen || events[1].To != CircuitHalfOpen || events[2].To != CircuitClosed {
+		t.Logf("events: %+v", events)
+		t.FailNow()
+	}
+}
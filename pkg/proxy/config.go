@@ -0,0 +1,439 @@
+package proxy
+
+import (
+	"net/http"
+	"time"
+)
+
+// ProxyHandlerFunc function of the local handlers, the error pages and the admin endpoints of the proxy
+type ProxyHandlerFunc func(ProxyResponseWriter, *ProxyRequest)
+
+// ProxyResponseWriter response of the proxy
+type ProxyResponseWriter struct {
+	http.ResponseWriter
+}
+
+// ProxyRequest request received by the proxy, with the parts of the host and the captures of the path
+type ProxyRequest struct {
+	*http.Request
+	QueryString   map[string][]string
+	ExpRegMatches map[string]string
+
+	// Replayable true when the whole body is buffered and the request can be sent again to another backend
+	Replayable bool
+
+	// RequestID X-Request-Id of the request, or a random id, filled in when a rewrite template uses {requestId}
+	RequestID string
+
+	SubDomain string
+	Domain    string
+	Port      string
+	Path      string
+}
+
+// ProxyUrl backend of a route
+type ProxyUrl struct {
+	// Url address of the backend. Ex.: http://localhost:8081
+	Url string `json:"url"`
+
+	// Name name of the backend, used in the statistics
+	Name string `json:"name"`
+
+	// Weight [optional] share of the requests in the weightedRoundRobin and consistentHash balancers. Default 1
+	Weight int `json:"weight"`
+}
+
+// ProxyBalancer strategy used to choose the backend of each request
+type ProxyBalancer struct {
+	// Strategy [optional] roundRobin, weightedRoundRobin, leastConnections, powerOfTwoChoices, ewma or
+	// consistentHash. Default roundRobin
+	Strategy string `json:"strategy"`
+
+	// HashBy [optional] key of the consistentHash balancer: header, cookie or ip. Default ip
+	HashBy string `json:"hashBy"`
+
+	// HashKey name of the header or of the cookie used by the consistentHash balancer
+	HashKey string `json:"hashKey"`
+}
+
+// ProxyDomain host of a route. The host is Host, HostExpReg, or SubDomain and Domain joined, with an optional Port.
+// A domain without host matches every host
+type ProxyDomain struct {
+	// ErrorHandle [optional] error page of the domain, used when every backend fails
+	ErrorHandle ProxyHandlerFunc `json:"-"`
+
+	// NotFoundHandle [optional] not found page of the domain, used when no route of the host matches
+	NotFoundHandle ProxyHandlerFunc `json:"-"`
+
+	// Host [optional] exact host, or wildcard host that matches every sub domain, with an optional port.
+	// Ex.: api.example.com, *.example.com or example.com:8080
+	Host string `json:"host"`
+
+	// HostExpReg [optional] regular expression of the host without port. The named groups are sent in
+	// ProxyRequest.ExpRegMatches. Ex.: ^(?P<tenant>[a-z]+)\.example\.com$
+	HostExpReg string `json:"hostExpReg"`
+
+	// SubDomain [optional] sub domain without the final dot. Ex.: blog for blog.example.com
+	SubDomain string `json:"subDomain"`
+
+	// Domain [optional] domain of the route. Ex.: example.com
+	Domain string `json:"domain"`
+
+	// Port [optional] port without the ':'. Ex.: 8080
+	Port string `json:"port"`
+}
+
+// ProxyPath path of a route. The path is ExpReg, Path or Prefix, in this order. Without them the route matches every
+// path
+type ProxyPath struct {
+	// Path [optional] exact path of the route. Segments like {id} match any segment and are sent in
+	// ProxyRequest.ExpRegMatches. Ex.: /v1/jobs/{id}
+	Path string `json:"path"`
+
+	// Prefix [optional] path prefix of the route, matched by whole segments. Ex.: /v1 matches /v1 and /v1/calculate,
+	// but not /v10
+	Prefix string `json:"prefix"`
+
+	// Method [optional] method of the route. Empty matches every method
+	Method string `json:"method"`
+
+	// ExpReg [optional] regular expression of the path. The named groups are sent in ProxyRequest.ExpRegMatches
+	ExpReg string `json:"expReg"`
+
+	// Headers [optional] regular expressions of the values of the headers that the request must have.
+	// Ex.: {"Content-Type": "^application/json"}
+	Headers map[string]string `json:"headers"`
+}
+
+// ProxyHandle local handler of a route, used when the route isn't proxied
+type ProxyHandle struct {
+	// Name name of the handler, used in the statistics
+	Name string `json:"name"`
+
+	// Handle function served by the route
+	Handle ProxyHandlerFunc `json:"-"`
+}
+
+// ProxyHealthCheck active health check of the backends of a route. A backend that fails Fall probes stops receiving
+// requests until it passes Rise probes, and a backend disabled by errors is enabled again only after Rise probes
+type ProxyHealthCheck struct {
+	// Path path of the probe, with an optional query. Empty disables the health check. Ex.: /health
+	Path string `json:"path"`
+
+	// Method [optional] method of the probe. Default GET
+	Method string `json:"method"`
+
+	// ExpectedStatus [optional] status codes of a healthy backend. Default any 2xx
+	ExpectedStatus []int `json:"expectedStatus"`
+
+	// ExpectedBody [optional] text that the body of a healthy backend contains
+	ExpectedBody string `json:"expectedBody"`
+
+	// Interval [optional] time between the probes of each backend. Default 10s
+	Interval time.Duration `json:"interval"`
+
+	// Timeout [optional] time to receive the response of the probe. Default 2s
+	Timeout time.Duration `json:"timeout"`
+
+	// Rise [optional] consecutive passed probes that make a backend healthy. Default 2
+	Rise int `json:"rise"`
+
+	// Fall [optional] consecutive failed probes that make a backend unhealthy. Default 3
+	Fall int `json:"fall"`
+}
+
+// prepare fills in the default values
+func (e *ProxyHealthCheck) prepare() {
+	if e.Method == "" {
+		e.Method = http.MethodGet
+	}
+
+	if e.Interval == 0 {
+		e.Interval = 10 * time.Second
+	}
+
+	if e.Timeout == 0 {
+		e.Timeout = 2 * time.Second
+	}
+
+	if e.Rise == 0 {
+		e.Rise = 2
+	}
+
+	if e.Fall == 0 {
+		e.Fall = 3
+	}
+}
+
+// ProxyCircuitBreaker circuit breaker of each backend of a route. The circuit of a backend opens when the error rate
+// or the share of slow requests of the Window reach the thresholds. An open circuit doesn't receive requests for
+// OpenTime, and then becomes half-open and receives HalfOpenRequests probe requests: the circuit closes when all of
+// them succeed, and opens again when one of them fails. Routes with circuit breaker don't disable backends by
+// ConsecutiveErrorsToDisable
+type ProxyCircuitBreaker struct {
+	// ErrorRate [optional] share of failed requests, from 0 to 1, that opens the circuit. 0 doesn't look at errors
+	ErrorRate float64 `json:"errorRate"`
+
+	// SlowThreshold [optional] response time of a slow request. 0 doesn't look at the response time
+	SlowThreshold time.Duration `json:"slowThreshold"`
+
+	// SlowRate [optional] share of slow requests, from 0 to 1, that opens the circuit. Default 0.5
+	SlowRate float64 `json:"slowRate"`
+
+	// Window [optional] duration of the sliding window. Default 10s
+	Window time.Duration `json:"window"`
+
+	// MinRequests [optional] requests of the window before the circuit can open. Default 20
+	MinRequests int64 `json:"minRequests"`
+
+	// OpenTime [optional] time the circuit stays open before it becomes half-open. Default 30s
+	OpenTime time.Duration `json:"openTime"`
+
+	// HalfOpenRequests [optional] probe requests sent while the circuit is half-open. Default 3
+	HalfOpenRequests int `json:"halfOpenRequests"`
+}
+
+// enabled returns true when the route has circuit breaker
+func (e *ProxyCircuitBreaker) enabled() bool {
+	return e.ErrorRate > 0 || e.SlowThreshold > 0
+}
+
+// prepare fills in the default values
+func (e *ProxyCircuitBreaker) prepare() {
+	if e.SlowRate == 0 {
+		e.SlowRate = 0.5
+	}
+
+	if e.Window < circuitBuckets {
+		e.Window = 10 * time.Second
+	}
+
+	if e.MinRequests == 0 {
+		e.MinRequests = 20
+	}
+
+	if e.OpenTime == 0 {
+		e.OpenTime = 30 * time.Second
+	}
+
+	if e.HalfOpenRequests == 0 {
+		e.HalfOpenRequests = 3
+	}
+}
+
+// ProxyFailure classification of the responses of the backends of a route. Failures count to disable the backend
+// and to open its circuit, and a request answered with one of StatusCodes can be sent to another backend
+type ProxyFailure struct {
+	// StatusCodes [optional] status codes that are failures of the backend. Default 500, 502, 503 and 504
+	StatusCodes []int `json:"statusCodes"`
+
+	// RetryStatus [optional] a request answered with one of StatusCodes is sent to another backend, when it can be
+	// retried. Otherwise, and on the last attempt, the response is passed through to the client. Default false
+	RetryStatus bool `json:"retryStatus"`
+
+	// ResponseTimeout [optional] time to receive the response headers. A slower backend fails with a timeout and the
+	// request can be retried. Default no limit
+	ResponseTimeout time.Duration `json:"responseTimeout"`
+
+	// SlowResponse [optional] response time, with the body, counted as a failure of the backend. The response is
+	// passed through to the client. Default no limit
+	SlowResponse time.Duration `json:"slowResponse"`
+
+	// IgnoreConnectionResets [optional] connections closed or reset by the backend aren't failures of the backend.
+	// The request is retried anyway, when it can be. Useful for backends that close idle keep-alive connections
+	IgnoreConnectionResets bool `json:"ignoreConnectionResets"`
+}
+
+// prepare fills in the default values
+func (e *ProxyFailure) prepare() {
+	if e.StatusCodes == nil {
+		e.StatusCodes = []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+	}
+}
+
+// ProxyRewrite changes of the requests sent to the backends of a route, and of their responses. The path is changed
+// in this order: StripPrefix, ExpReg and AddPrefix. Host and the values of the headers are templates with the
+// variables {clientIp}, {requestId}, {host}, {method}, {path} and {param.name}, the captures of the route
+type ProxyRewrite struct {
+	// StripPrefix [optional] prefix removed from the path. Ex.: /api sends /api/v1/calculate as /v1/calculate and keeps /apiary
+	StripPrefix string `json:"stripPrefix"`
+
+	// AddPrefix [optional] prefix added to the path. Ex.: /v1 sends /calculate as /v1/calculate
+	AddPrefix string `json:"addPrefix"`
+
+	// ExpReg [optional] regular expression replaced in the path by Replacement
+	ExpReg string `json:"expReg"`
+
+	// Replacement replacement of ExpReg, with $1 or ${name} for the groups. Ex.: /v1/jobs/$1
+	Replacement string `json:"replacement"`
+
+	// Host [optional] Host header sent to the backend. Default the host of the client. Ex.: api.internal
+	Host string `json:"host"`
+
+	// RequestHeaders [optional] headers set in the request. Ex.: {"X-Real-Ip": "{clientIp}"}
+	RequestHeaders map[string]string `json:"requestHeaders"`
+
+	// RemoveRequestHeaders [optional] headers removed from the request, before RequestHeaders are set
+	RemoveRequestHeaders []string `json:"removeRequestHeaders"`
+
+	// ResponseHeaders [optional] headers set in the response. Ex.: {"X-Request-Id": "{requestId}"}
+	ResponseHeaders map[string]string `json:"responseHeaders"`
+
+	// RemoveResponseHeaders [optional] headers removed from the response, before ResponseHeaders are set.
+	// Ex.: ["Server"]
+	RemoveResponseHeaders []string `json:"removeResponseHeaders"`
+}
+
+// ProxyRoute route of the proxy, served by a local handler or by a list of backends
+type ProxyRoute struct {
+	// Name unique name of the route, used in the statistics and to delete the route
+	Name string `json:"name"`
+
+	// Domain host of the route
+	Domain ProxyDomain `json:"domain"`
+
+	// Path [optional] path and method of the route
+	Path ProxyPath `json:"path"`
+
+	// Priority [optional] routes of higher priority are matched first. Between routes of the same priority, the
+	// first route of the configuration wins. Default 0
+	Priority int `json:"priority"`
+
+	// Handle [optional] local handler of the route
+	Handle ProxyHandle `json:"handle"`
+
+	// ProxyEnable sends the requests to ProxyServers, otherwise Handle is called
+	ProxyEnable bool `json:"proxyEnable"`
+
+	// ProxyServers backends of the route
+	ProxyServers []ProxyUrl `json:"proxyServers"`
+
+	// Balancer [optional] strategy used to choose the backend of each request. Default round-robin
+	Balancer ProxyBalancer `json:"balancer"`
+
+	// HealthCheck [optional] active health check of the backends, see Proxy.HealthCheck()
+	HealthCheck ProxyHealthCheck `json:"healthCheck"`
+
+	// CircuitBreaker [optional] circuit breaker of each backend
+	CircuitBreaker ProxyCircuitBreaker `json:"circuitBreaker"`
+
+	// Rewrite [optional] changes of the path, of the host and of the headers sent to the backends
+	Rewrite ProxyRewrite `json:"rewrite"`
+
+	// Failure [optional] responses of the backends treated as failures. Default the 500, 502, 503 and 504 status
+	// codes, passed through to the client
+	Failure ProxyFailure `json:"failure"`
+
+	// IdempotentMethods [optional] methods of the route retried on another backend after an error.
+	// Default ProxyConfig.IdempotentMethods. Ex.: ["POST"] for an endpoint without side effects, like /calculate
+	IdempotentMethods []string `json:"idempotentMethods"`
+}
+
+// ProxyConfig configuration of the proxy
+type ProxyConfig struct {
+	// DomainExpReg [optional] regular expression that splits the host into the subDomain, domain and port groups
+	DomainExpReg string `json:"domainExpReg"`
+
+	// ErrorHandle [optional] error page used when the domain doesn't have one. Default DefaultErrorHandle
+	ErrorHandle ProxyHandlerFunc `json:"-"`
+
+	// NotFoundHandle [optional] not found page used when no route matches. Default DefaultNotFoundHandle
+	NotFoundHandle ProxyHandlerFunc `json:"-"`
+
+	// DefaultHost [optional] host used to match the routes when the host of the request isn't the host of any route.
+	// Ex.: www.example.com
+	DefaultHost string `json:"defaultHost"`
+
+	// ListenAndServe address of the proxy. Ex.: :9999
+	ListenAndServe string `json:"listenAndServe"`
+
+	// MaxLoopTry [optional] attempts before the error page is sent, when the backends fail. Default 20
+	MaxLoopTry int `json:"maxLoopTry"`
+
+	// ConsecutiveErrorsToDisable [optional] consecutive errors that disable a backend for TimeToKeepDisabled.
+	// A backend with a temporary error keeps receiving requests. Default 10
+	ConsecutiveErrorsToDisable int64 `json:"consecutiveErrorsToDisable"`
+
+	// TimeToKeepDisabled [optional] time a disabled backend waits before receiving requests again. Default 90s
+	TimeToKeepDisabled time.Duration `json:"timeToKeepDisabled"`
+
+	// TimeToVerifyDisabled [optional] interval of VerifyDisabled() between the checks of the disabled backends.
+	// Default 30s
+	TimeToVerifyDisabled time.Duration `json:"timeToVerifyDisabled"`
+
+	// BodyMemoryLimit [optional] request bodies up to this size, in bytes, are buffered in memory and bigger ones in a
+	// temp file, so they can be sent again to another backend. Default 1MB
+	BodyMemoryLimit int64 `json:"bodyMemoryLimit"`
+
+	// BodyMaxBuffer [optional] request bodies bigger than this size, in bytes, are streamed to the backend and the
+	// request isn't retried. Default 32MB
+	BodyMaxBuffer int64 `json:"bodyMaxBuffer"`
+
+	// TempDir [optional] directory of the temp files of the request bodies. Default os.TempDir()
+	TempDir string `json:"tempDir"`
+
+	// IdempotentMethods [optional] methods retried on another backend after an error. Requests of other methods are
+	// retried only when the backend refused the connection, or when they carry the Idempotency-Key header.
+	// Default GET, HEAD, OPTIONS, TRACE, PUT and DELETE
+	IdempotentMethods []string `json:"idempotentMethods"`
+
+	// BufferSize [optional] size, in bytes, of the buffers used to stream the responses of the backends. Default 32KB
+	BufferSize int `json:"bufferSize"`
+
+	// FlushInterval [optional] interval between the flushes of the responses to the client while they are streamed.
+	// Default 0, the responses are flushed when the buffer is full. Ex.: 100ms for server-sent events
+	FlushInterval time.Duration `json:"flushInterval"`
+
+	// OnCircuitChange [optional] called when the circuit breaker of a backend changes its state
+	OnCircuitChange func(CircuitEvent) `json:"-"`
+
+	// Routes routes of the proxy, the route of higher priority that matches the request is used
+	Routes []ProxyRoute `json:"routes"`
+}
+
+// prepare fills in the default values
+func (e *ProxyConfig) prepare() {
+	if e.DomainExpReg == "" {
+		e.DomainExpReg = `^(?P<subDomain>[a-zA-Z0-9]??|[a-zA-Z0-9]?[a-zA-Z0-9-.]*?[a-zA-Z0-9]*)[.]*(?P<domain>[A-Za-z0-9]|[A-Za-z0-9][A-Za-z0-9-]*[A-Za-z0-9]):*(?P<port>[0-9]*)$`
+	}
+
+	if e.MaxLoopTry == 0 {
+		e.MaxLoopTry = 20
+	}
+
+	if e.ConsecutiveErrorsToDisable == 0 {
+		e.ConsecutiveErrorsToDisable = 10
+	}
+
+	if e.TimeToKeepDisabled == 0 {
+		e.TimeToKeepDisabled = 90 * time.Second
+	}
+
+	if e.TimeToVerifyDisabled == 0 {
+		e.TimeToVerifyDisabled = 30 * time.Second
+	}
+
+	if e.BodyMemoryLimit == 0 {
+		e.BodyMemoryLimit = 1 << 20
+	}
+
+	if e.BodyMaxBuffer == 0 {
+		e.BodyMaxBuffer = 32 << 20
+	}
+
+	if e.BufferSize == 0 {
+		e.BufferSize = 32 * 1024
+	}
+
+	if e.IdempotentMethods == nil {
+		e.IdempotentMethods = []string{http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete}
+	}
+
+	if e.ErrorHandle == nil {
+		e.ErrorHandle = DefaultErrorHandle
+	}
+
+	if e.NotFoundHandle == nil {
+		e.NotFoundHandle = DefaultNotFoundHandle
+	}
+}
@@ -0,0 +1,30 @@
+package proxy
+
+import (
+	"errors"
+	"io"
+	"syscall"
+)
+
+var (
+	// errFailureStatus the backend answered with one of ProxyFailure.StatusCodes
+	errFailureStatus = errors.New("failure status code")
+
+	// errResponseTimeout the backend didn't send the headers in ProxyFailure.ResponseTimeout
+	errResponseTimeout = errors.New("response timeout")
+)
+
+// failureStatus returns true when the status code is one of the failure status codes
+func failureStatus(statusCodes []int, statusCode int) bool {
+	for _, code := range statusCodes {
+		if code == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// connectionReset returns true when the backend closed or reset the connection
+func connectionReset(err error) bool {
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
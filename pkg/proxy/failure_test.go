@@ -0,0 +1,88 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newFailingBackend returns a test backend that fails as the path asks
+func newFailingBackend() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/500":
+			http.Error(w, "failing 500", http.StatusInternalServerError)
+		case "/503":
+			http.Error(w, "failing 503", http.StatusServiceUnavailable)
+		case "/404":
+			http.Error(w, "failing 404", http.StatusNotFound)
+		case "/slow":
+			time.Sleep(100 * time.Millisecond)
+			_, _ = w.Write([]byte("failing slow"))
+		case "/reset":
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			_ = conn.Close()
+		}
+	}))
+}
+
+func TestProxy_Failure(t *testing.T) {
+	failing := newFailingBackend()
+	defer failing.Close()
+	backend := newBackendServer("backend")
+	defer backend.Close()
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		failure ProxyFailure
+
+		statusCode int
+		body       string
+
+		// errors of the failing backend
+		errors int64
+	}{
+		{name: "5xx passed through", method: http.MethodGet, path: "/500", statusCode: http.StatusInternalServerError, body: "failing 500", errors: 1},
+		{name: "5xx retried", method: http.MethodGet, path: "/503", failure: ProxyFailure{RetryStatus: true}, statusCode: http.StatusOK, body: "backend GET /503", errors: 1},
+		{name: "5xx of a request that can't be retried", method: http.MethodPost, path: "/503", failure: ProxyFailure{RetryStatus: true}, statusCode: http.StatusServiceUnavailable, body: "failing 503", errors: 1},
+		{name: "status codes", method: http.MethodGet, path: "/404", failure: ProxyFailure{StatusCodes: []int{http.StatusNotFound}, RetryStatus: true}, statusCode: http.StatusOK, body: "backend GET /404", errors: 1},
+		{name: "not a failure", method: http.MethodGet, path: "/500", failure: ProxyFailure{StatusCodes: []int{}, RetryStatus: true}, statusCode: http.StatusInternalServerError, body: "failing 500"},
+		{name: "response timeout", method: http.MethodGet, path: "/slow", failure: ProxyFailure{ResponseTimeout: 20 * time.Millisecond}, statusCode: http.StatusOK, body: "backend GET /slow", errors: 1},
+		{name: "slow response", method: http.MethodGet, path: "/slow", failure: ProxyFailure{SlowResponse: 20 * time.Millisecond}, statusCode: http.StatusOK, body: "failing slow", errors: 1},
+		{name: "connection reset", method: http.MethodGet, path: "/reset", statusCode: http.StatusOK, body: "backend GET /reset", errors: 1},
+		{name: "connection reset ignored", method: http.MethodGet, path: "/reset", failure: ProxyFailure{IgnoreConnectionResets: true}, statusCode: http.StatusOK, body: "backend GET /reset"},
+	}
+
+	for _, test := range tests {
+		proxy, err := New(ProxyConfig{
+			Routes: []ProxyRoute{{
+				Name:         "flights",
+				ProxyEnable:  true,
+				ProxyServers: []ProxyUrl{{Name: "failing", Url: failing.URL}, {Name: "backend", Url: backend.URL}},
+				Failure:      test.failure,
+			}},
+		})
+		if err != nil {
+			t.Logf("%v: New().error: %v", test.name, err)
+			t.FailNow()
+		}
+
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest(test.method, test.path, nil))
+		if w.Code != test.statusCode || !strings.HasPrefix(w.Body.String(), test.body) {
+			t.Logf("%v: status code %v, body: %v", test.name, w.Code, w.Body.String())
+			t.FailNow()
+		}
+
+		// a failure isn't a successful use of the backend
+		statistics := proxy.Statistics()[0].ProxyServers[0]
+		if statistics.ErrorCounter != test.errors || (test.errors != 0 && statistics.UsedSuccessfully != 0) {
+			t.Logf("%v: statistics: %+v", test.name, statistics)
+			t.FailNow()
+		}
+	}
+}
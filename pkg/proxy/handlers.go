@@ -0,0 +1,138 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+)
+
+// DefaultErrorHandle error page sent when every backend of the route fails
+func DefaultErrorHandle(w ProxyResponseWriter, r *ProxyRequest) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusBadGateway)
+	_, _ = w.Write([]byte(`<html><header><style>body{height:100%; position:relative}div{margin:auto;height: 100%;width: 100%;position:fixed;top:0;bottom:0;left:0;right:0;background:blue;}div.center{margin:auto;height: 70%;width: 70%;}</style></header><body><div><div style="color:#ffff;" class="center"><p style="text-align: center; background-color: #888888;">There is something very wrong!</p><p>&nbsp;</p>The address is correct, but no server has responded correctly. The system administrator will be informed about this.<p>&nbsp;</p>Mussum Ipsum, cacilds vidis litro abertis. Interagi no mé, cursus quis, vehicula ac nisi. Viva Forevis aptent taciti sociosqu ad litora torquent. Atirei o pau no gatis, per gatis num morreus. Quem num gosta di mim que vai caçá sua turmis!</div></div></body></html>`))
+}
+
+// DefaultNotFoundHandle not found page sent when no route matches the request
+func DefaultNotFoundHandle(w ProxyResponseWriter, r *ProxyRequest) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusNotFound)
+	_, _ = w.Write([]byte(`<html><header><style>body{height:100%; position:relative}div{margin:auto;height: 100%;width: 100%;position:fixed;top:0;bottom:0;left:0;right:0;background:blue;}div.center{margin:auto;height: 70%;width: 70%;}</style></header><body><div><div style="color:#ffff;" class="center"><p style="text-align: center; background-color: #888888;">Page Not Found!</p><p>&nbsp;</p>Mussum Ipsum, cacilds vidis litro abertis. Interagi no mé, cursus quis, vehicula ac nisi. Viva Forevis aptent taciti sociosqu ad litora torquent. Atirei o pau no gatis, per gatis num morreus. Quem num gosta di mim que vai caçá sua turmis!<p>&nbsp;</p>Mussum Ipsum, cacilds vidis litro abertis. Interagi no mé, cursus quis, vehicula ac nisi. Viva Forevis aptent taciti sociosqu ad litora torquent. Atirei o pau no gatis, per gatis num morreus. Quem num gosta di mim que vai caçá sua turmis!</div></div></body></html>`))
+}
+
+// MetaJSonOutStt meta data of the json output of the admin endpoints
+type MetaJSonOutStt struct {
+	TotalCount int64  `json:"TotalCount"`
+	Error      string `json:"Error"`
+}
+
+// JSonOutStt json output of the admin endpoints
+type JSonOutStt struct {
+	Meta    MetaJSonOutStt `json:"Meta"`
+	Objects interface{}    `json:"Objects"`
+}
+
+// ToOutput writes the data, or the error with status 500
+func (e *JSonOutStt) ToOutput(totalCount int64, err error, data interface{}, w ProxyResponseWriter) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	e.Meta = MetaJSonOutStt{TotalCount: totalCount}
+	e.Objects = data
+
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		e.Meta = MetaJSonOutStt{Error: err.Error()}
+		e.Objects = []int{}
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if err = json.NewEncoder(w).Encode(e); err != nil {
+		log.Printf("JSonOutStt.ToOutput().json.NewEncoder(w).Encode().Error: %v", err)
+	}
+}
+
+// RouteAdd admin endpoint that adds a proxy route. The new route is used by the next requests.
+//
+//	{
+//	  "name": "news",
+//	  "domain": {"subDomain": "news", "domain": "localhost", "port": "8888"},
+//	  "proxyEnable": true,
+//	  "proxyServers": [
+//	    {"name": "docker 1 - ok", "url": "http://localhost:2368"},
+//	    {"name": "docker 2 - ok", "url": "http://localhost:2369"}
+//	  ]
+//	}
+func (e *Proxy) RouteAdd(w ProxyResponseWriter, r *ProxyRequest) {
+	var newRoute ProxyRoute
+	var output = JSonOutStt{}
+
+	err := json.NewDecoder(r.Body).Decode(&newRoute)
+	if err != nil {
+		output.ToOutput(0, err, []int{}, w)
+		return
+	}
+
+	if !newRoute.ProxyEnable {
+		output.ToOutput(0, errors.New("this function only adds new routes that can be used in conjunction with the reverse proxy"), []int{}, w)
+		return
+	}
+
+	if len(newRoute.ProxyServers) == 0 {
+		output.ToOutput(0, errors.New("this function must receive at least one route that can be used in conjunction with the reverse proxy"), []int{}, w)
+		return
+	}
+
+	for _, server := range newRoute.ProxyServers {
+		if server.Name == "" {
+			output.ToOutput(0, errors.New("every route must have a name assigned to it"), []int{}, w)
+			return
+		}
+	}
+
+	if err = e.AddRoute(newRoute); err != nil {
+		output.ToOutput(0, err, []int{}, w)
+		return
+	}
+
+	routes := e.Config().Routes
+	output.ToOutput(int64(len(routes)), nil, routes, w)
+}
+
+// RouteDelete admin endpoint that deletes a proxy route. Local handlers can't be deleted.
+//
+//	{"name": "name_of_route"}
+func (e *Proxy) RouteDelete(w ProxyResponseWriter, r *ProxyRequest) {
+	var route ProxyRoute
+	var output = JSonOutStt{}
+
+	err := json.NewDecoder(r.Body).Decode(&route)
+	if err != nil {
+		output.ToOutput(0, err, []int{}, w)
+		return
+	}
+
+	for _, current := range e.Config().Routes {
+		if current.Name == route.Name && !current.ProxyEnable {
+			output.ToOutput(0, errors.New("this function can only remove the routes used with the reverse proxy, not being able to remove other types of routes"), []int{}, w)
+			return
+		}
+	}
+
+	if err = e.DeleteRoute(route.Name); err != nil {
+		output.ToOutput(0, err, []int{}, w)
+		return
+	}
+
+	routes := e.Config().Routes
+	output.ToOutput(int64(len(routes)), nil, routes, w)
+}
+
+// ProxyStatistics admin endpoint with the usage of the routes and of their backends
+func (e *Proxy) ProxyStatistics(w ProxyResponseWriter, r *ProxyRequest) {
+	var output = JSonOutStt{}
+
+	statistics := e.Statistics()
+	output.ToOutput(int64(len(statistics)), nil, statistics, w)
+}
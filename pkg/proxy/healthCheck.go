@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// maxHealthCheckBody maximum part of the body of a probe compared with ExpectedBody
+const maxHealthCheckBody = 64 * 1024
+
+// maxHealthCheckWait maximum time HealthCheck() waits before looking at the routes again, so new routes are probed
+const maxHealthCheckWait = time.Second
+
+// HealthCheck probes the backends of the routes with ProxyRoute.HealthCheck, each one every Interval, until the
+// context is done. The probes of different backends run concurrently
+func (e *Proxy) HealthCheck(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-timer.C:
+			wait := maxHealthCheckWait
+			for _, route := range e.current().routes {
+				if route.config.HealthCheck.Path == "" {
+					continue
+				}
+
+				for _, backend := range route.backends {
+					if backend.probe(now) {
+						go route.probe(ctx, backend)
+					}
+				}
+
+				if route.config.HealthCheck.Interval < wait {
+					wait = route.config.HealthCheck.Interval
+				}
+			}
+			timer.Reset(wait)
+		}
+	}
+}
+
+// probe sends the probe to the backend and records the result
+func (e *route) probe(ctx context.Context, backend *backend) {
+	config := e.config.HealthCheck
+	err := e.check(ctx, backend)
+
+	if backend.probed(time.Now(), err, config) {
+		statistics := backend.statistics()
+		log.Printf("proxy.HealthCheck().route %v: backend %v is %v: %v", e.config.Name, backend.config.Name, statistics.Health, err)
+	}
+}
+
+// check returns nil when the response of the backend has one of the expected status codes and contains the expected body
+func (e *route) check(ctx context.Context, backend *backend) error {
+	config := e.config.HealthCheck
+
+	reference, err := url.Parse(config.Path)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, config.Timeout)
+	defer cancel()
+
+	r, err := http.NewRequestWithContext(ctx, config.Method, backend.url.ResolveReference(reference).String(), nil)
+	if err != nil {
+		return err
+	}
+
+	response, err := http.DefaultClient.Do(r)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if !expectedStatus(config.ExpectedStatus, response.StatusCode) {
+		return fmt.Errorf("unexpected status code %v", response.StatusCode)
+	}
+
+	if config.ExpectedBody != "" {
+		body, err := io.ReadAll(io.LimitReader(response.Body, maxHealthCheckBody))
+		if err != nil {
+			return err
+		}
+
+		if !bytes.Contains(body, []byte(config.ExpectedBody)) {
+			return fmt.Errorf("the body doesn't contain %q", config.ExpectedBody)
+		}
+	}
+
+	return nil
+}
+
+// expectedStatus returns true when the status code is in the list, or is 2xx when the list is empty
+func expectedStatus(expected []int, statusCode int) bool {
+	if len(expected) == 0 {
+		return statusCode >= 200 && statusCode < 300
+	}
+
+	for _, code := range expected {
+		if code == statusCode {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,196 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newHealthBackend returns a test backend whose /health answers 200 "ok" while healthy is 1, and 503 otherwise
+func newHealthBackend(name string, healthy *int32) *httptest.Server {
+	backend := newBackendServer(name)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			backend.Config.Handler.ServeHTTP(w, r)
+			return
+		}
+
+		if atomic.LoadInt32(healthy) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+}
+
+// waitHealth waits until the backend of the first route has the health
+func waitHealth(t *testing.T, proxy *Proxy, k int, health string) BackendStatistics {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		statistics := proxy.Statistics()[0].ProxyServers[k]
+		if statistics.Health == health {
+			return statistics
+		}
+
+		if time.Now().After(deadline) {
+			t.Logf("backend %v must be %v: %+v", k, health, statistics)
+			t.FailNow()
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestProxy_HealthCheck(t *testing.T) {
+	healthy1, healthy2 := int32(1), int32(1)
+	backend1 := newHealthBackend("backend1", &healthy1)
+	defer backend1.Close()
+	backend2 := newHealthBackend("backend2", &healthy2)
+	defer backend2.Close()
+
+	proxy, err := New(ProxyConfig{
+		ConsecutiveErrorsToDisable: 1,
+		TimeToKeepDisabled:         time.Millisecond,
+		TimeToVerifyDisabled:       time.Millisecond,
+		Routes: []ProxyRoute{{
+			Name:         "flights",
+			ProxyEnable:  true,
+			ProxyServers: []ProxyUrl{{Name: "backend 1", Url: backend1.URL}, {Name: "backend 2", Url: backend2.URL}},
+			HealthCheck:  ProxyHealthCheck{Path: "/health", ExpectedBody: "ok", Interval: 10 * time.Millisecond, Rise: 2, Fall: 2},
+		}},
+	})
+	if err != nil {
+		t.Logf("New().error: %v", err)
+		t.FailNow()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go proxy.HealthCheck(ctx)
+	go proxy.VerifyDisabled(ctx)
+
+	waitHealth(t, proxy, 0, HealthHealthy)
+
+	// the unhealthy backend doesn't receive requests
+	atomic.StoreInt32(&healthy1, 0)
+	statistics := waitHealth(t, proxy, 0, HealthUnhealthy)
+	if !strings.Contains(statistics.LastProbeError, "503") {
+		t.Logf("the error of the probe must be in the statistics: %+v", statistics)
+		t.FailNow()
+	}
+
+	for i := 0; i < 4; i += 1 {
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/route", nil))
+		if !strings.HasPrefix(w.Body.String(), "backend2") {
+			t.Logf("request %v was sent to the unhealthy backend: %v", i, w.Body.String())
+			t.FailNow()
+		}
+	}
+
+	atomic.StoreInt32(&healthy1, 1)
+	waitHealth(t, proxy, 0, HealthHealthy)
+
+	// a backend disabled by errors is enabled by the probes, not by VerifyDisabled()
+	backend2.Close()
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/route", nil))
+	proxy.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/route", nil))
+
+	waitHealth(t, proxy, 1, HealthUnhealthy)
+	time.Sleep(20 * time.Millisecond)
+	if statistics = proxy.Statistics()[0].ProxyServers[1]; statistics.Enabled || statistics.ErrorCounter != 1 {
+		t.Logf("the backend must stay disabled while it fails the probes: %+v", statistics)
+		t.FailNow()
+	}
+
+	// every backend is unhealthy, the error page is sent
+	atomic.StoreInt32(&healthy1, 0)
+	waitHealth(t, proxy, 0, HealthUnhealthy)
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/route", nil))
+	if w.Code != http.StatusBadGateway {
+		t.Logf("status code %v, body: %v", w.Code, w.Body.String())
+		t.FailNow()
+	}
+}
+
+func TestProxy_HealthCheckDisabled(t *testing.T) {
+	var calls int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer backend.Close()
+
+	// the probes don't run, the backend can only be enabled by the requests
+	proxy, err := New(ProxyConfig{
+		ConsecutiveErrorsToDisable: 1,
+		Routes: []ProxyRoute{{
+			Name:         "flights",
+			ProxyEnable:  true,
+			ProxyServers: []ProxyUrl{{Name: "backend", Url: backend.URL}},
+			HealthCheck:  ProxyHealthCheck{Path: "/health", Interval: time.Hour},
+		}},
+	})
+	if err != nil {
+		t.Logf("New().error: %v", err)
+		t.FailNow()
+	}
+
+	for i := 0; i < 3; i += 1 {
+		proxy.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/route", nil))
+	}
+
+	// the disabled backend isn't enabled by the requests, they receive the error page
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/route", nil))
+	statistics := proxy.Statistics()[0].ProxyServers[0]
+	if w.Code != http.StatusBadGateway || atomic.LoadInt32(&calls) != 1 || statistics.Enabled || statistics.ErrorConsecutiveCounter != 1 {
+		t.Logf("status code %v, calls: %v, statistics: %+v", w.Code, atomic.LoadInt32(&calls), statistics)
+		t.FailNow()
+	}
+}
+
+func TestRoute_check(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			_, _ = w.Write([]byte(`{"status":"` + r.Method + " " + r.URL.Query().Get("deep") + `"}`))
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer backend.Close()
+
+	tests := []struct {
+		config ProxyHealthCheck
+		err    bool
+	}{
+		{config: ProxyHealthCheck{Path: "/health"}},
+		{config: ProxyHealthCheck{Path: "/health?deep=1", Method: http.MethodHead}},
+		{config: ProxyHealthCheck{Path: "/health?deep=1", ExpectedBody: "GET 1"}},
+		{config: ProxyHealthCheck{Path: "/health", ExpectedBody: "GET 1"}, err: true},
+		{config: ProxyHealthCheck{Path: "/missing"}, err: true},
+		{config: ProxyHealthCheck{Path: "/missing", ExpectedStatus: []int{http.StatusOK, http.StatusNotFound}}},
+		{config: ProxyHealthCheck{Path: "/slow", Timeout: 20 * time.Millisecond}, err: true},
+	}
+
+	for _, test := range tests {
+		route, err := newRoute(ProxyRoute{Name: "flights", ProxyEnable: true, ProxyServers: []ProxyUrl{{Url: backend.URL}}, HealthCheck: test.config}, ProxyConfig{})
+		if err != nil {
+			t.Logf("%+v: newRoute().error: %v", test.config, err)
+			t.FailNow()
+		}
+
+		if err = route.check(context.Background(), route.backends[0]); (err != nil) != test.err {
+			t.Logf("%+v: check().error: %v", test.config, err)
+			t.FailNow()
+		}
+	}
+}
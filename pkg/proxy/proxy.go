@@ -0,0 +1,603 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrRouteNotFound there is no route with the name
+	ErrRouteNotFound = errors.New("route not found")
+
+	// ErrRouteExists there is already a route with the name
+	ErrRouteExists = errors.New("there is already a route with this name")
+)
+
+// HandleStatistics usage of the local handler of a route
+type HandleStatistics struct {
+	// Name name of the handler
+	Name string `json:"name"`
+
+	// TotalTime sum of the response times of the handler
+	TotalTime time.Duration `json:"totalTime"`
+
+	// UsedSuccessfully requests answered by the handler
+	UsedSuccessfully int64 `json:"usedSuccessfully"`
+}
+
+// RouteStatistics usage of a route and of its backends
+type RouteStatistics struct {
+	// Name name of the route
+	Name string `json:"name"`
+
+	// Handle usage of the local handler
+	Handle HandleStatistics `json:"handle"`
+
+	// ProxyServers usage of the backends
+	ProxyServers []BackendStatistics `json:"proxyServers"`
+}
+
+// route compiled route of a snapshot. The configuration doesn't change, only the statistics
+type route struct {
+	config   ProxyRoute
+	host     *hostMatcher
+	expReg   *regexp.Regexp
+	headers  map[string]*regexp.Regexp
+	rewrite  *rewriter
+	backends []*backend
+
+	// idempotent methods retried after an error
+	idempotent map[string]bool
+
+	// balancer chooses the backend of each request
+	balancer Balancer
+
+	mutex            sync.Mutex
+	handleTotalTime  time.Duration
+	handleSuccessful int64
+}
+
+// newRoute compiles the route. The idempotent methods of the proxy are used when the route doesn't inform them
+func newRoute(config ProxyRoute, proxyConfig ProxyConfig) (e *route, err error) {
+	e = &route{config: config, idempotent: make(map[string]bool)}
+
+	idempotentMethods := proxyConfig.IdempotentMethods
+	if config.IdempotentMethods != nil {
+		idempotentMethods = config.IdempotentMethods
+	}
+	for _, method := range idempotentMethods {
+		e.idempotent[strings.ToUpper(method)] = true
+	}
+
+	if e.host, err = newHostMatcher(config.Domain); err != nil {
+		return nil, fmt.Errorf("route %v: %v", config.Name, err)
+	}
+
+	if config.Path.ExpReg != "" {
+		if e.expReg, err = regexp.Compile(config.Path.ExpReg); err != nil {
+			return nil, fmt.Errorf("route %v: %v", config.Name, err)
+		}
+	}
+
+	if e.headers, err = compileHeaders(config.Path.Headers); err != nil {
+		return nil, fmt.Errorf("route %v: %v", config.Name, err)
+	}
+
+	if e.rewrite, err = newRewriter(config.Rewrite); err != nil {
+		return nil, fmt.Errorf("route %v: %v", config.Name, err)
+	}
+
+	if config.ProxyEnable && len(config.ProxyServers) == 0 && config.Handle.Handle == nil {
+		return nil, fmt.Errorf("route %v: a proxy route must have at least one backend", config.Name)
+	}
+
+	if config.HealthCheck.Path != "" {
+		e.config.HealthCheck.prepare()
+	}
+
+	if config.CircuitBreaker.enabled() {
+		e.config.CircuitBreaker.prepare()
+	}
+
+	e.config.Failure.prepare()
+
+	for _, server := range config.ProxyServers {
+		breaker := newCircuitBreaker(e.config.CircuitBreaker, config.Name, server.Name, proxyConfig.OnCircuitChange)
+		backend, err := newBackend(server, config.HealthCheck.Path != "", breaker)
+		if err != nil {
+			return nil, fmt.Errorf("route %v: backend %v: %v", config.Name, server.Name, err)
+		}
+		e.backends = append(e.backends, backend)
+	}
+
+	if len(e.backends) != 0 {
+		if e.balancer, err = NewBalancer(config.Balancer, config.ProxyServers); err != nil {
+			return nil, fmt.Errorf("route %v: %v", config.Name, err)
+		}
+	}
+
+	return
+}
+
+// match returns true when the method, the headers, the regular expression of the path and the host match the route,
+// and fills in the captures of the regular expressions. The host is matched last, so a route rejected by another
+// check doesn't leave captures behind. The exact paths and the prefixes are matched by the route table
+func (e *route) match(request *ProxyRequest, host, port string) bool {
+	if e.config.Path.Method != "" && e.config.Path.Method != request.Method {
+		return false
+	}
+
+	for name, expReg := range e.headers {
+		values := request.Header.Values(name)
+		if len(values) == 0 || !expReg.MatchString(strings.Join(values, ", ")) {
+			return false
+		}
+	}
+
+	var matches []string
+	if e.expReg != nil {
+		if matches = e.expReg.FindStringSubmatch(request.URL.Path); matches == nil {
+			return false
+		}
+	}
+
+	if !e.host.match(host, port, request) {
+		return false
+	}
+
+	if e.expReg != nil {
+		for k, name := range e.expReg.SubexpNames() {
+			if k != 0 && name != "" {
+				request.ExpRegMatches[name] = matches[k]
+			}
+		}
+	}
+	return true
+}
+
+// next returns the index of the backend chosen by the balancer between the enabled backends that didn't fail in this
+// request. Returns -1 when there is none
+func (e *route) next(request *ProxyRequest, failed []bool) int {
+	candidates := make([]int, 0, len(e.backends))
+	for k, backend := range e.backends {
+		if !failed[k] && backend.available() {
+			candidates = append(candidates, k)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return -1
+	}
+	return e.balancer.Next(request, candidates)
+}
+
+// retryable returns true when the request can be sent to another backend after the error.
+// A refused connection never reached the backend, otherwise the method must be idempotent
+func (e *route) retryable(request *ProxyRequest, err error) bool {
+	if !request.Replayable {
+		return false
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+
+	return e.idempotent[request.Method] || request.Header.Get("Idempotency-Key") != ""
+}
+
+// classify returns the failure of the attempt: the error of the transport, a failure status code passed through or a
+// slow response. Returns nil when the backend answered without failure
+func (e *route) classify(transport *transport, elapsed time.Duration) error {
+	if transport.Error != nil {
+		return transport.Error
+	}
+
+	if transport.Failure != nil {
+		return transport.Failure
+	}
+
+	if e.config.Failure.SlowResponse > 0 && elapsed >= e.config.Failure.SlowResponse {
+		return fmt.Errorf("slow response in %v", elapsed)
+	}
+	return nil
+}
+
+// handled records a request answered by the local handler
+func (e *route) handled(elapsed time.Duration) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.handleTotalTime += elapsed
+	e.handleSuccessful += 1
+}
+
+// statistics returns a copy of the statistics of the route and of its backends
+func (e *route) statistics() (statistics RouteStatistics) {
+	e.mutex.Lock()
+	statistics = RouteStatistics{
+		Name:         e.config.Name,
+		Handle:       HandleStatistics{Name: e.config.Handle.Name, TotalTime: e.handleTotalTime, UsedSuccessfully: e.handleSuccessful},
+		ProxyServers: make([]BackendStatistics, 0, len(e.backends)),
+	}
+	e.mutex.Unlock()
+
+	for _, backend := range e.backends {
+		statistics.ProxyServers = append(statistics.ProxyServers, backend.statistics())
+	}
+	return
+}
+
+// snapshot immutable configuration of the proxy. Changes of the routes create a new snapshot, so a request uses
+// the same routes from the beginning to the end
+type snapshot struct {
+	config ProxyConfig
+	domain *regexp.Regexp
+	routes []*route
+	hosts  *virtualHosts
+	table  *routeTable
+}
+
+// newSnapshot compiles the configuration. The routes of previous with the same name are kept, with their statistics
+func newSnapshot(config ProxyConfig, previous []*route) (e *snapshot, err error) {
+	e = &snapshot{config: config}
+	if e.domain, err = regexp.Compile(config.DomainExpReg); err != nil {
+		return nil, fmt.Errorf("domainExpReg: %v", err)
+	}
+
+	compiled := make(map[string]*route, len(previous))
+	for _, route := range previous {
+		compiled[route.config.Name] = route
+	}
+
+	names := make(map[string]bool, len(config.Routes))
+	for _, routeConfig := range config.Routes {
+		if names[routeConfig.Name] {
+			return nil, fmt.Errorf("route %v: %w", routeConfig.Name, ErrRouteExists)
+		}
+		names[routeConfig.Name] = true
+
+		route, found := compiled[routeConfig.Name]
+		if !found {
+			if route, err = newRoute(routeConfig, config); err != nil {
+				return nil, err
+			}
+		}
+		e.routes = append(e.routes, route)
+	}
+
+	e.hosts = newVirtualHosts(e.routes, config.DefaultHost)
+	e.table = newRouteTable(e.routes)
+	return
+}
+
+// Proxy reverse proxy with load balance between the backends of each route.
+// The routes are kept in an immutable snapshot, replaced atomically by AddRoute() and DeleteRoute(), and the
+// statistics of the backends are protected by their own mutex, so the proxy can be used by concurrent requests
+type Proxy struct {
+	// snapshot current *snapshot
+	snapshot atomic.Value
+
+	// mutex serializes the changes of the routes
+	mutex sync.Mutex
+
+	// buffers buffers used to stream the responses of the backends
+	buffers BufferPool
+}
+
+// New returns a proxy with the configuration. The default values are filled in
+func New(config ProxyConfig) (proxy *Proxy, err error) {
+	config.prepare()
+	config.Routes = append([]ProxyRoute{}, config.Routes...)
+
+	current, err := newSnapshot(config, nil)
+	if err != nil {
+		return
+	}
+
+	proxy = &Proxy{buffers: NewBufferPool(config.BufferSize)}
+	proxy.snapshot.Store(current)
+	return
+}
+
+// current returns the current snapshot
+func (e *Proxy) current() *snapshot {
+	return e.snapshot.Load().(*snapshot)
+}
+
+// Config returns the current configuration, with the default values filled in
+func (e *Proxy) Config() ProxyConfig {
+	config := e.current().config
+	config.Routes = append([]ProxyRoute{}, config.Routes...)
+	return config
+}
+
+// AddRoute adds the route after the current routes. The requests already running keep using the previous routes
+func (e *Proxy) AddRoute(routeConfig ProxyRoute) (err error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	current := e.current()
+	config := current.config
+	config.Routes = append(append([]ProxyRoute{}, config.Routes...), routeConfig)
+
+	next, err := newSnapshot(config, current.routes)
+	if err != nil {
+		return
+	}
+
+	e.snapshot.Store(next)
+	return
+}
+
+// DeleteRoute removes the route with the name. The requests already running keep using the previous routes
+func (e *Proxy) DeleteRoute(name string) (err error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	current := e.current()
+	config := current.config
+	config.Routes = make([]ProxyRoute, 0, len(current.config.Routes))
+	for _, routeConfig := range current.config.Routes {
+		if routeConfig.Name != name {
+			config.Routes = append(config.Routes, routeConfig)
+		}
+	}
+
+	if len(config.Routes) == len(current.config.Routes) {
+		return fmt.Errorf("route %v: %w", name, ErrRouteNotFound)
+	}
+
+	next, err := newSnapshot(config, current.routes)
+	if err != nil {
+		return
+	}
+
+	e.snapshot.Store(next)
+	return
+}
+
+// Statistics returns the usage of the routes and of their backends
+func (e *Proxy) Statistics() (statistics []RouteStatistics) {
+	current := e.current()
+
+	statistics = make([]RouteStatistics, 0, len(current.routes))
+	for _, route := range current.routes {
+		statistics = append(statistics, route.statistics())
+	}
+	return
+}
+
+// VerifyDisabled enables again the backends disabled for more than TimeToKeepDisabled, every TimeToVerifyDisabled.
+// A backend can be out for a while, so it is removed for some time to avoid unnecessary calls. The backends of routes
+// with health check are enabled by HealthCheck() instead, after they pass the probes. Returns when the context is done
+func (e *Proxy) VerifyDisabled(ctx context.Context) {
+	ticker := time.NewTicker(e.current().config.TimeToVerifyDisabled)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			current := e.current()
+			for _, route := range current.routes {
+				if route.config.HealthCheck.Path != "" {
+					continue
+				}
+
+				for _, backend := range route.backends {
+					backend.enable(now, current.config.TimeToKeepDisabled, false)
+				}
+			}
+		}
+	}
+}
+
+// ServeHTTP sends the request to the local handler or to a backend of the route that matches the request
+func (e *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	current := e.current()
+	start := time.Now()
+
+	responseWriter := ProxyResponseWriter{ResponseWriter: w}
+	request := &ProxyRequest{
+		Request:       r,
+		ExpRegMatches: make(map[string]string),
+		Path:          r.URL.Path,
+	}
+
+	// splits the host into sub domain, domain and port
+	matches := current.domain.FindStringSubmatch(r.Host)
+	if matches == nil {
+		current.config.NotFoundHandle(responseWriter, request)
+		return
+	}
+	for k, name := range current.domain.SubexpNames() {
+		switch name {
+		case "subDomain":
+			request.SubDomain = matches[k]
+		case "domain":
+			request.Domain = matches[k]
+		case "port":
+			request.Port = matches[k]
+		}
+	}
+
+	request.QueryString, _ = url.ParseQuery(r.URL.RawQuery)
+
+	// a host without routes uses the routes of the default host
+	host, port := current.hosts.resolve(r.Host)
+
+	route := current.table.lookup(request, host, port)
+	if route == nil {
+		current.hosts.notFound(host, port, current.config.NotFoundHandle)(responseWriter, request)
+		return
+	}
+
+	if route.config.Handle.Handle != nil {
+		route.config.Handle.Handle(responseWriter, request)
+		route.handled(time.Since(start))
+		return
+	}
+
+	// the body is buffered to be sent again when a backend fails
+	body, err := bufferBody(r, current.config.BodyMemoryLimit, current.config.BodyMaxBuffer, current.config.TempDir)
+	if err != nil {
+		log.Printf("proxy.ServeHTTP().bufferBody().error: %v", err)
+		http.Error(w, "the request body can't be read", http.StatusBadRequest)
+		return
+	}
+
+	// the temp file is removed even when the response is aborted
+	defer func() {
+		if err := body.Close(); err != nil {
+			log.Printf("proxy.ServeHTTP().body.Close().error: %v", err)
+		}
+	}()
+
+	request.Replayable = body.Replayable()
+	e.proxy(current, route, responseWriter, request, body)
+}
+
+// proxy sends the request to the backends of the route, chosen by the balancer, until one of them answers.
+// The error page is sent after MaxLoopTry failures, or after the first failure of a request that can't be retried
+func (e *Proxy) proxy(current *snapshot, route *route, w ProxyResponseWriter, request *ProxyRequest, body *bufferedBody) {
+	failed := make([]bool, len(route.backends))
+
+	loopCounter := 0
+	for loopCounter < current.config.MaxLoopTry {
+		// the client went away, nobody receives the response
+		if request.Context().Err() != nil {
+			log.Printf("proxy.proxy().route %v: the client went away: %v", route.config.Name, request.Context().Err())
+			return
+		}
+
+		k := route.next(request, failed)
+
+		// every enabled backend failed in this request, they're tried again
+		if k < 0 {
+			for key := range failed {
+				failed[key] = false
+			}
+			k = route.next(request, failed)
+		}
+
+		// the backends of a route with health check are enabled again only by passing the probes
+		if k < 0 && route.config.HealthCheck.Path != "" {
+			log.Printf("proxy.proxy().route %v: all backends are disabled or unhealthy, waiting for the health check", route.config.Name)
+			break
+		}
+
+		// every backend is disabled by consecutive errors, they're enabled and tried anyway.
+		// The unhealthy backends and the open circuits aren't used
+		if k < 0 {
+			log.Printf("proxy.proxy().route %v: all backends are disabled by errors and are being tried anyway", route.config.Name)
+			for _, backend := range route.backends {
+				backend.enable(time.Now(), 0, true)
+			}
+
+			if k = route.next(request, failed); k < 0 {
+				log.Printf("proxy.proxy().route %v: all backends are unhealthy or have the circuit open", route.config.Name)
+				break
+			}
+		}
+
+		backend := route.backends[k]
+		start := time.Now()
+
+		// the half-open circuit was taken by other requests. The backend reserved by the balancer is released
+		allowed, probe := backend.breaker.allow(start)
+		if !allowed {
+			route.balancer.Done(k, 0, ErrAttemptAborted)
+			failed[k] = true
+			loopCounter += 1
+			continue
+		}
+
+		// a failure status code is retried only when there is another attempt, otherwise it is passed through
+		transport := &transport{
+			RoundTripper: http.DefaultTransport,
+			failure:      route.config.Failure,
+			retryStatus:  route.config.Failure.RetryStatus && loopCounter+1 < current.config.MaxLoopTry && route.retryable(request, errFailureStatus),
+		}
+		reverseProxy := NewSingleHostReverseProxy(backend.url)
+		reverseProxy.Transport = transport
+		reverseProxy.BufferPool = e.buffers
+		reverseProxy.FlushInterval = current.config.FlushInterval
+
+		// each attempt sends the body from the beginning, with its own url because the director joins it with the path
+		// of the backend. The response is streamed to the original writer, so it can be flushed
+		attempt := request.Request.WithContext(request.Context())
+		attemptUrl := *request.URL
+		attempt.URL = &attemptUrl
+		attempt.Body = body.Reader()
+		if route.rewrite != nil {
+			route.rewrite.request(attempt, request)
+			reverseProxy.ModifyResponse = func(response *http.Response) error {
+				route.rewrite.response(response, request)
+				return nil
+			}
+		}
+		reverseProxy.ServeHTTP(w.ResponseWriter, attempt)
+		elapsed := time.Since(start)
+
+		// the client went away during the attempt, the backend isn't measured
+		if request.Context().Err() != nil {
+			route.balancer.Done(k, elapsed, ErrAttemptAborted)
+			backend.breaker.release(probe)
+			log.Printf("proxy.proxy().route %v: backend %v: the client went away: %v", route.config.Name, backend.config.Name, request.Context().Err())
+			return
+		}
+
+		err := route.classify(transport, elapsed)
+		counted := err != nil && !(route.config.Failure.IgnoreConnectionResets && connectionReset(err))
+		route.balancer.Done(k, elapsed, err)
+		backend.breaker.done(time.Now(), elapsed, counted, probe)
+		if counted {
+			backend.failure(time.Now(), current.config.ConsecutiveErrorsToDisable)
+		}
+
+		if transport.Error != nil {
+			log.Printf("proxy.proxy().route %v: backend %v: %v", route.config.Name, backend.config.Name, transport.Error)
+			failed[k] = true
+			loopCounter += 1
+
+			if !route.retryable(request, transport.Error) {
+				log.Printf("proxy.proxy().route %v: the %v request can't be retried", route.config.Name, request.Method)
+				break
+			}
+			continue
+		}
+
+		// the client must know that the response is incomplete, the connection is closed
+		if transport.Truncated {
+			log.Printf("proxy.proxy().route %v: backend %v: %v, the response was aborted", route.config.Name, backend.config.Name, err)
+			panic(http.ErrAbortHandler)
+		}
+
+		if err != nil {
+			log.Printf("proxy.proxy().route %v: backend %v: %v, the response was passed through", route.config.Name, backend.config.Name, err)
+			return
+		}
+
+		backend.success(elapsed)
+		return
+	}
+
+	log.Printf("proxy.proxy().route %v: the backends failed %v times, the error page was sent", route.config.Name, loopCounter)
+	if route.config.Domain.ErrorHandle != nil {
+		route.config.Domain.ErrorHandle(w, request)
+		return
+	}
+	current.config.ErrorHandle(w, request)
+}
@@ -0,0 +1,458 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newBackendServer returns a test backend that answers with its name
+func newBackendServer(name string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_, _ = fmt.Fprintf(w, "%v %v %v %s", name, r.Method, r.URL.Path, body)
+	}))
+}
+
+// newDeadBackend returns the url of a backend that refuses connections
+func newDeadBackend() string {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	return server.URL
+}
+
+func TestProxy_ServeHTTP(t *testing.T) {
+	backend1 := newBackendServer("backend1")
+	defer backend1.Close()
+	backend2 := newBackendServer("backend2")
+	defer backend2.Close()
+
+	proxy, err := New(ProxyConfig{
+		Routes: []ProxyRoute{
+			{
+				Name: "hello",
+				Path: ProxyPath{ExpReg: `^/hello/(?P<module>[a-z]+)/(?P<site>[a-z]+)$`, Method: http.MethodGet},
+				Handle: ProxyHandle{Name: "hello", Handle: func(w ProxyResponseWriter, r *ProxyRequest) {
+					_, _ = fmt.Fprintf(w, "module: %v, site: %v, port: %v", r.ExpRegMatches["module"], r.ExpRegMatches["site"], r.Port)
+				}},
+			},
+			{
+				Name:         "calculate",
+				Path:         ProxyPath{Path: "/calculate", Method: http.MethodPost},
+				ProxyEnable:  true,
+				ProxyServers: []ProxyUrl{{Name: "backend 1", Url: backend1.URL}, {Name: "backend 2", Url: backend2.URL}},
+			},
+		},
+	})
+	if err != nil {
+		t.Logf("New().error: %v", err)
+		t.FailNow()
+	}
+
+	tests := []struct {
+		method     string
+		path       string
+		statusCode int
+		body       string
+	}{
+		{method: http.MethodGet, path: "/hello/flights/docs", statusCode: http.StatusOK, body: "module: flights, site: docs, port: 9999"},
+		{method: http.MethodPost, path: "/calculate", statusCode: http.StatusOK, body: "backend1 POST /calculate [[\"SFO\",\"GSO\"]]"},
+		{method: http.MethodPost, path: "/calculate", statusCode: http.StatusOK, body: "backend2 POST /calculate [[\"SFO\",\"GSO\"]]"},
+		{method: http.MethodPost, path: "/calculate", statusCode: http.StatusOK, body: "backend1 POST /calculate [[\"SFO\",\"GSO\"]]"},
+		{method: http.MethodGet, path: "/calculate", statusCode: http.StatusNotFound, body: "Page Not Found!"},
+		{method: http.MethodGet, path: "/hello/flights", statusCode: http.StatusNotFound, body: "Page Not Found!"},
+	}
+
+	// the requests aren't logged, the response times are in the statistics
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	for _, test := range tests {
+		r := httptest.NewRequest(test.method, "http://www.example.com:9999"+test.path, strings.NewReader(`[["SFO","GSO"]]`))
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, r)
+
+		if w.Code != test.statusCode || !strings.Contains(w.Body.String(), test.body) {
+			t.Logf("%v %v: status code %v, body: %v", test.method, test.path, w.Code, w.Body.String())
+			t.FailNow()
+		}
+	}
+
+	if logs.Len() != 0 {
+		t.Logf("the requests must not be logged: %v", logs.String())
+		t.FailNow()
+	}
+
+	statistics := proxy.Statistics()
+	if len(statistics) != 2 || statistics[0].Handle.UsedSuccessfully != 1 || statistics[1].ProxyServers[0].UsedSuccessfully != 2 || statistics[1].ProxyServers[1].UsedSuccessfully != 1 {
+		t.Logf("Statistics(): %+v", statistics)
+		t.FailNow()
+	}
+}
+
+func TestProxy_Failover(t *testing.T) {
+	backend := newBackendServer("backend")
+	defer backend.Close()
+
+	proxy, err := New(ProxyConfig{
+		ConsecutiveErrorsToDisable: 2,
+		TimeToKeepDisabled:         50 * time.Millisecond,
+		TimeToVerifyDisabled:       10 * time.Millisecond,
+		Routes: []ProxyRoute{{
+			Name:         "flights",
+			ProxyEnable:  true,
+			ProxyServers: []ProxyUrl{{Name: "dead", Url: newDeadBackend()}, {Name: "alive", Url: backend.URL}},
+		}},
+	})
+	if err != nil {
+		t.Logf("New().error: %v", err)
+		t.FailNow()
+	}
+
+	// the dead backend is disabled after two errors, and every request is answered by the other one
+	for i := 0; i < 6; i += 1 {
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/route", nil))
+		if w.Code != http.StatusOK || !strings.HasPrefix(w.Body.String(), "backend GET /route") {
+			t.Logf("status code %v, body: %v", w.Code, w.Body.String())
+			t.FailNow()
+		}
+	}
+
+	statistics := proxy.Statistics()[0].ProxyServers
+	if statistics[0].Enabled || statistics[0].ErrorCounter != 2 || statistics[1].UsedSuccessfully != 6 {
+		t.Logf("the dead backend must be disabled: %+v", statistics)
+		t.FailNow()
+	}
+
+	// the dead backend is enabled again after TimeToKeepDisabled
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go proxy.VerifyDisabled(ctx)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !proxy.Statistics()[0].ProxyServers[0].Enabled {
+		if time.Now().After(deadline) {
+			t.Log("VerifyDisabled() must enable the backend after TimeToKeepDisabled")
+			t.FailNow()
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// the error page is sent when every backend fails
+	proxy, _ = New(ProxyConfig{
+		MaxLoopTry: 3,
+		Routes:     []ProxyRoute{{Name: "dead", ProxyEnable: true, ProxyServers: []ProxyUrl{{Name: "dead", Url: newDeadBackend()}}}},
+	})
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/route", nil))
+	if w.Code != http.StatusBadGateway || proxy.Statistics()[0].ProxyServers[0].ErrorCounter != 3 {
+		t.Logf("status code %v, statistics: %+v", w.Code, proxy.Statistics())
+		t.FailNow()
+	}
+}
+
+// newResetBackend returns a test backend that closes the connection after reading the request
+func newResetBackend() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			_ = conn.Close()
+		}
+	}))
+}
+
+func TestProxy_Retry(t *testing.T) {
+	backend := newBackendServer("backend")
+	defer backend.Close()
+	reset := newResetBackend()
+	defer reset.Close()
+
+	payload := strings.Repeat(`["SFO","GSO"],`, 100)
+
+	tests := []struct {
+		name       string
+		config     ProxyConfig
+		servers    []ProxyUrl
+		methods    []string
+		header     string
+		statusCode int
+	}{
+		// the connection was refused, the body was never sent
+		{name: "dead backend", servers: []ProxyUrl{{Name: "dead", Url: newDeadBackend()}, {Name: "alive", Url: backend.URL}}, statusCode: http.StatusOK},
+		{name: "temp file", config: ProxyConfig{BodyMemoryLimit: 10}, servers: []ProxyUrl{{Name: "dead", Url: newDeadBackend()}, {Name: "alive", Url: backend.URL}}, statusCode: http.StatusOK},
+
+		// the backend received the body, only idempotent requests are sent again
+		{name: "not idempotent", servers: []ProxyUrl{{Name: "reset", Url: reset.URL}, {Name: "alive", Url: backend.URL}}, statusCode: http.StatusBadGateway},
+		{name: "idempotent route", servers: []ProxyUrl{{Name: "reset", Url: reset.URL}, {Name: "alive", Url: backend.URL}}, methods: []string{"post"}, statusCode: http.StatusOK},
+		{name: "idempotency key", servers: []ProxyUrl{{Name: "reset", Url: reset.URL}, {Name: "alive", Url: backend.URL}}, header: "key", statusCode: http.StatusOK},
+		{name: "idempotent config", config: ProxyConfig{IdempotentMethods: []string{http.MethodPost}}, servers: []ProxyUrl{{Name: "reset", Url: reset.URL}, {Name: "alive", Url: backend.URL}}, statusCode: http.StatusOK},
+
+		// the body is bigger than the buffer and was streamed to the first backend
+		{name: "not replayable", config: ProxyConfig{BodyMemoryLimit: 10, BodyMaxBuffer: 100}, servers: []ProxyUrl{{Name: "dead", Url: newDeadBackend()}, {Name: "alive", Url: backend.URL}}, statusCode: http.StatusBadGateway},
+	}
+
+	for _, test := range tests {
+		test.config.TempDir = t.TempDir()
+		test.config.Routes = []ProxyRoute{{
+			Name:              "calculate",
+			Path:              ProxyPath{Path: "/calculate", Method: http.MethodPost},
+			ProxyEnable:       true,
+			ProxyServers:      test.servers,
+			IdempotentMethods: test.methods,
+		}}
+
+		proxy, err := New(test.config)
+		if err != nil {
+			t.Logf("%v: New().error: %v", test.name, err)
+			t.FailNow()
+		}
+
+		r := httptest.NewRequest(http.MethodPost, "/calculate", strings.NewReader(payload))
+		if test.header != "" {
+			r.Header.Set("Idempotency-Key", test.header)
+		}
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, r)
+
+		if w.Code != test.statusCode {
+			t.Logf("%v: status code %v, body: %v", test.name, w.Code, w.Body.String())
+			t.FailNow()
+		}
+
+		if w.Code == http.StatusOK && w.Body.String() != "backend POST /calculate "+payload {
+			t.Logf("%v: the body must be sent again: %v", test.name, w.Body.String())
+			t.FailNow()
+		}
+
+		// the temp files are removed
+		if files, _ := os.ReadDir(test.config.TempDir); len(files) != 0 {
+			t.Logf("%v: temp files: %v", test.name, len(files))
+			t.FailNow()
+		}
+	}
+}
+
+func TestProxy_ClientGone(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer slow.Close()
+	backend := newBackendServer("backend")
+	defer backend.Close()
+
+	proxy, err := New(ProxyConfig{
+		ConsecutiveErrorsToDisable: 1,
+		Routes: []ProxyRoute{{
+			Name:           "flights",
+			ProxyEnable:    true,
+			ProxyServers:   []ProxyUrl{{Name: "slow", Url: slow.URL}, {Name: "backend", Url: backend.URL}},
+			CircuitBreaker: ProxyCircuitBreaker{ErrorRate: 0.5, MinRequests: 1, OpenTime: time.Minute, HalfOpenRequests: 1},
+		}},
+	})
+	if err != nil {
+		t.Logf("New().error: %v", err)
+		t.FailNow()
+	}
+
+	// the client gives up waiting for the slow backend, and then before the request is sent
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	proxy.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/route", nil).WithContext(ctx))
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	proxy.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/route", nil).WithContext(ctx))
+
+	// the requests aren't failures of the backends and aren't retried
+	for _, statistics := range proxy.Statistics()[0].ProxyServers {
+		if statistics.ErrorCounter != 0 || statistics.UsedSuccessfully != 0 || !statistics.Enabled || statistics.Circuit != CircuitClosed {
+			t.Logf("the backends must not be measured: %+v", statistics)
+			t.FailNow()
+		}
+	}
+
+	// the next request uses the backends as usual
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/route", nil))
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Body.String(), "backend GET /route") {
+		t.Logf("status code %v, body: %v", w.Code, w.Body.String())
+		t.FailNow()
+	}
+}
+
+func TestRoute_match(t *testing.T) {
+	route, err := newRoute(ProxyRoute{
+		Name:   "reports",
+		Domain: ProxyDomain{HostExpReg: `^(?P<tenant>[a-z]+)\.example\.com$`},
+		Path:   ProxyPath{ExpReg: `^/reports/(?P<id>[0-9]+)$`, Method: http.MethodGet, Headers: map[string]string{"X-Admin": "^yes$"}},
+		Handle: ProxyHandle{Name: "reports", Handle: func(w ProxyResponseWriter, r *ProxyRequest) {}},
+	}, ProxyConfig{})
+	if err != nil {
+		t.Logf("newRoute().error: %v", err)
+		t.FailNow()
+	}
+
+	tests := []struct {
+		name    string
+		method  string
+		host    string
+		path    string
+		admin   string
+		match   bool
+		matches map[string]string
+	}{
+		{name: "match", method: http.MethodGet, host: "acme.example.com", path: "/reports/10", admin: "yes", match: true, matches: map[string]string{"tenant": "acme", "id": "10"}},
+		{name: "method", method: http.MethodPost, host: "acme.example.com", path: "/reports/10", admin: "yes"},
+		{name: "header", method: http.MethodGet, host: "acme.example.com", path: "/reports/10", admin: "no"},
+		{name: "path", method: http.MethodGet, host: "acme.example.com", path: "/reports/last", admin: "yes"},
+		{name: "host", method: http.MethodGet, host: "acme.example.org", path: "/reports/10", admin: "yes"},
+	}
+
+	// a rejected route doesn't leave captures in the request
+	for _, test := range tests {
+		r := httptest.NewRequest(test.method, test.path, nil)
+		r.Header.Set("X-Admin", test.admin)
+		request := &ProxyRequest{Request: r, ExpRegMatches: make(map[string]string)}
+
+		if match := route.match(request, test.host, ""); match != test.match || len(request.ExpRegMatches) != len(test.matches) {
+			t.Logf("%v: match %v, captures %v", test.name, match, request.ExpRegMatches)
+			t.FailNow()
+		}
+
+		for name, value := range test.matches {
+			if request.ExpRegMatches[name] != value {
+				t.Logf("%v: captures %v", test.name, request.ExpRegMatches)
+				t.FailNow()
+			}
+		}
+	}
+}
+
+func TestProxy_Routes(t *testing.T) {
+	proxy, err := New(ProxyConfig{Routes: []ProxyRoute{{Name: "flights", ProxyEnable: true, ProxyServers: []ProxyUrl{{Name: "one", Url: "http://localhost:8081"}}}}})
+	if err != nil {
+		t.Logf("New().error: %v", err)
+		t.FailNow()
+	}
+
+	if err = proxy.AddRoute(ProxyRoute{Name: "flights", ProxyEnable: true, ProxyServers: []ProxyUrl{{Name: "two", Url: "http://localhost:8082"}}}); !errors.Is(err, ErrRouteExists) {
+		t.Logf("AddRoute() must reject a duplicated name: %v", err)
+		t.FailNow()
+	}
+
+	if err = proxy.AddRoute(ProxyRoute{Name: "empty", ProxyEnable: true}); err == nil {
+		t.Log("AddRoute() must reject a proxy route without backends")
+		t.FailNow()
+	}
+
+	if err = proxy.DeleteRoute("unknown"); !errors.Is(err, ErrRouteNotFound) {
+		t.Logf("DeleteRoute() must fail for an unknown route: %v", err)
+		t.FailNow()
+	}
+
+	if err = proxy.AddRoute(ProxyRoute{Name: "news", ProxyEnable: true, ProxyServers: []ProxyUrl{{Name: "two", Url: "http://localhost:8082"}}}); err != nil || len(proxy.Config().Routes) != 2 {
+		t.Logf("AddRoute().error: %v", err)
+		t.FailNow()
+	}
+
+	if err = proxy.DeleteRoute("flights"); err != nil || len(proxy.Config().Routes) != 1 || proxy.Statistics()[0].Name != "news" {
+		t.Logf("DeleteRoute().error: %v", err)
+		t.FailNow()
+	}
+
+	if _, err = New(ProxyConfig{Routes: []ProxyRoute{{Name: "regexp", Path: ProxyPath{ExpReg: "("}}}}); err == nil {
+		t.Log("New() must reject an invalid regular expression")
+		t.FailNow()
+	}
+}
+
+// TestProxy_Concurrent sends requests from many goroutines while the routes change and the statistics are read.
+// Run with go test -race
+func TestProxy_Concurrent(t *testing.T) {
+	backends := make([]ProxyUrl, 0)
+	for k := 0; k < 3; k += 1 {
+		server := newBackendServer("backend")
+		defer server.Close()
+		backends = append(backends, ProxyUrl{Name: fmt.Sprintf("backend %v", k), Url: server.URL})
+	}
+	backends = append(backends, ProxyUrl{Name: "dead", Url: newDeadBackend()})
+
+	proxy, err := New(ProxyConfig{
+		ConsecutiveErrorsToDisable: 3,
+		TimeToKeepDisabled:         time.Millisecond,
+		TimeToVerifyDisabled:       time.Millisecond,
+		Routes:                     []ProxyRoute{{Name: "flights", Path: ProxyPath{Path: "/calculate"}, ProxyEnable: true, ProxyServers: backends}},
+	})
+	if err != nil {
+		t.Logf("New().error: %v", err)
+		t.FailNow()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go proxy.VerifyDisabled(ctx)
+
+	// changes the routes and reads the statistics while the requests are running
+	var changes sync.WaitGroup
+	changes.Add(1)
+	go func() {
+		defer changes.Done()
+		for k := 0; ctx.Err() == nil; k += 1 {
+			name := fmt.Sprintf("route %v", k)
+			_ = proxy.AddRoute(ProxyRoute{Name: name, Path: ProxyPath{Path: "/" + name}, ProxyEnable: true, ProxyServers: backends[:1]})
+			_ = proxy.Statistics()
+			_ = proxy.DeleteRoute(name)
+		}
+	}()
+
+	const goroutines, requests = 20, 25
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines*requests)
+	for g := 0; g < goroutines; g += 1 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < requests; i += 1 {
+				w := httptest.NewRecorder()
+				proxy.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/calculate", nil))
+				if w.Code != http.StatusOK {
+					errs <- fmt.Errorf("status code %v, body: %v", w.Code, w.Body.String())
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	cancel()
+	changes.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Logf("concurrent request: %v", err)
+		t.FailNow()
+	}
+
+	var successful int64
+	for _, backend := range proxy.Statistics()[0].ProxyServers {
+		successful += backend.UsedSuccessfully
+	}
+	if successful != goroutines*requests {
+		t.Logf("the backends answered %v requests, expected %v", successful, goroutines*requests)
+		t.FailNow()
+	}
+}
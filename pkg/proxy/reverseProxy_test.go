@@ -0,0 +1,120 @@
+package proxy
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewBufferPool(t *testing.T) {
+	pool := NewBufferPool(1024)
+
+	buffer := pool.Get()
+	if len(*buffer) != 1024 {
+		t.Logf("len(buffer): %v", len(*buffer))
+		t.FailNow()
+	}
+
+	(*buffer)[0] = 'x'
+	pool.Put(buffer)
+	if buffer = pool.Get(); len(*buffer) != 1024 {
+		t.Logf("len(buffer): %v", len(*buffer))
+		t.FailNow()
+	}
+}
+
+func TestProxy_Stream(t *testing.T) {
+	// the backend sends the second line only after the client received the first one
+	received := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("first\n"))
+		w.(http.Flusher).Flush()
+
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+		}
+		_, _ = w.Write([]byte("second\n"))
+	}))
+	defer backend.Close()
+
+	proxy, err := New(ProxyConfig{
+		BufferSize:    16,
+		FlushInterval: 5 * time.Millisecond,
+		Routes:        []ProxyRoute{{Name: "events", ProxyEnable: true, ProxyServers: []ProxyUrl{{Name: "backend", Url: backend.URL}}}},
+	})
+	if err != nil {
+		t.Logf("New().error: %v", err)
+		t.FailNow()
+	}
+
+	server := httptest.NewServer(proxy)
+	defer server.Close()
+
+	start := time.Now()
+	response, err := http.Get(server.URL + "/events")
+	if err != nil {
+		t.Logf("http.Get().error: %v", err)
+		t.FailNow()
+	}
+	defer response.Body.Close()
+
+	reader := bufio.NewReader(response.Body)
+	if line, err := reader.ReadString('\n'); err != nil || line != "first\n" {
+		t.Logf("first line: %q, error: %v", line, err)
+		t.FailNow()
+	}
+
+	if time.Since(start) > 2*time.Second {
+		t.Log("the first line must be streamed before the backend ends the response")
+		t.FailNow()
+	}
+	close(received)
+
+	if line, err := reader.ReadString('\n'); err != nil || line != "second\n" {
+		t.Logf("second line: %q, error: %v", line, err)
+		t.FailNow()
+	}
+}
+
+func TestProxy_Truncated(t *testing.T) {
+	// the backend closes the connection in the middle of the body
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, buffer, _ := w.(http.Hijacker).Hijack()
+		_, _ = buffer.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 100\r\n\r\npartial")
+		_ = buffer.Flush()
+		_ = conn.Close()
+	}))
+	defer backend.Close()
+
+	proxy, err := New(ProxyConfig{
+		Routes: []ProxyRoute{{Name: "flights", ProxyEnable: true, ProxyServers: []ProxyUrl{{Name: "backend", Url: backend.URL}}}},
+	})
+	if err != nil {
+		t.Logf("New().error: %v", err)
+		t.FailNow()
+	}
+
+	server := httptest.NewServer(proxy)
+	defer server.Close()
+
+	// the connection is closed, the client receives an error in the headers or in the body
+	response, err := http.Get(server.URL + "/calculate")
+	if err == nil {
+		_, err = io.ReadAll(response.Body)
+		_ = response.Body.Close()
+	}
+
+	if err == nil {
+		t.Log("the truncated response must fail in the client")
+		t.FailNow()
+	}
+
+	if statistics := proxy.Statistics()[0].ProxyServers[0]; statistics.ErrorCounter != 1 || statistics.UsedSuccessfully != 0 {
+		t.Logf("the truncated response is a failure of the backend: %+v", statistics)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,197 @@
+package proxy
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// templatePart literal text or variable of a template
+type templatePart struct {
+	literal  string
+	variable string
+}
+
+// template compiled template of a rewrite. The variables are {clientIp}, {requestId}, {host}, {method}, {path} and
+// {param.name}, the captures of ProxyRequest.ExpRegMatches
+type template []templatePart
+
+// parseTemplate compiles the template. Unknown variables are errors
+func parseTemplate(text string) (e template, err error) {
+	for text != "" {
+		start := strings.Index(text, "{")
+		if start < 0 {
+			return append(e, templatePart{literal: text}), nil
+		}
+
+		end := strings.Index(text[start:], "}")
+		if end < 0 {
+			return nil, fmt.Errorf("template %v: the variable isn't closed", text)
+		}
+		end += start
+
+		variable := text[start+1 : end]
+		switch {
+		case variable == "clientIp", variable == "requestId", variable == "host", variable == "method", variable == "path":
+		case strings.HasPrefix(variable, "param.") && len(variable) > len("param."):
+		default:
+			return nil, fmt.Errorf("template %v: unknown variable {%v}", text, variable)
+		}
+
+		if start > 0 {
+			e = append(e, templatePart{literal: text[:start]})
+		}
+		e = append(e, templatePart{variable: variable})
+		text = text[end+1:]
+	}
+	return
+}
+
+// expand returns the text of the template for the request
+func (e template) expand(request *ProxyRequest) string {
+	var text strings.Builder
+	for _, part := range e {
+		switch part.variable {
+		case "":
+			text.WriteString(part.literal)
+		case "clientIp":
+			text.WriteString(clientIP(request.Request))
+		case "requestId":
+			text.WriteString(request.requestID())
+		case "host":
+			text.WriteString(request.Host)
+		case "method":
+			text.WriteString(request.Method)
+		case "path":
+			text.WriteString(request.URL.Path)
+		default:
+			text.WriteString(request.ExpRegMatches[strings.TrimPrefix(part.variable, "param.")])
+		}
+	}
+	return text.String()
+}
+
+// clientIP returns the ip of the client, without the port
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// requestID returns the X-Request-Id of the request, or a random id created once for the request
+func (e *ProxyRequest) requestID() string {
+	if e.RequestID != "" {
+		return e.RequestID
+	}
+
+	if e.RequestID = e.Header.Get("X-Request-Id"); e.RequestID == "" {
+		id := make([]byte, 16)
+		_, _ = rand.Read(id)
+		e.RequestID = hex.EncodeToString(id)
+	}
+	return e.RequestID
+}
+
+// rewriter compiled rewrites of a route
+type rewriter struct {
+	config          ProxyRewrite
+	expReg          *regexp.Regexp
+	host            template
+	requestHeaders  map[string]template
+	responseHeaders map[string]template
+}
+
+// newRewriter compiles the rewrites. Returns nil when the route doesn't have rewrites
+func newRewriter(config ProxyRewrite) (e *rewriter, err error) {
+	if config.StripPrefix == "" && config.AddPrefix == "" && config.ExpReg == "" && config.Host == "" &&
+		len(config.RequestHeaders) == 0 && len(config.RemoveRequestHeaders) == 0 &&
+		len(config.ResponseHeaders) == 0 && len(config.RemoveResponseHeaders) == 0 {
+		return nil, nil
+	}
+
+	e = &rewriter{config: config}
+	if config.ExpReg != "" {
+		if e.expReg, err = regexp.Compile(config.ExpReg); err != nil {
+			return nil, fmt.Errorf("rewrite expReg: %v", err)
+		}
+	}
+
+	if e.host, err = parseTemplate(config.Host); err != nil {
+		return nil, fmt.Errorf("rewrite host: %v", err)
+	}
+
+	if e.requestHeaders, err = parseHeaderTemplates(config.RequestHeaders); err != nil {
+		return nil, fmt.Errorf("rewrite requestHeaders: %v", err)
+	}
+
+	if e.responseHeaders, err = parseHeaderTemplates(config.ResponseHeaders); err != nil {
+		return nil, fmt.Errorf("rewrite responseHeaders: %v", err)
+	}
+	return
+}
+
+// parseHeaderTemplates compiles the templates of the headers
+func parseHeaderTemplates(headers map[string]string) (templates map[string]template, err error) {
+	templates = make(map[string]template, len(headers))
+	for name, text := range headers {
+		if templates[http.CanonicalHeaderKey(name)], err = parseTemplate(text); err != nil {
+			return nil, fmt.Errorf("%v: %v", name, err)
+		}
+	}
+	return
+}
+
+// path returns the path rewritten: the prefix is stripped, the regular expression is replaced and the prefix is added
+func (e *rewriter) path(path string) string {
+	// the prefix is removed only at a segment boundary, /api doesn't change /apiary
+	if prefix := strings.TrimSuffix(e.config.StripPrefix, "/"); prefix != "" && (path == prefix || strings.HasPrefix(path, prefix+"/")) {
+		path = strings.TrimPrefix(path, prefix)
+		if path == "" {
+			path = "/"
+		}
+	}
+
+	if e.expReg != nil {
+		path = e.expReg.ReplaceAllString(path, e.config.Replacement)
+	}
+
+	if e.config.AddPrefix != "" {
+		path = strings.TrimSuffix(e.config.AddPrefix, "/") + path
+	}
+	return path
+}
+
+// request rewrites the attempt sent to the backend. The url of the attempt is a copy and the headers are cloned, the
+// request of the client doesn't change
+func (e *rewriter) request(attempt *http.Request, request *ProxyRequest) {
+	attempt.URL.Path = e.path(attempt.URL.Path)
+	attempt.URL.RawPath = ""
+
+	attempt.Header = attempt.Header.Clone()
+	for _, name := range e.config.RemoveRequestHeaders {
+		attempt.Header.Del(name)
+	}
+	for name, value := range e.requestHeaders {
+		attempt.Header.Set(name, value.expand(request))
+	}
+
+	if len(e.host) != 0 {
+		attempt.Host = e.host.expand(request)
+	}
+}
+
+// response rewrites the headers of the response of the backend
+func (e *rewriter) response(response *http.Response, request *ProxyRequest) {
+	for _, name := range e.config.RemoveResponseHeaders {
+		response.Header.Del(name)
+	}
+	for name, value := range e.responseHeaders {
+		response.Header.Set(name, value.expand(request))
+	}
+}
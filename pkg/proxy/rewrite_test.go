@@ -0,0 +1,151 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseTemplate(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "http://www.example.com/v1/jobs/42", nil)
+	r.RemoteAddr = "10.0.0.1:5555"
+	r.Header.Set("X-Request-Id", "abc")
+	request := &ProxyRequest{Request: r, ExpRegMatches: map[string]string{"id": "42"}}
+
+	tests := []struct {
+		template string
+		text     string
+		err      bool
+	}{
+		{template: "", text: ""},
+		{template: "static", text: "static"},
+		{template: "{clientIp}", text: "10.0.0.1"},
+		{template: "id={requestId};", text: "id=abc;"},
+		{template: "{method} {host}{path}", text: "GET www.example.com/v1/jobs/42"},
+		{template: "job-{param.id}-{param.missing}", text: "job-42-"},
+		{template: "{unknown}", err: true},
+		{template: "{param.}", err: true},
+		{template: "{clientIp", err: true},
+	}
+
+	for _, test := range tests {
+		template, err := parseTemplate(test.template)
+		if (err != nil) != test.err {
+			t.Logf("parseTemplate(%v).error: %v", test.template, err)
+			t.FailNow()
+		}
+
+		if err == nil && template.expand(request) != test.text {
+			t.Logf("parseTemplate(%v).expand(): %v, expected: %v", test.template, template.expand(request), test.text)
+			t.FailNow()
+		}
+	}
+}
+
+func TestProxyRequest_requestID(t *testing.T) {
+	request := &ProxyRequest{Request: httptest.NewRequest(http.MethodGet, "/", nil)}
+	id := request.requestID()
+	if len(id) != 32 || request.requestID() != id {
+		t.Logf("requestID() must create the id once: %v, %v", id, request.requestID())
+		t.FailNow()
+	}
+}
+
+func TestRewriter_path(t *testing.T) {
+	tests := []struct {
+		config ProxyRewrite
+		path   string
+		result string
+	}{
+		{config: ProxyRewrite{StripPrefix: "/api"}, path: "/api/v1/calculate", result: "/v1/calculate"},
+		{config: ProxyRewrite{StripPrefix: "/api"}, path: "/api", result: "/"},
+		{config: ProxyRewrite{StripPrefix: "/api"}, path: "/other", result: "/other"},
+		{config: ProxyRewrite{StripPrefix: "/api"}, path: "/apiary/x", result: "/apiary/x"},
+		{config: ProxyRewrite{StripPrefix: "/api/"}, path: "/api/v1/calculate", result: "/v1/calculate"},
+		{config: ProxyRewrite{StripPrefix: "/api/"}, path: "/apiary/x", result: "/apiary/x"},
+		{config: ProxyRewrite{AddPrefix: "/v1/"}, path: "/calculate", result: "/v1/calculate"},
+		{config: ProxyRewrite{StripPrefix: "/api", AddPrefix: "/v2"}, path: "/api/calculate", result: "/v2/calculate"},
+		{config: ProxyRewrite{ExpReg: `^/jobs/([0-9]+)/(?P<action>[a-z]+)$`, Replacement: "/v1/${action}/$1"}, path: "/jobs/42/cancel", result: "/v1/cancel/42"},
+		{config: ProxyRewrite{StripPrefix: "/api", ExpReg: `^/old/`, Replacement: "/new/", AddPrefix: "/v1"}, path: "/api/old/x", result: "/v1/new/x"},
+	}
+
+	for _, test := range tests {
+		rewriter, err := newRewriter(test.config)
+		if err != nil {
+			t.Logf("newRewriter().error: %v", err)
+			t.FailNow()
+		}
+
+		if result := rewriter.path(test.path); result != test.result {
+			t.Logf("path(%v) with %+v: %v, expected: %v", test.path, test.config, result, test.result)
+			t.FailNow()
+		}
+	}
+
+	if rewriter, err := newRewriter(ProxyRewrite{}); rewriter != nil || err != nil {
+		t.Logf("newRewriter() without rewrites must return nil: %v, %v", rewriter, err)
+		t.FailNow()
+	}
+
+	for _, config := range []ProxyRewrite{{ExpReg: "("}, {Host: "{unknown}"}, {RequestHeaders: map[string]string{"X-Id": "{id}"}}, {ResponseHeaders: map[string]string{"X-Id": "{"}}} {
+		if _, err := newRewriter(config); err == nil {
+			t.Logf("newRewriter(%+v) must fail", config)
+			t.FailNow()
+		}
+	}
+}
+
+func TestProxy_Rewrite(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "backend")
+		w.Header().Set("X-Internal", "secret")
+		_, _ = fmt.Fprintf(w, "%v %v real-ip=%v job=%v secret=%v", r.Host, r.URL.Path, r.Header.Get("X-Real-Ip"), r.Header.Get("X-Job"), r.Header.Get("X-Secret"))
+	}))
+	defer backend.Close()
+
+	proxy, err := New(ProxyConfig{Routes: []ProxyRoute{{
+		Name:         "jobs",
+		Path:         ProxyPath{Path: "/api/jobs/{id}"},
+		ProxyEnable:  true,
+		ProxyServers: []ProxyUrl{{Name: "jobs", Url: backend.URL + "/internal"}},
+		Rewrite: ProxyRewrite{
+			StripPrefix:           "/api",
+			ExpReg:                `^/jobs/([0-9]+)$`,
+			Replacement:           "/v1/job/$1",
+			Host:                  "jobs.internal",
+			RequestHeaders:        map[string]string{"X-Real-Ip": "{clientIp}", "X-Job": "{param.id}"},
+			RemoveRequestHeaders:  []string{"X-Secret"},
+			ResponseHeaders:       map[string]string{"X-Request-Id": "{requestId}", "X-Route": "{method} {path}"},
+			RemoveResponseHeaders: []string{"Server", "X-Internal"},
+		},
+	}}})
+	if err != nil {
+		t.Logf("New().error: %v", err)
+		t.FailNow()
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "http://www.example.com/api/jobs/42", nil)
+	r.RemoteAddr = "10.0.0.1:5555"
+	r.Header.Set("X-Secret", "client")
+	r.Header.Set("X-Request-Id", "abc")
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, r)
+
+	if body := w.Body.String(); w.Code != http.StatusOK || body != "jobs.internal /internal/v1/job/42 real-ip=10.0.0.1 job=42 secret=" {
+		t.Logf("ServeHTTP(): status code %v, body: %v", w.Code, body)
+		t.FailNow()
+	}
+
+	header := w.Header()
+	if header.Get("Server") != "" || header.Get("X-Internal") != "" || header.Get("X-Request-Id") != "abc" || header.Get("X-Route") != "GET /api/jobs/42" {
+		t.Logf("ServeHTTP().header: %v", header)
+		t.FailNow()
+	}
+
+	if r.URL.Path != "/api/jobs/42" || r.Header.Get("X-Secret") != "client" || !strings.HasSuffix(r.Host, "example.com") {
+		t.Logf("the request of the client must not change: %v %v %v", r.Host, r.URL.Path, r.Header)
+		t.FailNow()
+	}
+}
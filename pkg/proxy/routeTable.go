@@ -0,0 +1,177 @@
+package proxy
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// routeEntry route in a node of the route table
+type routeEntry struct {
+	route *route
+
+	// order position of the route in the configuration, the first route wins between routes of the same priority
+	order int
+
+	// params names of the {param} segments of the path, by position
+	params map[int]string
+}
+
+// routeNode node of the route table, one for each segment of the paths
+type routeNode struct {
+	static map[string]*routeNode
+	param  *routeNode
+
+	// exact routes of the path that ends in the node
+	exact []*routeEntry
+
+	// prefix routes of every path that starts with the node, including the regular expressions with this literal prefix
+	prefix []*routeEntry
+}
+
+// child returns the child of the segment, created when it doesn't exist
+func (e *routeNode) child(segment string) *routeNode {
+	if isPathParam(segment) {
+		if e.param == nil {
+			e.param = &routeNode{}
+		}
+		return e.param
+	}
+
+	if e.static == nil {
+		e.static = make(map[string]*routeNode)
+	}
+	if e.static[segment] == nil {
+		e.static[segment] = &routeNode{}
+	}
+	return e.static[segment]
+}
+
+// collect appends the routes of the nodes of the path. The static and the {param} children are both followed
+func (e *routeNode) collect(segments []string, depth int, entries []*routeEntry) []*routeEntry {
+	entries = append(entries, e.prefix...)
+	if depth == len(segments) {
+		return append(entries, e.exact...)
+	}
+
+	if child := e.static[segments[depth]]; child != nil {
+		entries = child.collect(segments, depth+1, entries)
+	}
+
+	if e.param != nil && segments[depth] != "" {
+		entries = e.param.collect(segments, depth+1, entries)
+	}
+	return entries
+}
+
+// routeTable routes of a snapshot compiled in a trie of the path segments. A request only evaluates the routes of the
+// nodes of its path, so the cost of the lookup doesn't depend on the number of routes
+type routeTable struct {
+	root *routeNode
+}
+
+// newRouteTable compiles the routes. Routes without local handler and without proxy are never used and are left out
+func newRouteTable(routes []*route) *routeTable {
+	e := &routeTable{root: &routeNode{}}
+	for order, route := range routes {
+		if route.config.Handle.Handle == nil && !route.config.ProxyEnable {
+			continue
+		}
+
+		entry := &routeEntry{route: route, order: order}
+		path, prefix := route.tablePath()
+
+		node := e.root
+		for k, segment := range pathSegments(path, prefix) {
+			if isPathParam(segment) {
+				if entry.params == nil {
+					entry.params = make(map[int]string)
+				}
+				entry.params[k] = segment[1 : len(segment)-1]
+			}
+			node = node.child(segment)
+		}
+
+		if prefix {
+			node.prefix = append(node.prefix, entry)
+		} else {
+			node.exact = append(node.exact, entry)
+		}
+	}
+	return e
+}
+
+// lookup returns the route of the request with the highest priority, the first one of the configuration between
+// routes of the same priority, and fills in the captures of the path. Returns nil when no route matches
+func (e *routeTable) lookup(request *ProxyRequest, host, port string) *route {
+	segments := pathSegments(request.URL.Path, false)
+	entries := e.root.collect(segments, 0, nil)
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].route.config.Priority != entries[j].route.config.Priority {
+			return entries[i].route.config.Priority > entries[j].route.config.Priority
+		}
+		return entries[i].order < entries[j].order
+	})
+
+	for _, entry := range entries {
+		if !entry.route.match(request, host, port) {
+			continue
+		}
+
+		for k, name := range entry.params {
+			request.ExpRegMatches[name] = segments[k]
+		}
+		return entry.route
+	}
+	return nil
+}
+
+// tablePath returns the path of the route in the table, and if it is a prefix. A regular expression anchored at the
+// beginning is a prefix of its literal segments, the others are evaluated for every path
+func (e *route) tablePath() (path string, prefix bool) {
+	switch {
+	case e.expReg != nil:
+		if !strings.HasPrefix(e.config.Path.ExpReg, "^") {
+			return "", true
+		}
+
+		literal, _ := e.expReg.LiteralPrefix()
+		if k := strings.LastIndex(literal, "/"); k > 0 {
+			return literal[:k], true
+		}
+		return "", true
+	case e.config.Path.Path != "":
+		return e.config.Path.Path, false
+	}
+	return e.config.Path.Prefix, true
+}
+
+// pathSegments returns the segments of the path. The segments of a prefix don't have the final empty segment
+func pathSegments(path string, prefix bool) []string {
+	path = strings.TrimPrefix(path, "/")
+	if prefix {
+		path = strings.TrimSuffix(path, "/")
+		if path == "" {
+			return nil
+		}
+	}
+	return strings.Split(path, "/")
+}
+
+// isPathParam returns true for the {param} segments of the path templates
+func isPathParam(segment string) bool {
+	return len(segment) > 2 && segment[0] == '{' && segment[len(segment)-1] == '}'
+}
+
+// compileHeaders compiles the header matchers of the path
+func compileHeaders(headers map[string]string) (compiled map[string]*regexp.Regexp, err error) {
+	compiled = make(map[string]*regexp.Regexp, len(headers))
+	for name, expReg := range headers {
+		if compiled[name], err = regexp.Compile(expReg); err != nil {
+			return nil, fmt.Errorf("header %v: %v", name, err)
+		}
+	}
+	return
+}
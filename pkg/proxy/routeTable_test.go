@@ -0,0 +1,126 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newTestTable returns the table of the routes, each one with a local handler
+func newTestTable(t testing.TB, configs []ProxyRoute) *routeTable {
+	routes := make([]*route, 0, len(configs))
+	for _, config := range configs {
+		config.Handle = ProxyHandle{Name: config.Name, Handle: func(ProxyResponseWriter, *ProxyRequest) {}}
+		route, err := newRoute(config, ProxyConfig{})
+		if err != nil {
+			t.Logf("newRoute().error: %v", err)
+			t.FailNow()
+		}
+		routes = append(routes, route)
+	}
+	return newRouteTable(routes)
+}
+
+func TestRouteTable_lookup(t *testing.T) {
+	table := newTestTable(t, []ProxyRoute{
+		{Name: "root", Path: ProxyPath{Path: "/"}},
+		{Name: "calculate", Path: ProxyPath{Path: "/calculate", Method: http.MethodPost}},
+		{Name: "calculate json", Path: ProxyPath{Path: "/calculate", Method: http.MethodPut, Headers: map[string]string{"Content-Type": "^application/json"}}},
+		{Name: "job", Path: ProxyPath{Path: "/v1/jobs/{id}"}},
+		{Name: "job result", Path: ProxyPath{Path: "/v1/jobs/{id}/result"}},
+		{Name: "jobs list", Path: ProxyPath{Path: "/v1/jobs/list"}, Priority: 1},
+		{Name: "v1", Path: ProxyPath{Prefix: "/v1"}},
+		{Name: "v1 admin", Path: ProxyPath{Prefix: "/v1/admin/"}, Priority: 10},
+		{Name: "hello", Path: ProxyPath{ExpReg: `^/hello/(?P<module>[a-z]+)/(?P<site>[a-z]+)$`}},
+		{Name: "images", Path: ProxyPath{ExpReg: `\.(?P<extension>png|jpg)$`}},
+		{Name: "fallback", Path: ProxyPath{Prefix: "/"}, Priority: -1},
+	})
+
+	tests := []struct {
+		method  string
+		path    string
+		header  string
+		route   string
+		matches map[string]string
+	}{
+		{method: http.MethodGet, path: "/", route: "root"},
+		{method: http.MethodPost, path: "/calculate", route: "calculate"},
+		{method: http.MethodPut, path: "/calculate", header: "application/json; charset=utf-8", route: "calculate json"},
+		{method: http.MethodPut, path: "/calculate", header: "text/csv", route: "fallback"},
+		{method: http.MethodGet, path: "/calculate", route: "fallback"},
+		{method: http.MethodGet, path: "/calculate/", route: "fallback"},
+
+		// the exact and the template routes are before the prefix in the configuration
+		{method: http.MethodGet, path: "/v1/jobs/42", route: "job", matches: map[string]string{"id": "42"}},
+		{method: http.MethodGet, path: "/v1/jobs/42/result", route: "job result", matches: map[string]string{"id": "42"}},
+		{method: http.MethodGet, path: "/v1/jobs/", route: "v1"},
+		{method: http.MethodGet, path: "/v1/jobs/list", route: "jobs list"},
+		{method: http.MethodGet, path: "/v1", route: "v1"},
+		{method: http.MethodGet, path: "/v1/calculate", route: "v1"},
+		{method: http.MethodGet, path: "/v10", route: "fallback"},
+		{method: http.MethodGet, path: "/v1/admin", route: "v1 admin"},
+		{method: http.MethodGet, path: "/v1/admin/logo.png", route: "v1 admin"},
+
+		{method: http.MethodGet, path: "/hello/flights/docs", route: "hello", matches: map[string]string{"module": "flights", "site": "docs"}},
+		{method: http.MethodGet, path: "/hello/flights", route: "fallback"},
+		{method: http.MethodGet, path: "/static/logo.png", route: "images", matches: map[string]string{"extension": "png"}},
+		{method: http.MethodGet, path: "/v1/logo.png", route: "v1"},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(test.method, test.path, nil)
+		if test.header != "" {
+			r.Header.Set("Content-Type", test.header)
+		}
+		request := &ProxyRequest{Request: r, ExpRegMatches: make(map[string]string)}
+
+		route := table.lookup(request, "example.com", "")
+		if route == nil || route.config.Name != test.route {
+			t.Logf("%v %v: route %+v, want %v", test.method, test.path, route, test.route)
+			t.FailNow()
+		}
+
+		if len(request.ExpRegMatches) != len(test.matches) {
+			t.Logf("%v %v: matches %v, want %v", test.method, test.path, request.ExpRegMatches, test.matches)
+			t.FailNow()
+		}
+		for name, value := range test.matches {
+			if request.ExpRegMatches[name] != value {
+				t.Logf("%v %v: matches %v, want %v", test.method, test.path, request.ExpRegMatches, test.matches)
+				t.FailNow()
+			}
+		}
+	}
+
+	// without the fallback, a path without routes isn't found
+	table = newTestTable(t, []ProxyRoute{{Name: "calculate", Path: ProxyPath{Path: "/calculate"}}})
+	request := &ProxyRequest{Request: httptest.NewRequest(http.MethodGet, "/other", nil), ExpRegMatches: make(map[string]string)}
+	if route := table.lookup(request, "example.com", ""); route != nil {
+		t.Logf("route %v must not match", route.config.Name)
+		t.FailNow()
+	}
+}
+
+func BenchmarkRouteTable_lookup(b *testing.B) {
+	for _, size := range []int{10, 100, 1000, 10000} {
+		configs := make([]ProxyRoute, 0, size)
+		for i := 0; i < size; i += 1 {
+			configs = append(configs, ProxyRoute{Name: fmt.Sprintf("route%v", i), Path: ProxyPath{Path: fmt.Sprintf("/service%v/v1/items/{id}", i), Method: http.MethodGet}})
+		}
+		table := newTestTable(b, configs)
+
+		// the last route, that a linear search would find after all the others
+		r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/service%v/v1/items/42", size-1), nil)
+
+		b.Run(fmt.Sprintf("%v routes", size), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i += 1 {
+				request := &ProxyRequest{Request: r, ExpRegMatches: make(map[string]string)}
+				if table.lookup(request, "example.com", "") == nil {
+					b.FailNow()
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// hostMatcher compiled virtual host of a route. An empty matcher matches every host
+type hostMatcher struct {
+	// exact [optional] host without port. Ex.: api.example.com
+	exact string
+
+	// suffix [optional] domain of a wildcard host, with the dot. Ex.: .example.com for *.example.com
+	suffix string
+
+	// expReg [optional] regular expression of the host without port
+	expReg *regexp.Regexp
+
+	// port [optional] port of the host. Empty matches every port
+	port string
+}
+
+// newHostMatcher compiles the host of the domain. Host accepts exact and wildcard hosts, HostExpReg a regular
+// expression, and SubDomain and Domain are joined in an exact host. Without them, the route matches every host
+func newHostMatcher(domain ProxyDomain) (e *hostMatcher, err error) {
+	e = &hostMatcher{port: domain.Port}
+
+	host := strings.ToLower(strings.TrimSuffix(domain.Host, "."))
+	if host == "" && domain.Domain != "" {
+		host = strings.ToLower(domain.Domain)
+		if domain.SubDomain != "" {
+			host = strings.ToLower(domain.SubDomain) + "." + host
+		}
+	}
+
+	if host != "" && domain.HostExpReg != "" {
+		return nil, fmt.Errorf("host %v: a domain must have a host or a regular expression, not both", host)
+	}
+
+	// the port can be in the host. Ex.: *.example.com:8080
+	if name, port, splitErr := net.SplitHostPort(host); splitErr == nil {
+		if e.port != "" && e.port != port {
+			return nil, fmt.Errorf("host %v: the port is different of %v", host, e.port)
+		}
+		host, e.port = name, port
+	}
+
+	switch {
+	case domain.HostExpReg != "":
+		if e.expReg, err = regexp.Compile(domain.HostExpReg); err != nil {
+			return nil, fmt.Errorf("hostExpReg: %v", err)
+		}
+	case strings.HasPrefix(host, "*."):
+		e.suffix = host[1:]
+	case strings.Contains(host, "*"):
+		return nil, fmt.Errorf("host %v: the wildcard must be the first label. Ex.: *.example.com", host)
+	default:
+		e.exact = host
+	}
+	return
+}
+
+// any returns true when the matcher matches every host and port
+func (e *hostMatcher) any() bool {
+	return e.exact == "" && e.suffix == "" && e.expReg == nil && e.port == ""
+}
+
+// match returns true when the host and the port match, and fills in the named groups of the regular expression
+func (e *hostMatcher) match(host, port string, request *ProxyRequest) bool {
+	if e.port != "" && e.port != port {
+		return false
+	}
+
+	switch {
+	case e.exact != "":
+		return e.exact == host
+	case e.suffix != "":
+		return len(host) > len(e.suffix) && strings.HasSuffix(host, e.suffix)
+	case e.expReg != nil:
+		matches := e.expReg.FindStringSubmatch(host)
+		if matches == nil {
+			return false
+		}
+
+		if request != nil {
+			for k, name := range e.expReg.SubexpNames() {
+				if k != 0 && name != "" {
+					request.ExpRegMatches[name] = matches[k]
+				}
+			}
+		}
+		return true
+	}
+	return true
+}
+
+// splitHost returns the host in lower case, without the final dot, and the port of the Host header
+func splitHost(hostPort string) (host, port string) {
+	host = hostPort
+	if name, p, err := net.SplitHostPort(hostPort); err == nil {
+		host, port = name, p
+	}
+	return strings.TrimSuffix(strings.ToLower(host), "."), port
+}
+
+// virtualHosts virtual hosts of a snapshot, used to send the requests of unknown hosts to the default host
+type virtualHosts struct {
+	matchers    []*hostMatcher
+	defaultHost string
+	defaultPort string
+
+	// notFoundRoutes routes with a not found page, in the order of the configuration
+	notFoundRoutes []*route
+}
+
+// newVirtualHosts returns the virtual hosts of the routes
+func newVirtualHosts(routes []*route, defaultHost string) *virtualHosts {
+	e := &virtualHosts{}
+	e.defaultHost, e.defaultPort = splitHost(defaultHost)
+
+	for _, route := range routes {
+		if !route.host.any() {
+			e.matchers = append(e.matchers, route.host)
+		}
+
+		if route.config.Domain.NotFoundHandle != nil {
+			e.notFoundRoutes = append(e.notFoundRoutes, route)
+		}
+	}
+	return e
+}
+
+// notFound returns the not found page of the first domain of the host, or defaultHandle when there is none
+func (e *virtualHosts) notFound(host, port string, defaultHandle ProxyHandlerFunc) ProxyHandlerFunc {
+	for _, route := range e.notFoundRoutes {
+		if route.host.match(host, port, nil) {
+			return route.config.Domain.NotFoundHandle
+		}
+	}
+	return defaultHandle
+}
+
+// resolve returns the host and the port used to match the routes: the host of the request when a route has it, the
+// default host otherwise
+func (e *virtualHosts) resolve(hostPort string) (host, port string) {
+	host, port = splitHost(hostPort)
+	if e.defaultHost == "" {
+		return
+	}
+
+	for _, matcher := range e.matchers {
+		if matcher.match(host, port, nil) {
+			return
+		}
+	}
+
+	if e.defaultPort != "" {
+		port = e.defaultPort
+	}
+	return e.defaultHost, port
+}
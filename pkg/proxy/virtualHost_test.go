@@ -0,0 +1,153 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNewHostMatcher(t *testing.T) {
+	tests := []struct {
+		domain ProxyDomain
+		err    bool
+	}{
+		{domain: ProxyDomain{}},
+		{domain: ProxyDomain{Host: "api.example.com"}},
+		{domain: ProxyDomain{Host: "*.example.com:8080"}},
+		{domain: ProxyDomain{Host: "example.com:8080", Port: "8080"}},
+		{domain: ProxyDomain{HostExpReg: `^[a-z]+\.example\.com$`}},
+		{domain: ProxyDomain{SubDomain: "blog", Domain: "example.com"}},
+		{domain: ProxyDomain{Host: "example.com:8080", Port: "9090"}, err: true},
+		{domain: ProxyDomain{Host: "api.*.com"}, err: true},
+		{domain: ProxyDomain{HostExpReg: `^(`}, err: true},
+		{domain: ProxyDomain{Host: "example.com", HostExpReg: `^example\.com$`}, err: true},
+	}
+
+	for _, test := range tests {
+		if _, err := newHostMatcher(test.domain); (err != nil) != test.err {
+			t.Logf("%+v: newHostMatcher().error: %v", test.domain, err)
+			t.FailNow()
+		}
+	}
+}
+
+func TestHostMatcher_match(t *testing.T) {
+	tests := []struct {
+		domain  ProxyDomain
+		host    string
+		match   bool
+		matches map[string]string
+	}{
+		{domain: ProxyDomain{}, host: "anything.com:1234", match: true},
+
+		{domain: ProxyDomain{Host: "api.example.com"}, host: "api.example.com", match: true},
+		{domain: ProxyDomain{Host: "api.example.com"}, host: "API.Example.com.:443", match: true},
+		{domain: ProxyDomain{Host: "api.example.com"}, host: "www.example.com", match: false},
+		{domain: ProxyDomain{Host: "API.example.com"}, host: "api.example.com", match: true},
+
+		{domain: ProxyDomain{Host: "*.example.com"}, host: "api.example.com", match: true},
+		{domain: ProxyDomain{Host: "*.example.com"}, host: "v1.api.example.com:8080", match: true},
+		{domain: ProxyDomain{Host: "*.example.com"}, host: "example.com", match: false},
+		{domain: ProxyDomain{Host: "*.example.com"}, host: "badexample.com", match: false},
+
+		{domain: ProxyDomain{Host: "example.com:8080"}, host: "example.com:8080", match: true},
+		{domain: ProxyDomain{Host: "example.com:8080"}, host: "example.com:9090", match: false},
+		{domain: ProxyDomain{Host: "example.com:8080"}, host: "example.com", match: false},
+		{domain: ProxyDomain{Port: "8080"}, host: "other.com:8080", match: true},
+
+		{domain: ProxyDomain{SubDomain: "blog", Domain: "example.com"}, host: "blog.example.com", match: true},
+		{domain: ProxyDomain{SubDomain: "blog", Domain: "example.com"}, host: "example.com", match: false},
+		{domain: ProxyDomain{Domain: "localhost", Port: "9999"}, host: "localhost:9999", match: true},
+
+		{domain: ProxyDomain{HostExpReg: `^(?P<tenant>[a-z]+)\.example\.com$`}, host: "acme.example.com:80", match: true, matches: map[string]string{"tenant": "acme"}},
+		{domain: ProxyDomain{HostExpReg: `^(?P<tenant>[a-z]+)\.example\.com$`}, host: "acme.example.org", match: false},
+	}
+
+	for _, test := range tests {
+		matcher, err := newHostMatcher(test.domain)
+		if err != nil {
+			t.Logf("%+v: newHostMatcher().error: %v", test.domain, err)
+			t.FailNow()
+		}
+
+		request := &ProxyRequest{ExpRegMatches: make(map[string]string)}
+		host, port := splitHost(test.host)
+		if match := matcher.match(host, port, request); match != test.match {
+			t.Logf("%+v: host %v: match %v", test.domain, test.host, match)
+			t.FailNow()
+		}
+
+		for name, value := range test.matches {
+			if request.ExpRegMatches[name] != value {
+				t.Logf("%+v: host %v: matches %v", test.domain, test.host, request.ExpRegMatches)
+				t.FailNow()
+			}
+		}
+	}
+}
+
+func TestProxy_VirtualHosts(t *testing.T) {
+	handle := func(name string) ProxyHandle {
+		return ProxyHandle{Name: name, Handle: func(w ProxyResponseWriter, r *ProxyRequest) {
+			_, _ = fmt.Fprintf(w, "%v %v", name, r.ExpRegMatches["tenant"])
+		}}
+	}
+
+	proxy, err := New(ProxyConfig{
+		DefaultHost: "www.example.com",
+		Routes: []ProxyRoute{
+			{Name: "api", Domain: ProxyDomain{Host: "api.example.com"}, Path: ProxyPath{Path: "/v1"}, Handle: handle("api")},
+			{Name: "tenant", Domain: ProxyDomain{HostExpReg: `^(?P<tenant>[a-z]+)\.tenants\.example\.com$`}, Handle: handle("tenant")},
+			{Name: "static", Domain: ProxyDomain{Host: "*.cdn.example.com"}, Handle: handle("static")},
+			{Name: "site", Domain: ProxyDomain{
+				Host: "www.example.com",
+				NotFoundHandle: func(w ProxyResponseWriter, r *ProxyRequest) {
+					http.Error(w, "site not found", http.StatusNotFound)
+				},
+			}, Path: ProxyPath{Path: "/"}, Handle: handle("site")},
+			{Name: "any", Path: ProxyPath{Path: "/health"}, Handle: handle("any")},
+		},
+	})
+	if err != nil {
+		t.Logf("New().error: %v", err)
+		t.FailNow()
+	}
+
+	tests := []struct {
+		host       string
+		path       string
+		statusCode int
+		body       string
+	}{
+		{host: "api.example.com", path: "/v1", statusCode: http.StatusOK, body: "api "},
+		{host: "api.example.com:8443", path: "/v1", statusCode: http.StatusOK, body: "api "},
+		{host: "acme.tenants.example.com", path: "/v1", statusCode: http.StatusOK, body: "tenant acme"},
+		{host: "img.cdn.example.com", path: "/logo.png", statusCode: http.StatusOK, body: "static "},
+		{host: "www.example.com", path: "/", statusCode: http.StatusOK, body: "site "},
+
+		// the routes without domain match every host
+		{host: "api.example.com", path: "/health", statusCode: http.StatusOK, body: "any "},
+
+		// the unknown hosts use the default host
+		{host: "unknown.org", path: "/", statusCode: http.StatusOK, body: "site "},
+		{host: "example.com", path: "/v1", statusCode: http.StatusNotFound, body: "site not found\n"},
+
+		// a known host doesn't use the routes of the default host
+		{host: "api.example.com", path: "/", statusCode: http.StatusNotFound, body: "Page Not Found!"},
+		{host: "www.example.com", path: "/missing", statusCode: http.StatusNotFound, body: "site not found\n"},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, test.path, nil)
+		r.Host = test.host
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, r)
+
+		if w.Code != test.statusCode || !strings.Contains(w.Body.String(), test.body) {
+			t.Logf("%v%v: status code %v, body: %q", test.host, test.path, w.Code, w.Body.String())
+			t.FailNow()
+		}
+	}
+}
@@ -0,0 +1,525 @@
+package server
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// ScopeRoutesCalculate scope required by the /calculate endpoint
+	ScopeRoutesCalculate = "routes:calculate"
+
+	// ScopeRoutesBatch scope required by the batch endpoints
+	ScopeRoutesBatch = "routes:batch"
+)
+
+const (
+	// AuthMethodAPIKey client authenticated by static api key
+	AuthMethodAPIKey = "apiKey"
+
+	// AuthMethodJWT client authenticated by jwt bearer token
+	AuthMethodJWT = "jwt"
+)
+
+var (
+	// ErrUnauthorized missing or invalid credentials
+	ErrUnauthorized = errors.New("unauthorized")
+
+	// ErrForbidden valid credentials without the required scope
+	ErrForbidden = errors.New("forbidden")
+)
+
+// APIKeyConfig static api key of a client. The key itself is never stored, only its sha256 hash
+type APIKeyConfig struct {
+	// Name client name, used as the principal subject
+	Name string `json:"name"`
+
+	// Hash hex encoded sha256 of the api key. See HashAPIKey()
+	Hash string `json:"hash"`
+
+	// Scopes list of scopes granted to the client. Ex.: ["routes:calculate", "routes:batch"]
+	Scopes []string `json:"scopes"`
+}
+
+// AuthConfig authentication configuration of the server
+type AuthConfig struct {
+	// APIKeys static api keys accepted in the X-API-Key header
+	APIKeys []APIKeyConfig `json:"apiKeys"`
+
+	// JWKSFile [optional] local json web key set file used to validate jwt bearer tokens
+	JWKSFile string `json:"jwksFile"`
+
+	// Issuer [optional] when informed, the jwt "iss" claim must be equal
+	Issuer string `json:"issuer"`
+
+	// Audience [optional] when informed, the jwt "aud" claim must contain it
+	Audience string `json:"audience"`
+
+	// Leeway clock skew tolerated on "exp" and "nbf" claims. Ex.: "30s"
+	Leeway string `json:"leeway"`
+}
+
+// LoadAuthConfig reads the authentication configuration from a json file
+func LoadAuthConfig(path string) (config AuthConfig, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(data, &config)
+	return
+}
+
+// HashAPIKey returns the value to be stored in APIKeyConfig.Hash
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Principal authenticated client
+type Principal struct {
+	// Subject api key name or jwt "sub" claim
+	Subject string `json:"subject"`
+
+	// Method AuthMethodAPIKey or AuthMethodJWT
+	Method string `json:"method"`
+
+	// Scopes granted to the client
+	Scopes []string `json:"scopes"`
+}
+
+// HasScope returns true when the scope was granted to the client
+func (e Principal) HasScope(scope string) bool {
+	for _, granted := range e.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// principalKey context key of the authenticated client
+type principalKey struct{}
+
+// PrincipalFromContext returns the client authenticated by Auth.Middleware()
+func PrincipalFromContext(ctx context.Context) (principal Principal, ok bool) {
+	principal, ok = ctx.Value(principalKey{}).(Principal)
+	return
+}
+
+// Auth validates api keys and jwt bearer tokens
+type Auth struct {
+	apiKeys  map[[sha256.Size]byte]APIKeyConfig
+	keys     map[string]jsonWebKey
+	issuer   string
+	audience string
+	leeway   time.Duration
+	now      func() time.Time
+}
+
+// NewAuth prepares the authentication from the configuration
+func NewAuth(config AuthConfig) (auth *Auth, err error) {
+	auth = &Auth{
+		apiKeys:  make(map[[sha256.Size]byte]APIKeyConfig),
+		keys:     make(map[string]jsonWebKey),
+		issuer:   config.Issuer,
+		audience: config.Audience,
+		now:      time.Now,
+	}
+
+	if config.Leeway != "" {
+		auth.leeway, err = time.ParseDuration(config.Leeway)
+		if err != nil {
+			return nil, fmt.Errorf("leeway: %v", err)
+		}
+	}
+
+	for _, apiKey := range config.APIKeys {
+		var hash []byte
+		hash, err = hex.DecodeString(apiKey.Hash)
+		if err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("the api key '%v' must have a hex encoded sha256 hash", apiKey.Name)
+		}
+
+		var key [sha256.Size]byte
+		copy(key[:], hash)
+		auth.apiKeys[key] = apiKey
+	}
+
+	if config.JWKSFile != "" {
+		auth.keys, err = loadJWKS(config.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return
+}
+
+// Middleware authenticates the request and checks if the client has the scope.
+// Returns 401 for missing or invalid credentials and 403 when the scope was not granted.
+func (e *Auth) Middleware(scope string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, err := e.Authenticate(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="flights"`)
+			writeRestError(w, r, http.StatusUnauthorized, err)
+			return
+		}
+
+		if scope != "" && !principal.HasScope(scope) {
+			writeRestError(w, r, http.StatusForbidden, fmt.Errorf("%w: scope '%v' is required", ErrForbidden, scope))
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), principalKey{}, principal)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Authenticate returns the client identified by the X-API-Key header or by the Authorization bearer token
+func (e *Auth) Authenticate(r *http.Request) (principal Principal, err error) {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return e.authenticateAPIKey(key)
+	}
+
+	authorization := r.Header.Get("Authorization")
+	if len(authorization) > 7 && strings.EqualFold(authorization[:7], "Bearer ") {
+		return e.authenticateJWT(strings.TrimSpace(authorization[7:]))
+	}
+
+	err = fmt.Errorf("%w: credentials not found", ErrUnauthorized)
+	return
+}
+
+// authenticateAPIKey looks for the hash of the key
+func (e *Auth) authenticateAPIKey(key string) (principal Principal, err error) {
+	hash := sha256.Sum256([]byte(key))
+	apiKey, found := e.apiKeys[hash]
+	if !found {
+		err = fmt.Errorf("%w: invalid api key", ErrUnauthorized)
+		return
+	}
+
+	principal = Principal{
+		Subject: apiKey.Name,
+		Method:  AuthMethodAPIKey,
+		Scopes:  apiKey.Scopes,
+	}
+	return
+}
+
+// jwtHeader header of the jwt
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// jwtClaims claims used by the server
+type jwtClaims struct {
+	Subject   string          `json:"sub"`
+	Issuer    string          `json:"iss"`
+	Audience  json.RawMessage `json:"aud"`
+	ExpiresAt *int64          `json:"exp"`
+	NotBefore *int64          `json:"nbf"`
+	Scope     string          `json:"scope"`
+	Scopes    []string        `json:"scopes"`
+}
+
+// hasAudience the "aud" claim can be a string or a list of strings
+func (e jwtClaims) hasAudience(audience string) bool {
+	var single string
+	if json.Unmarshal(e.Audience, &single) == nil {
+		return single == audience
+	}
+
+	var list []string
+	if json.Unmarshal(e.Audience, &list) == nil {
+		for _, value := range list {
+			if value == audience {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// authenticateJWT validates the signature and the claims of the token
+func (e *Auth) authenticateJWT(token string) (principal Principal, err error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		err = fmt.Errorf("%w: malformed token", ErrUnauthorized)
+		return
+	}
+
+	var header jwtHeader
+	if err = decodeJWTPart(parts[0], &header); err != nil {
+		err = fmt.Errorf("%w: malformed token header", ErrUnauthorized)
+		return
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		err = fmt.Errorf("%w: malformed token signature", ErrUnauthorized)
+		return
+	}
+
+	key, err := e.findKey(header)
+	if err != nil {
+		return
+	}
+
+	if err = key.verify(header.Alg, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		err = fmt.Errorf("%w: %v", ErrUnauthorized, err)
+		return
+	}
+
+	var claims jwtClaims
+	if err = decodeJWTPart(parts[1], &claims); err != nil {
+		err = fmt.Errorf("%w: malformed token claims", ErrUnauthorized)
+		return
+	}
+
+	if err = e.validateClaims(claims); err != nil {
+		return
+	}
+
+	principal = Principal{
+		Subject: claims.Subject,
+		Method:  AuthMethodJWT,
+		Scopes:  append(strings.Fields(claims.Scope), claims.Scopes...),
+	}
+	return
+}
+
+// findKey returns the key informed by "kid", or the only key of the set compatible with the algorithm
+func (e *Auth) findKey(header jwtHeader) (key jsonWebKey, err error) {
+	if header.Kid != "" {
+		var found bool
+		if key, found = e.keys[header.Kid]; !found {
+			err = fmt.Errorf("%w: unknown key id '%v'", ErrUnauthorized, header.Kid)
+		}
+		return
+	}
+
+	found := 0
+	for _, candidate := range e.keys {
+		if candidate.supports(header.Alg) {
+			key = candidate
+			found += 1
+		}
+	}
+
+	if found != 1 {
+		err = fmt.Errorf("%w: the token must inform the key id", ErrUnauthorized)
+	}
+	return
+}
+
+// validateClaims checks time, issuer and audience
+func (e *Auth) validateClaims(claims jwtClaims) (err error) {
+	now := e.now()
+
+	if claims.ExpiresAt == nil {
+		return fmt.Errorf("%w: the token must have an expiration", ErrUnauthorized)
+	}
+
+	if now.After(time.Unix(*claims.ExpiresAt, 0).Add(e.leeway)) {
+		return fmt.Errorf("%w: token expired", ErrUnauthorized)
+	}
+
+	if claims.NotBefore != nil && now.Add(e.leeway).Before(time.Unix(*claims.NotBefore, 0)) {
+		return fmt.Errorf("%w: token not valid yet", ErrUnauthorized)
+	}
+
+	if e.issuer != "" && claims.Issuer != e.issuer {
+		return fmt.Errorf("%w: invalid issuer", ErrUnauthorized)
+	}
+
+	if e.audience != "" && !claims.hasAudience(e.audience) {
+		return fmt.Errorf("%w: invalid audience", ErrUnauthorized)
+	}
+
+	if claims.Subject == "" {
+		return fmt.Errorf("%w: the token must have a subject", ErrUnauthorized)
+	}
+
+	return
+}
+
+// decodeJWTPart decodes a base64url json part of the token
+func decodeJWTPart(part string, value any) (err error) {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return
+	}
+
+	return json.Unmarshal(data, value)
+}
+
+// jsonWebKey key of the set, RFC 7517
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv"`
+	K   string `json:"k"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+
+	secret   []byte
+	rsaKey   *rsa.PublicKey
+	ecdsaKey *ecdsa.PublicKey
+}
+
+// loadJWKS reads the json web key set file
+func loadJWKS(path string) (keys map[string]jsonWebKey, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err = json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("jwks file %v: %v", path, err)
+	}
+
+	keys = make(map[string]jsonWebKey)
+	for i, key := range set.Keys {
+		if err = key.prepare(); err != nil {
+			return nil, fmt.Errorf("jwks file %v, key %v: %v", path, i, err)
+		}
+
+		if key.Kid == "" {
+			key.Kid = fmt.Sprintf("#%v", i)
+		}
+		keys[key.Kid] = key
+	}
+
+	return
+}
+
+// prepare decodes the key material
+func (e *jsonWebKey) prepare() (err error) {
+	decode := base64.RawURLEncoding.DecodeString
+
+	switch e.Kty {
+	case "oct":
+		e.secret, err = decode(e.K)
+		if err == nil && len(e.secret) == 0 {
+			err = errors.New("empty secret")
+		}
+
+	case "RSA":
+		var n, exponent []byte
+		if n, err = decode(e.N); err != nil {
+			return
+		}
+		if exponent, err = decode(e.E); err != nil {
+			return
+		}
+		e.rsaKey = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(exponent).Int64()),
+		}
+
+	case "EC":
+		if e.Crv != "P-256" {
+			return fmt.Errorf("unsupported curve '%v'", e.Crv)
+		}
+		var x, y []byte
+		if x, err = decode(e.X); err != nil {
+			return
+		}
+		if y, err = decode(e.Y); err != nil {
+			return
+		}
+		e.ecdsaKey = &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !e.ecdsaKey.Curve.IsOnCurve(e.ecdsaKey.X, e.ecdsaKey.Y) {
+			return errors.New("invalid ec point")
+		}
+
+	default:
+		err = fmt.Errorf("unsupported key type '%v'", e.Kty)
+	}
+
+	return
+}
+
+// supports returns true when the key can verify the algorithm
+func (e jsonWebKey) supports(alg string) bool {
+	if e.Alg != "" && e.Alg != alg {
+		return false
+	}
+
+	switch alg {
+	case "HS256":
+		return e.secret != nil
+	case "RS256":
+		return e.rsaKey != nil
+	case "ES256":
+		return e.ecdsaKey != nil
+	}
+	return false
+}
+
+// verify checks the signature of the token. Only HS256, RS256 and ES256 are accepted
+func (e jsonWebKey) verify(alg string, signed, signature []byte) error {
+	if !e.supports(alg) {
+		return fmt.Errorf("the algorithm '%v' is not accepted by the key", alg)
+	}
+
+	digest := sha256.Sum256(signed)
+
+	switch alg {
+	case "HS256":
+		mac := hmac.New(sha256.New, e.secret)
+		mac.Write(signed)
+		if subtle.ConstantTimeCompare(mac.Sum(nil), signature) != 1 {
+			return errors.New("invalid signature")
+		}
+
+	case "RS256":
+		if rsa.VerifyPKCS1v15(e.rsaKey, crypto.SHA256, digest[:], signature) != nil {
+			return errors.New("invalid signature")
+		}
+
+	case "ES256":
+		// the jws signature is r || s, with 32 bytes each
+		if len(signature) != 64 {
+			return errors.New("invalid signature")
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(e.ecdsaKey, digest[:], r, s) {
+			return errors.New("invalid signature")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testSigner struct {
+	kid  string
+	alg  string
+	sign func(signed []byte) []byte
+}
+
+func newTestJWT(t *testing.T, signer testSigner, claims map[string]any) string {
+	header, _ := json.Marshal(map[string]string{"alg": signer.alg, "kid": signer.kid, "typ": "JWT"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Logf("json.Marshal().error: %v", err)
+		t.FailNow()
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signer.sign([]byte(signed)))
+}
+
+func newTestAuth(t *testing.T) (auth *Auth, signers []testSigner) {
+	encode := base64.RawURLEncoding.EncodeToString
+
+	secret := []byte("a very long secret used only by the test")
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	jwks := map[string]any{
+		"keys": []map[string]string{
+			{"kty": "oct", "kid": "hs", "alg": "HS256", "k": encode(secret)},
+			{"kty": "RSA", "kid": "rs", "alg": "RS256", "n": encode(rsaKey.N.Bytes()), "e": encode(big.NewInt(int64(rsaKey.E)).Bytes())},
+			{"kty": "EC", "kid": "es", "alg": "ES256", "crv": "P-256", "x": encode(ecKey.X.FillBytes(make([]byte, 32))), "y": encode(ecKey.Y.FillBytes(make([]byte, 32)))},
+		},
+	}
+
+	data, _ := json.Marshal(jwks)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Logf("os.WriteFile().error: %v", err)
+		t.FailNow()
+	}
+
+	auth, err := NewAuth(AuthConfig{
+		APIKeys: []APIKeyConfig{
+			{Name: "partner-a", Hash: HashAPIKey("key-a"), Scopes: []string{ScopeRoutesCalculate}},
+			{Name: "partner-b", Hash: HashAPIKey("key-b"), Scopes: []string{ScopeRoutesBatch}},
+		},
+		JWKSFile: path,
+		Issuer:   "flights-test",
+	})
+	if err != nil {
+		t.Logf("NewAuth().error: %v", err)
+		t.FailNow()
+	}
+
+	signers = []testSigner{
+		{kid: "hs", alg: "HS256", sign: func(signed []byte) []byte {
+			mac := hmac.New(sha256.New, secret)
+			mac.Write(signed)
+			return mac.Sum(nil)
+		}},
+		{kid: "rs", alg: "RS256", sign: func(signed []byte) []byte {
+			digest := sha256.Sum256(signed)
+			signature, _ := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+			return signature
+		}},
+		{kid: "es", alg: "ES256", sign: func(signed []byte) []byte {
+			digest := sha256.Sum256(signed)
+			r, s, _ := ecdsa.Sign(rand.Reader, ecKey, digest[:])
+			return append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+		}},
+	}
+	return
+}
+
+func TestAuth_Middleware(t *testing.T) {
+	auth, signers := newTestAuth(t)
+
+	handler := auth.Middleware(ScopeRoutesCalculate, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, _ := PrincipalFromContext(r.Context())
+		_, _ = w.Write([]byte(principal.Subject))
+	}))
+
+	valid := map[string]any{"sub": "client-1", "iss": "flights-test", "exp": time.Now().Add(time.Hour).Unix(), "scope": "routes:calculate routes:batch"}
+	expired := map[string]any{"sub": "client-1", "iss": "flights-test", "exp": time.Now().Add(-time.Hour).Unix(), "scope": "routes:calculate"}
+	noScope := map[string]any{"sub": "client-1", "iss": "flights-test", "exp": time.Now().Add(time.Hour).Unix(), "scope": "routes:batch"}
+	badIssuer := map[string]any{"sub": "client-1", "iss": "other", "exp": time.Now().Add(time.Hour).Unix(), "scope": "routes:calculate"}
+
+	tests := []struct {
+		name       string
+		header     string
+		value      string
+		statusCode int
+		subject    string
+	}{
+		{name: "without credentials", statusCode: http.StatusUnauthorized},
+		{name: "api key", header: "X-API-Key", value: "key-a", statusCode: http.StatusOK, subject: "partner-a"},
+		{name: "invalid api key", header: "X-API-Key", value: "key-c", statusCode: http.StatusUnauthorized},
+		{name: "api key without scope", header: "X-API-Key", value: "key-b", statusCode: http.StatusForbidden},
+		{name: "HS256", header: "Authorization", value: "Bearer " + newTestJWT(t, signers[0], valid), statusCode: http.StatusOK, subject: "client-1"},
+		{name: "RS256", header: "Authorization", value: "Bearer " + newTestJWT(t, signers[1], valid), statusCode: http.StatusOK, subject: "client-1"},
+		{name: "ES256", header: "Authorization", value: "Bearer " + newTestJWT(t, signers[2], valid), statusCode: http.StatusOK, subject: "client-1"},
+		{name: "expired", header: "Authorization", value: "Bearer " + newTestJWT(t, signers[0], expired), statusCode: http.StatusUnauthorized},
+		{name: "jwt without scope", header: "Authorization", value: "Bearer " + newTestJWT(t, signers[1], noScope), statusCode: http.StatusForbidden},
+		{name: "invalid issuer", header: "Authorization", value: "Bearer " + newTestJWT(t, signers[2], badIssuer), statusCode: http.StatusUnauthorized},
+		{name: "algorithm confusion", header: "Authorization", value: "Bearer " + newTestJWT(t, testSigner{kid: "rs", alg: "HS256", sign: signers[0].sign}, valid), statusCode: http.StatusUnauthorized},
+		{name: "malformed", header: "Authorization", value: "Bearer abc.def", statusCode: http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/calculate", nil)
+		if test.header != "" {
+			r.Header.Set(test.header, test.value)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if w.Code != test.statusCode {
+			t.Logf("%v: status code %v, expected %v. body: %s", test.name, w.Code, test.statusCode, w.Body.Bytes())
+			t.FailNow()
+		}
+
+		if test.statusCode == http.StatusOK && w.Body.String() != test.subject {
+			t.Logf("%v: subject %v, expected %v", test.name, w.Body.String(), test.subject)
+			t.FailNow()
+		}
+	}
+}
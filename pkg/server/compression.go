@@ -0,0 +1,259 @@
+package server
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	EncodingZstd     = "zstd"
+	EncodingGzip     = "gzip"
+	EncodingDeflate  = "deflate"
+	EncodingIdentity = "identity"
+)
+
+// ErrUnsupportedEncoding the request body uses an unknown Content-Encoding
+var ErrUnsupportedEncoding = errors.New("unsupported content encoding")
+
+// supportedEncodings in order of preference when the client accepts more than one with the same quality
+var supportedEncodings = []string{EncodingZstd, EncodingGzip, EncodingDeflate}
+
+// CompressionConfig configuration of the compression middleware
+type CompressionConfig struct {
+	// MinSize responses smaller than MinSize bytes are sent without compression. Default 512
+	MinSize int
+
+	// MaxDecompressedBytes maximum size of a decompressed request body. Default 10MB
+	MaxDecompressedBytes int64
+}
+
+// zstdEncoder EncodeAll() is safe for concurrent use, so one encoder is shared by all requests
+var zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+
+// NegotiateEncoding returns the best encoding accepted by the Accept-Encoding header, or "identity"
+func NegotiateEncoding(acceptEncoding string) string {
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		if name == "" {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if value, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					quality = value
+				}
+			}
+		}
+		qualities[name] = quality
+	}
+
+	best, bestQuality := EncodingIdentity, 0.0
+	for _, encoding := range supportedEncodings {
+		quality, found := qualities[encoding]
+		if !found {
+			quality, found = qualities["*"]
+		}
+
+		if found && quality > bestQuality {
+			best, bestQuality = encoding, quality
+		}
+	}
+
+	return best
+}
+
+// compress compresses data with the encoding. The output is deterministic for the same input
+func compress(encoding string, data []byte) (compressed []byte, err error) {
+	if encoding == EncodingZstd {
+		return zstdEncoder.EncodeAll(data, nil), nil
+	}
+
+	var buffer bytes.Buffer
+	var writer io.WriteCloser
+
+	switch encoding {
+	case EncodingGzip:
+		writer = gzip.NewWriter(&buffer)
+	case EncodingDeflate:
+		writer, err = flate.NewWriter(&buffer, flate.DefaultCompression)
+		if err != nil {
+			return
+		}
+	default:
+		return data, nil
+	}
+
+	if _, err = writer.Write(data); err != nil {
+		return
+	}
+
+	if err = writer.Close(); err != nil {
+		return
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// zstdReadCloser closes the zstd decoder and the original body
+type zstdReadCloser struct {
+	*zstd.Decoder
+	body io.Closer
+}
+
+func (e zstdReadCloser) Close() error {
+	e.Decoder.Close()
+	return e.body.Close()
+}
+
+// limitedReadCloser returns an error when the decompressed body exceeds the limit
+type limitedReadCloser struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (e *limitedReadCloser) Read(p []byte) (n int, err error) {
+	if e.remaining <= 0 {
+		// the body can end exactly at the limit
+		var probe [1]byte
+		if n, err = e.ReadCloser.Read(probe[:]); n == 0 && err == io.EOF {
+			return 0, io.EOF
+		}
+		return 0, fmt.Errorf("the decompressed body exceeds the limit")
+	}
+
+	if int64(len(p)) > e.remaining {
+		p = p[:e.remaining]
+	}
+
+	n, err = e.ReadCloser.Read(p)
+	e.remaining -= int64(n)
+	return
+}
+
+// decompressBody replaces the body of the request by the decompressed body
+func decompressBody(r *http.Request, maxBytes int64) (err error) {
+	encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+	if encoding == "" || encoding == EncodingIdentity {
+		return
+	}
+
+	var body io.ReadCloser
+	switch encoding {
+	case EncodingGzip:
+		body, err = gzip.NewReader(r.Body)
+	case EncodingDeflate:
+		body = flate.NewReader(r.Body)
+	case EncodingZstd:
+		var decoder *zstd.Decoder
+		decoder, err = zstd.NewReader(r.Body, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxMemory(uint64(maxBytes)))
+		body = zstdReadCloser{Decoder: decoder, body: r.Body}
+	default:
+		return fmt.Errorf("%w: %v", ErrUnsupportedEncoding, encoding)
+	}
+
+	if err != nil {
+		return
+	}
+
+	r.Body = &limitedReadCloser{ReadCloser: body, remaining: maxBytes}
+	r.Header.Del("Content-Encoding")
+	r.Header.Del("Content-Length")
+	r.ContentLength = -1
+	return
+}
+
+// bufferedResponseWriter keeps the response in memory so it can be changed before being sent
+type bufferedResponseWriter struct {
+	header     http.Header
+	statusCode int
+	body       bytes.Buffer
+}
+
+func newBufferedResponseWriter() *bufferedResponseWriter {
+	return &bufferedResponseWriter{
+		header:     make(http.Header),
+		statusCode: http.StatusOK,
+	}
+}
+
+func (e *bufferedResponseWriter) Header() http.Header {
+	return e.header
+}
+
+func (e *bufferedResponseWriter) Write(data []byte) (int, error) {
+	return e.body.Write(data)
+}
+
+func (e *bufferedResponseWriter) WriteHeader(statusCode int) {
+	e.statusCode = statusCode
+}
+
+// flush sends the buffered response
+func (e *bufferedResponseWriter) flush(w http.ResponseWriter) {
+	for key, values := range e.header {
+		w.Header()[key] = values
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(e.body.Len()))
+	w.WriteHeader(e.statusCode)
+	_, _ = w.Write(e.body.Bytes())
+}
+
+// Middleware decompresses gzip, deflate and zstd request bodies and compresses the response with the best encoding
+// accepted by the client
+func (e CompressionConfig) Middleware(next http.Handler) http.Handler {
+	if e.MinSize == 0 {
+		e.MinSize = 512
+	}
+
+	if e.MaxDecompressedBytes == 0 {
+		e.MaxDecompressedBytes = 10 * 1024 * 1024
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := decompressBody(r, e.MaxDecompressedBytes); err != nil {
+			if errors.Is(err, ErrUnsupportedEncoding) {
+				w.Header().Set("Accept-Encoding", strings.Join(supportedEncodings, ", "))
+				writeRestError(w, r, http.StatusUnsupportedMediaType, err)
+				return
+			}
+			writeRestError(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		buffer := newBufferedResponseWriter()
+		next.ServeHTTP(buffer, r)
+
+		encoding := NegotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == EncodingIdentity || buffer.body.Len() < e.MinSize || buffer.header.Get("Content-Encoding") != "" {
+			buffer.flush(w)
+			return
+		}
+
+		compressed, err := compress(encoding, buffer.body.Bytes())
+		if err != nil {
+			buffer.flush(w)
+			return
+		}
+
+		buffer.body.Reset()
+		buffer.body.Write(compressed)
+		buffer.header.Set("Content-Encoding", encoding)
+		buffer.flush(w)
+	})
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := map[string]string{
+		"":                            EncodingIdentity,
+		"gzip":                        EncodingGzip,
+		"gzip, deflate, br":           EncodingGzip,
+		"gzip, deflate, zstd":         EncodingZstd,
+		"zstd;q=0.5, gzip":            EncodingGzip,
+		"deflate;q=0.9, gzip;q=0.1":   EncodingDeflate,
+		"*":                           EncodingZstd,
+		"*;q=0.5, zstd;q=0":           EncodingGzip,
+		"br, identity":                EncodingIdentity,
+		"GZIP;q=1.0, deflate;q=1.000": EncodingGzip,
+	}
+
+	for acceptEncoding, expected := range tests {
+		if encoding := NegotiateEncoding(acceptEncoding); encoding != expected {
+			t.Logf("NegotiateEncoding(%q) = %v, expected %v", acceptEncoding, encoding, expected)
+			t.FailNow()
+		}
+	}
+}
+
+func TestCompressionConfig_Middleware(t *testing.T) {
+	handler := MiddlewareETag(CompressionConfig{}.Middleware(MiddlewarePost(http.HandlerFunc(GeneratesSubRoutesOfRoute))))
+
+	payload := []byte(`[["DUB","LHR"],["LHR","GVA"],["GVA","MXP"],["MXP","NCE"],["NCE","MAD"],["MAD","LIM"],["LIM","SCL"],["SCL","AEP"],["AEP","EZE"]]`)
+
+	var gzipPayload bytes.Buffer
+	writer := gzip.NewWriter(&gzipPayload)
+	_, _ = writer.Write(payload)
+	_ = writer.Close()
+
+	send := func(body []byte, header map[string]string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/calculate", bytes.NewReader(body))
+		for key, value := range header {
+			r.Header.Set(key, value)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	plain := send(payload, nil)
+	if plain.Code != http.StatusOK || plain.Header().Get("Content-Encoding") != "" || plain.Header().Get("ETag") == "" {
+		t.Logf("plain response error. status code: %v, headers: %v", plain.Code, plain.Header())
+		t.FailNow()
+	}
+
+	// compressed request body and compressed response
+	w := send(gzipPayload.Bytes(), map[string]string{"Content-Encoding": "gzip", "Accept-Encoding": "zstd"})
+	if w.Code != http.StatusOK || w.Header().Get("Content-Encoding") != EncodingZstd {
+		t.Logf("zstd response error. status code: %v, headers: %v", w.Code, w.Header())
+		t.FailNow()
+	}
+
+	decoder, _ := zstd.NewReader(nil)
+	body, err := decoder.DecodeAll(w.Body.Bytes(), nil)
+	if err != nil || !bytes.Equal(body, plain.Body.Bytes()) {
+		t.Logf("the zstd response must be equal to the plain response. error: %v", err)
+		t.FailNow()
+	}
+
+	if w.Header().Get("ETag") == plain.Header().Get("ETag") {
+		t.Log("each content encoding must have its own ETag")
+		t.FailNow()
+	}
+
+	// same itinerary, same ETag
+	w = send(payload, map[string]string{"If-None-Match": plain.Header().Get("ETag")})
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Logf("If-None-Match must return 304. status code: %v", w.Code)
+		t.FailNow()
+	}
+
+	w = send(payload, map[string]string{"Accept-Encoding": "gzip"})
+	reader, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Logf("gzip.NewReader().error: %v", err)
+		t.FailNow()
+	}
+	body, _ = io.ReadAll(reader)
+	if !bytes.Equal(body, plain.Body.Bytes()) {
+		t.Log("the gzip response must be equal to the plain response")
+		t.FailNow()
+	}
+
+	w = send(payload, map[string]string{"Content-Encoding": "br"})
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Logf("unknown content encoding must return 415. status code: %v", w.Code)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,218 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// CORSConfig cross-origin resource sharing configuration of the server
+type CORSConfig struct {
+	// AllowedOrigins origins allowed to call the server. "*" allows any origin and a "*" inside the origin matches
+	// any sequence of characters. Ex.: ["https://app.example.com", "https://*.example.com"]
+	AllowedOrigins []string `json:"allowedOrigins"`
+
+	// AllowedMethods [optional] methods allowed in preflight requests. Default GET, HEAD and POST
+	AllowedMethods []string `json:"allowedMethods"`
+
+	// AllowedHeaders [optional] request headers allowed in preflight requests. "*" allows any header.
+	// Default Accept, Authorization, Content-Encoding, Content-Type, If-None-Match and X-API-Key
+	AllowedHeaders []string `json:"allowedHeaders"`
+
+	// ExposedHeaders [optional] response headers readable by the browser. Ex.: ["ETag", "RateLimit-Remaining"]
+	ExposedHeaders []string `json:"exposedHeaders"`
+
+	// AllowCredentials allows cookies, authorization headers and client certificates. It can't be used with the "*"
+	// origin, otherwise any site could read the responses with the credentials of the user
+	AllowCredentials bool `json:"allowCredentials"`
+
+	// MaxAge [optional] seconds the browser can cache the preflight response. Zero omits the header
+	MaxAge int `json:"maxAge"`
+}
+
+// LoadCORSConfig reads the cors configuration from a json file
+func LoadCORSConfig(path string) (config CORSConfig, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(data, &config)
+	return
+}
+
+// CORS answers preflight requests and adds the cors headers to the responses of allowed origins
+type CORS struct {
+	config         CORSConfig
+	anyOrigin      bool
+	origins        map[string]bool
+	patterns       []*regexp.Regexp
+	methods        map[string]bool
+	anyHeader      bool
+	headers        map[string]bool
+	allowedMethods string
+	exposedHeaders string
+}
+
+// NewCORS returns the cors middleware with the configuration
+func NewCORS(config CORSConfig) (cors *CORS, err error) {
+	if len(config.AllowedOrigins) == 0 {
+		err = fmt.Errorf("the cors configuration must have at least one allowed origin")
+		return
+	}
+
+	if len(config.AllowedMethods) == 0 {
+		config.AllowedMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+	}
+
+	if len(config.AllowedHeaders) == 0 {
+		config.AllowedHeaders = []string{"Accept", "Authorization", "Content-Encoding", "Content-Type", "If-None-Match", "X-API-Key"}
+	}
+
+	cors = &CORS{
+		config:  config,
+		origins: make(map[string]bool),
+		methods: make(map[string]bool),
+		headers: make(map[string]bool),
+	}
+
+	for _, origin := range config.AllowedOrigins {
+		origin = strings.ToLower(strings.TrimSpace(origin))
+		switch {
+		case origin == "*":
+			cors.anyOrigin = true
+		case strings.Contains(origin, "*"):
+			pattern := "^" + strings.ReplaceAll(regexp.QuoteMeta(origin), `\*`, `.*`) + "$"
+			cors.patterns = append(cors.patterns, regexp.MustCompile(pattern))
+		default:
+			cors.origins[origin] = true
+		}
+	}
+
+	if cors.anyOrigin && config.AllowCredentials {
+		return nil, fmt.Errorf("the cors configuration can't allow credentials for any origin \"*\"")
+	}
+
+	methods := make([]string, 0, len(config.AllowedMethods))
+	for _, method := range config.AllowedMethods {
+		method = strings.ToUpper(strings.TrimSpace(method))
+		cors.methods[method] = true
+		methods = append(methods, method)
+	}
+	cors.allowedMethods = strings.Join(methods, ", ")
+
+	for _, header := range config.AllowedHeaders {
+		header = strings.TrimSpace(header)
+		if header == "*" {
+			cors.anyHeader = true
+			continue
+		}
+		cors.headers[http.CanonicalHeaderKey(header)] = true
+	}
+
+	cors.exposedHeaders = strings.Join(config.ExposedHeaders, ", ")
+	return
+}
+
+// AllowOrigin returns true when the origin is allowed by the configuration
+func (e *CORS) AllowOrigin(origin string) bool {
+	if origin == "" {
+		return false
+	}
+
+	if e.anyOrigin {
+		return true
+	}
+
+	origin = strings.ToLower(origin)
+	if e.origins[origin] {
+		return true
+	}
+
+	for _, pattern := range e.patterns {
+		if pattern.MatchString(origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// allowHeaders returns false when one of the headers of Access-Control-Request-Headers isn't allowed
+func (e *CORS) allowHeaders(requestHeaders string) bool {
+	if e.anyHeader {
+		return true
+	}
+
+	for _, header := range strings.Split(requestHeaders, ",") {
+		header = strings.TrimSpace(header)
+		if header != "" && !e.headers[http.CanonicalHeaderKey(header)] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// setOrigin sets the headers shared by preflight and actual responses
+func (e *CORS) setOrigin(w http.ResponseWriter, origin string) {
+	if e.anyOrigin {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	} else {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	}
+
+	if e.config.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// Middleware answers preflight requests before they reach the handlers, so it works with handlers that only accept
+// POST, like MiddlewarePost(). Actual requests of allowed origins receive the cors headers, including error responses.
+func (e *CORS) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		requestMethod := r.Header.Get("Access-Control-Request-Method")
+
+		// an OPTIONS request without these headers isn't a preflight and is answered by the handler
+		if r.Method != http.MethodOptions || origin == "" || requestMethod == "" {
+			if !e.anyOrigin {
+				w.Header().Add("Vary", "Origin")
+			}
+
+			if e.AllowOrigin(origin) {
+				e.setOrigin(w, origin)
+				if e.exposedHeaders != "" {
+					w.Header().Set("Access-Control-Expose-Headers", e.exposedHeaders)
+				}
+			}
+
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin, Access-Control-Request-Method, Access-Control-Request-Headers")
+
+		requestHeaders := r.Header.Get("Access-Control-Request-Headers")
+		if !e.AllowOrigin(origin) || !e.methods[strings.ToUpper(requestMethod)] || !e.allowHeaders(requestHeaders) {
+			writeRestError(w, r, http.StatusForbidden, fmt.Errorf("%w: cross-origin request not allowed", ErrForbidden))
+			return
+		}
+
+		e.setOrigin(w, origin)
+		w.Header().Set("Access-Control-Allow-Methods", e.allowedMethods)
+		if requestHeaders != "" {
+			w.Header().Set("Access-Control-Allow-Headers", requestHeaders)
+		}
+
+		if e.config.MaxAge > 0 {
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(e.config.MaxAge))
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
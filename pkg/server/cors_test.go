@@ -0,0 +1,122 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCORS_Middleware(t *testing.T) {
+	cors, err := NewCORS(CORSConfig{
+		AllowedOrigins:   []string{"https://app.example.com", "https://*.flights.example.com"},
+		ExposedHeaders:   []string{"ETag"},
+		AllowCredentials: true,
+		MaxAge:           600,
+	})
+	if err != nil {
+		t.Logf("NewCORS().error: %v", err)
+		t.FailNow()
+	}
+
+	// the preflight must be answered even by the post only handler
+	handler := cors.Middleware(MiddlewarePost(http.HandlerFunc(GeneratesSubRoutesOfRoute)))
+
+	tests := []struct {
+		method         string
+		origin         string
+		requestMethod  string
+		requestHeaders string
+		statusCode     int
+		allowOrigin    string
+	}{
+		{method: http.MethodOptions, origin: "https://app.example.com", requestMethod: http.MethodPost, requestHeaders: "content-type, x-api-key", statusCode: http.StatusNoContent, allowOrigin: "https://app.example.com"},
+		{method: http.MethodOptions, origin: "https://eu.flights.example.com", requestMethod: http.MethodPost, statusCode: http.StatusNoContent, allowOrigin: "https://eu.flights.example.com"},
+		{method: http.MethodOptions, origin: "https://evil.example.com", requestMethod: http.MethodPost, statusCode: http.StatusForbidden},
+		{method: http.MethodOptions, origin: "https://app.example.com", requestMethod: http.MethodDelete, statusCode: http.StatusForbidden},
+		{method: http.MethodOptions, origin: "https://app.example.com", requestMethod: http.MethodPost, requestHeaders: "X-Custom", statusCode: http.StatusForbidden},
+		{method: http.MethodOptions, statusCode: http.StatusMethodNotAllowed},
+		{method: http.MethodPost, origin: "https://app.example.com", statusCode: http.StatusOK, allowOrigin: "https://app.example.com"},
+		{method: http.MethodPost, origin: "https://evil.example.com", statusCode: http.StatusOK},
+		{method: http.MethodGet, origin: "https://app.example.com", statusCode: http.StatusMethodNotAllowed, allowOrigin: "https://app.example.com"},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(test.method, "/calculate", strings.NewReader(`[["SFO","EWR"]]`))
+		if test.origin != "" {
+			r.Header.Set("Origin", test.origin)
+		}
+		if test.requestMethod != "" {
+			r.Header.Set("Access-Control-Request-Method", test.requestMethod)
+		}
+		if test.requestHeaders != "" {
+			r.Header.Set("Access-Control-Request-Headers", test.requestHeaders)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if w.Code != test.statusCode {
+			t.Logf("%v %v: status code %v, expected %v", test.method, test.origin, w.Code, test.statusCode)
+			t.FailNow()
+		}
+
+		if w.Header().Get("Access-Control-Allow-Origin") != test.allowOrigin {
+			t.Logf("%v %v: Access-Control-Allow-Origin %q, expected %q", test.method, test.origin, w.Header().Get("Access-Control-Allow-Origin"), test.allowOrigin)
+			t.FailNow()
+		}
+
+		if test.allowOrigin == "" {
+			continue
+		}
+
+		if w.Header().Get("Access-Control-Allow-Credentials") != "true" {
+			t.Logf("%v %v: Access-Control-Allow-Credentials must be sent", test.method, test.origin)
+			t.FailNow()
+		}
+
+		if test.method == http.MethodOptions {
+			if w.Header().Get("Access-Control-Max-Age") != "600" || !strings.Contains(w.Header().Get("Access-Control-Allow-Methods"), http.MethodPost) {
+				t.Logf("%v %v: invalid preflight headers: %v", test.method, test.origin, w.Header())
+				t.FailNow()
+			}
+			if w.Header().Get("Access-Control-Allow-Headers") != test.requestHeaders {
+				t.Logf("%v %v: Access-Control-Allow-Headers %q, expected %q", test.method, test.origin, w.Header().Get("Access-Control-Allow-Headers"), test.requestHeaders)
+				t.FailNow()
+			}
+		} else if w.Header().Get("Access-Control-Expose-Headers") != "ETag" {
+			t.Logf("%v %v: Access-Control-Expose-Headers must be sent", test.method, test.origin)
+			t.FailNow()
+		}
+	}
+}
+
+func TestCORS_AnyOrigin(t *testing.T) {
+	cors, _ := NewCORS(CORSConfig{AllowedOrigins: []string{"*"}})
+	mux, err := NewMux(Config{CORS: cors})
+	if err != nil {
+		t.Logf("NewMux().error: %v", err)
+		t.FailNow()
+	}
+
+	r := httptest.NewRequest(http.MethodOptions, "/v1/calculate", nil)
+	r.Header.Set("Origin", "https://any.example.org")
+	r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Logf("the preflight must be accepted for any origin. status code: %v, headers: %v", w.Code, w.Header())
+		t.FailNow()
+	}
+
+	if _, err = NewCORS(CORSConfig{}); err == nil {
+		t.Logf("a configuration without origins must be rejected")
+		t.FailNow()
+	}
+
+	if _, err = NewCORS(CORSConfig{AllowedOrigins: []string{"https://app.example.com", "*"}, AllowCredentials: true}); err == nil {
+		t.Logf("credentials for any origin must be rejected")
+		t.FailNow()
+	}
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// etagMatch returns true when the If-None-Match header contains the etag.
+// If-None-Match uses the weak comparison, RFC 9110 section 13.1.2
+func etagMatch(ifNoneMatch, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// MiddlewareETag adds a strong ETag, the sha256 of the response body, to successful responses and returns
+// 304 Not Modified when the If-None-Match header matches.
+// Responses of /calculate are deterministic, so the same itinerary always receives the same ETag, even with POST.
+// Use it outside the compression middleware, so each content encoding has its own ETag.
+func MiddlewareETag(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buffer := newBufferedResponseWriter()
+		next.ServeHTTP(buffer, r)
+
+		if buffer.statusCode != http.StatusOK {
+			buffer.flush(w)
+			return
+		}
+
+		etag := buffer.header.Get("ETag")
+		if etag == "" {
+			sum := sha256.Sum256(buffer.body.Bytes())
+			etag = `"` + hex.EncodeToString(sum[:16]) + `"`
+			buffer.header.Set("ETag", etag)
+		}
+
+		if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" && etagMatch(ifNoneMatch, etag) {
+			for key, values := range buffer.header {
+				w.Header()[key] = values
+			}
+			w.Header().Del("Content-Length")
+			w.Header().Del("Content-Type")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		buffer.flush(w)
+	})
+}
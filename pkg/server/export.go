@@ -0,0 +1,75 @@
+package server
+
+import (
+	"errors"
+	"flights/pkg/export"
+	"flights/pkg/types"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// formats of the ?format= query parameter of /v1/calculate
+const (
+	// FormatJSON sub routes in the RestFul format, the default
+	FormatJSON = "json"
+
+	// FormatDOT Graphviz DOT digraph of the flights
+	FormatDOT = "dot"
+
+	// FormatGeoJSON GeoJSON points of the airports and lines of the flights
+	FormatGeoJSON = "geojson"
+
+	// FormatSVG SVG image of the flights
+	FormatSVG = "svg"
+)
+
+// exportContentTypes content type of the export formats
+var exportContentTypes = map[string]string{
+	FormatDOT:     "text/vnd.graphviz",
+	FormatGeoJSON: "application/geo+json",
+	FormatSVG:     "image/svg+xml",
+}
+
+// queryFormat returns the ?format= query parameter, FormatJSON when it isn't informed
+func queryFormat(r *http.Request) (format string, err error) {
+	format = r.URL.Query().Get("format")
+	if format == "" || format == FormatJSON {
+		return FormatJSON, nil
+	}
+
+	if _, found := exportContentTypes[format]; !found {
+		message := fmt.Sprintf("the query parameter 'format' must be %v, %v, %v or %v", FormatJSON, FormatDOT, FormatGeoJSON, FormatSVG)
+		return "", types.NewError(types.ErrorCodeInvalidParameter, "", message)
+	}
+	return
+}
+
+// exportable returns true when the flights can be exported. The forks and the disconnected fragments are
+// highlighted in the exports, so only the lists with invalid flights are rejected
+func exportable(err error) bool {
+	var typed types.Error
+	if !errors.As(err, &typed) {
+		return err == nil
+	}
+	return typed.Code == types.ErrorCodeFork || typed.Code == types.ErrorCodeDisconnectedChain
+}
+
+// writeExport writes the flights, sorted or not, in the export format
+func writeExport(w http.ResponseWriter, format string, flights types.Flights, airports export.Airports) {
+	w.Header().Set("Content-Type", exportContentTypes[format])
+
+	var err error
+	switch format {
+	case FormatDOT:
+		err = export.WriteDOT(w, flights)
+	case FormatGeoJSON:
+		err = export.WriteGeoJSON(w, flights, airports)
+	case FormatSVG:
+		err = export.WriteSVG(w, flights, airports)
+	}
+
+	if err != nil {
+		log.Printf("writeExport().export.Write(%v).Error: %v", format, err)
+	}
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"encoding/json"
+	"flights/pkg/export"
+	"flights/pkg/types"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSubRoutesHandler_Format(t *testing.T) {
+	mux, err := NewMux(Config{Airports: export.Airports{"SFO": {Latitude: 37.6213, Longitude: -122.3790}}})
+	if err != nil {
+		t.Logf("NewMux().error: %v", err)
+		t.FailNow()
+	}
+
+	tests := []struct {
+		query       string
+		body        string
+		statusCode  int
+		contentType string
+		contains    string
+	}{
+		{query: "", body: `[["SFO","GSO"]]`, statusCode: http.StatusOK, contentType: "application/json", contains: `"data":[[["SFO","GSO"]]]`},
+		{query: "?format=json", body: `[["SFO","GSO"]]`, statusCode: http.StatusOK, contentType: "application/json", contains: `"data":[[["SFO","GSO"]]]`},
+		{query: "?format=dot", body: `[["SFO","GSO"],["SFO","JFK"]]`, statusCode: http.StatusOK, contentType: "text/vnd.graphviz", contains: `"SFO" -> "JFK" [label="/1", color="#d62728"`},
+		{query: "?format=geojson", body: `[["SFO","GSO"],["GRU","JFK"]]`, statusCode: http.StatusOK, contentType: "application/geo+json", contains: `"coordinates":[-122.379,37.6213]`},
+		{query: "?format=svg", body: `[["SFO","GSO"]]`, statusCode: http.StatusOK, contentType: "image/svg+xml", contains: `<svg xmlns="http://www.w3.org/2000/svg"`},
+
+		// only the forks and the disconnected fragments are exported
+		{query: "?format=svg", body: `[["SFO","SFO"]]`, statusCode: http.StatusUnprocessableEntity, contentType: "application/json", contains: types.ErrorCodeInvalidFlight},
+		{query: "?format=png", body: `[["SFO","GSO"]]`, statusCode: http.StatusBadRequest, contentType: "application/json", contains: types.ErrorCodeInvalidParameter},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/v1/calculate"+test.query, strings.NewReader(test.body))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+
+		if w.Code != test.statusCode || w.Header().Get("Content-Type") != test.contentType || !strings.Contains(w.Body.String(), test.contains) {
+			t.Logf("%v: status code %v, content type %v, body: %s", test.query, w.Code, w.Header().Get("Content-Type"), w.Body.Bytes())
+			t.FailNow()
+		}
+
+		if test.contentType == "application/geo+json" && !json.Valid(w.Body.Bytes()) {
+			t.Logf("%v: invalid json: %s", test.query, w.Body.Bytes())
+			t.FailNow()
+		}
+	}
+}
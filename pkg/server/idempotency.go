@@ -0,0 +1,254 @@
+package server
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrIdempotencyKeyReused the idempotency key was already used with a different payload
+	ErrIdempotencyKeyReused = errors.New("idempotency key reused with a different payload")
+
+	// ErrIdempotencyInProgress the first request with the idempotency key is still running
+	ErrIdempotencyInProgress = errors.New("a request with the same idempotency key is in progress")
+
+	// ErrIdempotencyBodyTooLarge the payload of a request with idempotency key is bigger than MaxBodyBytes
+	ErrIdempotencyBodyTooLarge = errors.New("the payload is too large for an idempotency key")
+)
+
+// maxIdempotencyKeyLength maximum length of the Idempotency-Key header
+const maxIdempotencyKeyLength = 255
+
+// IdempotencyConfig configuration of the idempotency keys
+type IdempotencyConfig struct {
+	// TTL time the responses are kept and replayed. Default 24h
+	TTL time.Duration
+
+	// MaxEntries maximum number of stored responses. The oldest responses are removed first. Default 10000
+	MaxEntries int
+
+	// MaxBodyBytes maximum payload of a request with idempotency key, bigger payloads receive 413. Default 10MB
+	MaxBodyBytes int64
+
+	// MaxResponseBytes maximum size of a stored response. Bigger responses aren't stored. Default 1MB
+	MaxResponseBytes int
+
+	// MaxTotalBytes maximum size of all stored responses. The oldest responses are removed first. Default 256MB
+	MaxTotalBytes int
+}
+
+// idempotencyEntry response stored for an idempotency key
+type idempotencyEntry struct {
+	key       string
+	bodyHash  [sha256.Size]byte
+	createdAt time.Time
+	element   *list.Element
+
+	// done is closed when the response is stored. The fields below can only be read after it
+	done       chan struct{}
+	statusCode int
+	header     http.Header
+	body       []byte
+}
+
+// Idempotency stores the responses of requests with the Idempotency-Key header and replays them when the client
+// retries the request, so a retry doesn't process the payload twice
+type Idempotency struct {
+	config IdempotencyConfig
+	now    func() time.Time
+
+	mutex   sync.Mutex
+	entries map[string]*idempotencyEntry
+
+	// order entries in order of creation, the oldest in front. All entries have the same ttl, so it is also the
+	// order of expiration
+	order *list.List
+
+	// bytes size of the stored responses
+	bytes int
+}
+
+// NewIdempotency returns an empty idempotency store
+func NewIdempotency(config IdempotencyConfig) *Idempotency {
+	if config.TTL <= 0 {
+		config.TTL = 24 * time.Hour
+	}
+
+	if config.MaxEntries <= 0 {
+		config.MaxEntries = 10000
+	}
+
+	if config.MaxBodyBytes <= 0 {
+		config.MaxBodyBytes = 10 * 1024 * 1024
+	}
+
+	if config.MaxResponseBytes <= 0 {
+		config.MaxResponseBytes = 1024 * 1024
+	}
+
+	if config.MaxTotalBytes <= 0 {
+		config.MaxTotalBytes = 256 * 1024 * 1024
+	}
+
+	// a response that fits in MaxResponseBytes always fits in the budget
+	if config.MaxTotalBytes < config.MaxResponseBytes {
+		config.MaxTotalBytes = config.MaxResponseBytes
+	}
+
+	return &Idempotency{
+		config:  config,
+		now:     time.Now,
+		entries: make(map[string]*idempotencyEntry),
+		order:   list.New(),
+	}
+}
+
+// remove removes the entry. Must be called with the mutex locked
+func (e *Idempotency) remove(entry *idempotencyEntry) {
+	if e.entries[entry.key] == entry {
+		delete(e.entries, entry.key)
+		e.order.Remove(entry.element)
+		e.bytes -= len(entry.body)
+	}
+}
+
+// begin returns the stored entry of the key, or creates an entry in progress when the key is new
+func (e *Idempotency) begin(key string, bodyHash [sha256.Size]byte) (entry *idempotencyEntry, created bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	now := e.now()
+	for front := e.order.Front(); front != nil; front = e.order.Front() {
+		oldest := front.Value.(*idempotencyEntry)
+		if now.Sub(oldest.createdAt) < e.config.TTL && e.order.Len() < e.config.MaxEntries {
+			break
+		}
+		e.remove(oldest)
+	}
+
+	if entry, found := e.entries[key]; found {
+		return entry, false
+	}
+
+	entry = &idempotencyEntry{key: key, bodyHash: bodyHash, createdAt: now, done: make(chan struct{})}
+	entry.element = e.order.PushBack(entry)
+	e.entries[key] = entry
+	return entry, true
+}
+
+// transientStatus returns true for the responses the client can retry with the same key: server errors, timeouts,
+// conflicts, too early and too many requests
+func transientStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusRequestTimeout, http.StatusConflict, http.StatusTooEarly, http.StatusTooManyRequests:
+		return true
+	}
+	return statusCode >= http.StatusInternalServerError
+}
+
+// finish stores the response, or removes the entry when the response must not be replayed
+func (e *Idempotency) finish(entry *idempotencyEntry, response *bufferedResponseWriter) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	// big responses aren't stored, the retry processes the payload again
+	if response == nil || transientStatus(response.statusCode) || response.body.Len() > e.config.MaxResponseBytes {
+		e.remove(entry)
+		close(entry.done)
+		return
+	}
+
+	// the oldest responses are removed to keep the stored responses in the budget
+	for front := e.order.Front(); front != nil && e.bytes+response.body.Len() > e.config.MaxTotalBytes; front = e.order.Front() {
+		e.remove(front.Value.(*idempotencyEntry))
+	}
+
+	entry.statusCode = response.statusCode
+	entry.header = response.header.Clone()
+	entry.body = append([]byte(nil), response.body.Bytes()...)
+	if e.entries[entry.key] == entry {
+		e.bytes += len(entry.body)
+	}
+
+	close(entry.done)
+}
+
+// Middleware replays the stored response for a request with the same Idempotency-Key and payload.
+// The key is scoped by client, method, path and query. A key reused with a different payload receives 422, and a retry
+// sent while the first request is running receives 409. Requests without the header aren't changed.
+func (e *Idempotency) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			writeRestError(w, r, http.StatusBadRequest, fmt.Errorf("the Idempotency-Key header must have up to %v characters", maxIdempotencyKeyLength))
+			return
+		}
+
+		// the whole payload is compared, one byte more than the limit tells a bigger payload apart
+		data, err := io.ReadAll(io.LimitReader(r.Body, e.config.MaxBodyBytes+1))
+		if err != nil {
+			writeRestError(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		if int64(len(data)) > e.config.MaxBodyBytes {
+			writeRestError(w, r, http.StatusRequestEntityTooLarge, ErrIdempotencyBodyTooLarge)
+			return
+		}
+		r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(data), r.Body))
+
+		bodyHash := sha256.Sum256(data)
+		entry, created := e.begin(ClientKey(r)+"|"+r.Method+"|"+r.URL.RequestURI()+"|"+key, bodyHash)
+		if !created {
+			if entry.bodyHash != bodyHash {
+				writeRestError(w, r, http.StatusUnprocessableEntity, ErrIdempotencyKeyReused)
+				return
+			}
+
+			select {
+			case <-entry.done:
+			default:
+				w.Header().Set("Retry-After", "1")
+				writeRestError(w, r, http.StatusConflict, ErrIdempotencyInProgress)
+				return
+			}
+
+			// the response wasn't stored, the first request failed with a transient error
+			if entry.header == nil {
+				writeRestError(w, r, http.StatusConflict, ErrIdempotencyInProgress)
+				return
+			}
+
+			for name, values := range entry.header {
+				w.Header()[name] = values
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(entry.statusCode)
+			_, _ = w.Write(entry.body)
+			return
+		}
+
+		// a panic of the handler removes the entry, so the key can be used again
+		var response *bufferedResponseWriter
+		defer func() {
+			e.finish(entry, response)
+		}()
+
+		buffer := newBufferedResponseWriter()
+		next.ServeHTTP(buffer, r)
+		response = buffer
+		buffer.flush(w)
+	})
+}
@@ -0,0 +1,221 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIdempotency_Middleware(t *testing.T) {
+	idempotency := NewIdempotency(IdempotencyConfig{TTL: time.Minute, MaxEntries: 2})
+
+	var calls int64
+	handler := idempotency.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&calls, 1)
+		if strings.HasPrefix(r.Header.Get("Idempotency-Key"), "fail") {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if strings.HasPrefix(r.Header.Get("Idempotency-Key"), "busy") {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		GeneratesSubRoutesOfRoute(w, r)
+	}))
+
+	request := func(key, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/calculate", strings.NewReader(body))
+		if key != "" {
+			r.Header.Set("Idempotency-Key", key)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	tests := []struct {
+		key        string
+		body       string
+		statusCode int
+		replayed   bool
+		calls      int64
+	}{
+		{key: "a", body: `[["SFO","EWR"]]`, statusCode: http.StatusOK, calls: 1},
+		{key: "a", body: `[["SFO","EWR"]]`, statusCode: http.StatusOK, replayed: true, calls: 1},
+		{key: "a", body: `[["SFO","GRU"]]`, statusCode: http.StatusUnprocessableEntity, calls: 1},
+		{key: "b", body: `[["SFO","GRU"]]`, statusCode: http.StatusOK, calls: 2},
+		{body: `[["SFO","GRU"]]`, statusCode: http.StatusOK, calls: 3},
+		{body: `[["SFO","GRU"]]`, statusCode: http.StatusOK, calls: 4},
+
+		// server errors aren't stored, the client can retry
+		{key: "fail", body: `[["SFO","EWR"]]`, statusCode: http.StatusInternalServerError, calls: 5},
+		{key: "fail", body: `[["SFO","EWR"]]`, statusCode: http.StatusInternalServerError, calls: 6},
+
+		// transient errors aren't stored either
+		{key: "busy", body: `[["SFO","EWR"]]`, statusCode: http.StatusTooManyRequests, calls: 7},
+		{key: "busy", body: `[["SFO","EWR"]]`, statusCode: http.StatusTooManyRequests, calls: 8},
+
+		// the limit of two entries removed "a", the oldest one
+		{key: "a", body: `[["SFO","GRU"]]`, statusCode: http.StatusOK, calls: 9},
+		{key: strings.Repeat("k", 256), body: `[["SFO","GRU"]]`, statusCode: http.StatusBadRequest, calls: 9},
+	}
+
+	for k, test := range tests {
+		w := request(test.key, test.body)
+		if w.Code != test.statusCode || (w.Header().Get("Idempotent-Replayed") == "true") != test.replayed || atomic.LoadInt64(&calls) != test.calls {
+			t.Logf("test %v: status code %v, replayed: %v, calls: %v", k, w.Code, w.Header().Get("Idempotent-Replayed"), calls)
+			t.FailNow()
+		}
+	}
+
+	// the response expires after the ttl
+	now := time.Now().Add(2 * time.Minute)
+	idempotency.now = func() time.Time { return now }
+	if w := request("b", `[["SFO","EWR"]]`); w.Code != http.StatusOK || w.Header().Get("Idempotent-Replayed") != "" {
+		t.Logf("the key must be accepted again after the ttl. status code: %v", w.Code)
+		t.FailNow()
+	}
+}
+
+func TestIdempotency_InProgress(t *testing.T) {
+	idempotency := NewIdempotency(IdempotencyConfig{})
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	handler := idempotency.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusAccepted)
+	}))
+
+	request := func() *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/v1/jobs", strings.NewReader(`[["SFO","EWR"]]`))
+		r.Header.Set("Idempotency-Key", "job")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	first := make(chan *httptest.ResponseRecorder)
+	go func() {
+		first <- request()
+	}()
+	<-started
+
+	if w := request(); w.Code != http.StatusConflict {
+		t.Logf("a retry during the first request must return 409, found: %v", w.Code)
+		t.FailNow()
+	}
+
+	close(release)
+	if w := <-first; w.Code != http.StatusAccepted {
+		t.Logf("the first request must return 202, found: %v", w.Code)
+		t.FailNow()
+	}
+
+	if w := request(); w.Code != http.StatusAccepted || w.Header().Get("Idempotent-Replayed") != "true" {
+		t.Logf("the response must be replayed after the first request, found: %v", w.Code)
+		t.FailNow()
+	}
+}
+
+func TestIdempotency_RateLimited(t *testing.T) {
+	limiter, _ := NewRateLimiter(RateLimitConfig{Default: &RateLimitRule{Rate: 1, Burst: 1}})
+	now := time.Unix(1000, 0)
+	limiter.now = func() time.Time { return now }
+
+	mux, err := NewMux(Config{RateLimiter: limiter, Idempotency: NewIdempotency(IdempotencyConfig{TTL: time.Hour, MaxEntries: 10})})
+	if err != nil {
+		t.Logf("NewMux().error: %v", err)
+		t.FailNow()
+	}
+
+	request := func(key string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/v1/calculate", strings.NewReader(`[["SFO","EWR"]]`))
+		r.RemoteAddr = "10.0.0.1:1000"
+		if key != "" {
+			r.Header.Set("Idempotency-Key", key)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := request(""); w.Code != http.StatusOK {
+		t.Logf("the first request must drain the bucket. status code: %v", w.Code)
+		t.FailNow()
+	}
+
+	if w := request("mobile"); w.Code != http.StatusTooManyRequests {
+		t.Logf("the request must be rate limited. status code: %v", w.Code)
+		t.FailNow()
+	}
+
+	// the retry after the back off reaches the handler, the 429 wasn't stored under the key
+	now = now.Add(time.Second)
+	if w := request("mobile"); w.Code != http.StatusOK || w.Header().Get("Idempotent-Replayed") != "" {
+		t.Logf("the retry must reach the handler. status code: %v, replayed: %v", w.Code, w.Header().Get("Idempotent-Replayed"))
+		t.FailNow()
+	}
+}
+
+func TestIdempotency_Limits(t *testing.T) {
+	idempotency := NewIdempotency(IdempotencyConfig{MaxBodyBytes: 32, MaxResponseBytes: 10, MaxTotalBytes: 20})
+
+	var calls int64
+	handler := idempotency.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&calls, 1)
+		body, _ := io.ReadAll(r.Body)
+		_, _ = w.Write(body)
+	}))
+
+	request := func(key, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/calculate", strings.NewReader(body))
+		r.Header.Set("Idempotency-Key", key)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	tests := []struct {
+		key        string
+		body       string
+		statusCode int
+		replayed   bool
+		calls      int64
+	}{
+		{key: "a", body: "aaaaaaaa", statusCode: http.StatusOK, calls: 1},
+		{key: "a", body: "aaaaaaaa", statusCode: http.StatusOK, replayed: true, calls: 1},
+
+		// responses over maxResponseBytes aren't stored
+		{key: "big", body: "bbbbbbbbbbbb", statusCode: http.StatusOK, calls: 2},
+		{key: "big", body: "bbbbbbbbbbbb", statusCode: http.StatusOK, calls: 3},
+
+		// the third response doesn't fit in maxTotalBytes with the others, the oldest one is removed
+		{key: "b", body: "bbbbbbbb", statusCode: http.StatusOK, calls: 4},
+		{key: "c", body: "cccccccc", statusCode: http.StatusOK, calls: 5},
+		{key: "c", body: "cccccccc", statusCode: http.StatusOK, replayed: true, calls: 5},
+		{key: "b", body: "bbbbbbbb", statusCode: http.StatusOK, replayed: true, calls: 5},
+		{key: "a", body: "aaaaaaaa", statusCode: http.StatusOK, calls: 6},
+
+		// payloads over maxBodyBytes aren't compared by a prefix
+		{key: "d", body: strings.Repeat("d", 33), statusCode: http.StatusRequestEntityTooLarge, calls: 6},
+	}
+
+	for k, test := range tests {
+		w := request(test.key, test.body)
+		if w.Code != test.statusCode || (w.Header().Get("Idempotent-Replayed") == "true") != test.replayed || atomic.LoadInt64(&calls) != test.calls {
+			t.Logf("test %v: status code %v, replayed: %v, calls: %v", k, w.Code, w.Header().Get("Idempotent-Replayed"), calls)
+			t.FailNow()
+		}
+	}
+
+	if idempotency.bytes > 20 {
+		t.Logf("the stored responses must fit in maxTotalBytes: %v", idempotency.bytes)
+		t.FailNow()
+	}
+}
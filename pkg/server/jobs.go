@@ -0,0 +1,160 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"flights/pkg/jobs"
+	"flights/pkg/types"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+const (
+	// defaultResultLimit sub routes per chunk when the client doesn't inform the limit
+	defaultResultLimit = 1000
+
+	// maxResultLimit maximum sub routes per chunk
+	maxResultLimit = 10000
+)
+
+// JobResult chunk of the result of a job
+type JobResult struct {
+	Offset int64 `json:"offset"`
+	Limit  int64 `json:"limit"`
+	Total  int64 `json:"total"`
+
+	// Next [optional] offset of the next chunk, omitted in the last chunk
+	Next *int64 `json:"next,omitempty"`
+
+	Routes [][][]string `json:"routes"`
+}
+
+// JobsHandler endpoints of the asynchronous sub routes jobs
+type JobsHandler struct {
+	Manager *jobs.Manager
+}
+
+// writeJob writes the status of the job in the RestFul format
+func writeJob(w http.ResponseWriter, statusCode int, job jobs.Job) {
+	var rest types.RestFul
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	rest.Success(job)
+	err := json.NewEncoder(w).Encode(rest)
+	if err != nil {
+		log.Printf("writeJob().json.NewEncoder(w).Encode(rest).Error: %v", err)
+	}
+}
+
+// jobError writes the error of the job manager with its status code
+func jobError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, jobs.ErrJobNotFound):
+		writeRestError(w, r, http.StatusNotFound, err)
+	case errors.Is(err, jobs.ErrJobFinished), errors.Is(err, jobs.ErrJobNotSucceeded):
+		writeRestError(w, r, http.StatusConflict, err)
+	case errors.Is(err, jobs.ErrQueueFull), errors.Is(err, jobs.ErrManagerClosed):
+		w.Header().Set("Retry-After", "30")
+		writeRestError(w, r, http.StatusServiceUnavailable, err)
+	default:
+		writeRestError(w, r, http.StatusInternalServerError, err)
+	}
+}
+
+// Create this endpoint queues a job and returns its id
+// Entrada: POST [["IND", "EWR"], ["SFO", "ATL"], ["GSO", "IND"], ["ATL", "GSO"]]
+func (e JobsHandler) Create(w http.ResponseWriter, r *http.Request) {
+	flights, statusCode, err := decodeFlights(r)
+	if err != nil {
+		writeRestError(w, r, statusCode, err)
+		return
+	}
+
+	job, err := e.Manager.Submit(flights)
+	if err != nil {
+		jobError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", "/v1/jobs/"+job.ID)
+	writeJob(w, http.StatusAccepted, job)
+}
+
+// Status this endpoint returns the status and the progress of the job
+func (e JobsHandler) Status(w http.ResponseWriter, r *http.Request) {
+	job, err := e.Manager.Get(PathParam(r, "id"))
+	if err != nil {
+		jobError(w, r, err)
+		return
+	}
+
+	writeJob(w, http.StatusOK, job)
+}
+
+// Cancel this endpoint cancels a queued or running job
+func (e JobsHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	job, err := e.Manager.Cancel(PathParam(r, "id"))
+	if err != nil {
+		jobError(w, r, err)
+		return
+	}
+
+	writeJob(w, http.StatusOK, job)
+}
+
+// queryInt returns the integer query parameter, or the default value when it isn't informed
+func queryInt(r *http.Request, name string, defaultValue, min, max int64) (value int64, err error) {
+	text := r.URL.Query().Get(name)
+	if text == "" {
+		return defaultValue, nil
+	}
+
+	value, err = strconv.ParseInt(text, 10, 64)
+	if err != nil || value < min || value > max {
+		message := fmt.Sprintf("the query parameter '%v' must be an integer between %v and %v", name, min, max)
+		return 0, types.NewError(types.ErrorCodeInvalidParameter, "", message)
+	}
+
+	return
+}
+
+// Result this endpoint returns a chunk of the result of a succeeded job.
+// Query: offset, the first sub route, and limit, the number of sub routes. The Link header points to the next chunk
+func (e JobsHandler) Result(w http.ResponseWriter, r *http.Request) {
+	var rest types.RestFul
+
+	offset, err := queryInt(r, "offset", 0, 0, 1<<62)
+	if err != nil {
+		writeRestError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	limit, err := queryInt(r, "limit", defaultResultLimit, 1, maxResultLimit)
+	if err != nil {
+		writeRestError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	id := PathParam(r, "id")
+	routes, total, err := e.Manager.Result(id, offset, limit)
+	if err != nil {
+		jobError(w, r, err)
+		return
+	}
+
+	result := JobResult{Offset: offset, Limit: limit, Total: total, Routes: routes}
+	if next := offset + int64(len(routes)); next < total {
+		result.Next = &next
+		w.Header().Set("Link", fmt.Sprintf(`</v1/jobs/%v/result?offset=%v&limit=%v>; rel="next"`, id, next, limit))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	rest.Success(result)
+	err = json.NewEncoder(w).Encode(rest)
+	if err != nil {
+		log.Printf("JobsHandler.Result().json.NewEncoder(w).Encode(rest).Error: %v", err)
+	}
+}
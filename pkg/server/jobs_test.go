@@ -0,0 +1,86 @@
+package server
+
+import (
+	"encoding/json"
+	"flights/pkg/jobs"
+	"flights/pkg/types"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJobsHandler(t *testing.T) {
+	manager := jobs.NewManager(jobs.Config{Workers: 1})
+	defer manager.Close()
+
+	mux, err := NewMux(Config{Jobs: manager})
+	if err != nil {
+		t.Logf("NewMux().error: %v", err)
+		t.FailNow()
+	}
+
+	request := func(method, target, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	var created struct {
+		Data jobs.Job `json:"data"`
+	}
+	w := request(http.MethodPost, "/v1/jobs", `[["IND","EWR"],["SFO","ATL"],["GSO","IND"],["ATL","GSO"]]`)
+	_ = json.Unmarshal(w.Body.Bytes(), &created)
+	if w.Code != http.StatusAccepted || w.Header().Get("Location") != "/v1/jobs/"+created.Data.ID {
+		t.Logf("the job must be accepted. status code: %v, body: %s", w.Code, w.Body.Bytes())
+		t.FailNow()
+	}
+
+	var status struct {
+		Data jobs.Job `json:"data"`
+	}
+	for i := 0; i != 500 && status.Data.Status != jobs.StatusSucceeded; i += 1 {
+		time.Sleep(10 * time.Millisecond)
+		_ = json.Unmarshal(request(http.MethodGet, "/v1/jobs/"+created.Data.ID, "").Body.Bytes(), &status)
+	}
+
+	if status.Data.Status != jobs.StatusSucceeded || status.Data.Progress != 1 {
+		t.Logf("the job must succeed: %+v", status.Data)
+		t.FailNow()
+	}
+
+	// follows the Link header until the last chunk
+	routes := make([][][]string, 0)
+	next := "/v1/jobs/" + created.Data.ID + "/result?limit=4"
+	for next != "" {
+		var chunk struct {
+			Data JobResult `json:"data"`
+		}
+
+		w = request(http.MethodGet, next, "")
+		if err = json.Unmarshal(w.Body.Bytes(), &chunk); err != nil || w.Code != http.StatusOK {
+			t.Logf("%v: status code: %v, body: %s", next, w.Code, w.Body.Bytes())
+			t.FailNow()
+		}
+		routes = append(routes, chunk.Data.Routes...)
+
+		next = ""
+		if link := w.Header().Get("Link"); link != "" {
+			next = link[1:strings.Index(link, ">")]
+		}
+	}
+
+	flights := types.Flights{{"IND", "EWR"}, {"SFO", "ATL"}, {"GSO", "IND"}, {"ATL", "GSO"}}
+	if !reflect.DeepEqual(routes, flights.GetSubRoutes()) {
+		t.Logf("the chunks must form the result of GetSubRoutes(): %v", routes)
+		t.FailNow()
+	}
+
+	if w = request(http.MethodDelete, "/v1/jobs/"+created.Data.ID, ""); w.Code != http.StatusConflict {
+		t.Logf("a finished job can't be cancelled. status code: %v", w.Code)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,242 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// jsonSchema subset of JSON Schema 2020-12 used by the OpenAPI document of the server
+type jsonSchema struct {
+	Ref                  string                 `json:"$ref"`
+	Type                 jsonSchemaType         `json:"type"`
+	Items                *jsonSchema            `json:"items"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	AdditionalProperties *bool                  `json:"additionalProperties"`
+	Required             []string               `json:"required"`
+	MinItems             *int                   `json:"minItems"`
+	MaxItems             *int                   `json:"maxItems"`
+	MinLength            *int                   `json:"minLength"`
+	MaxLength            *int                   `json:"maxLength"`
+	Minimum              *float64               `json:"minimum"`
+	Maximum              *float64               `json:"maximum"`
+	Pattern              string                 `json:"pattern"`
+	Enum                 []any                  `json:"enum"`
+	OneOf                []*jsonSchema          `json:"oneOf"`
+
+	pattern *regexp.Regexp
+}
+
+// jsonSchemaType "type" can be a string or a list of strings
+type jsonSchemaType []string
+
+func (e *jsonSchemaType) UnmarshalJSON(data []byte) error {
+	var single string
+	if json.Unmarshal(data, &single) == nil {
+		*e = []string{single}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*e = list
+	return nil
+}
+
+// SchemaError error of validation with the json pointer of the invalid value. Ex.: /3/1
+type SchemaError struct {
+	Pointer string
+	Message string
+}
+
+func (e SchemaError) Error() string {
+	if e.Pointer == "" {
+		return e.Message
+	}
+	return e.Pointer + ": " + e.Message
+}
+
+// escapePointer escapes a json pointer token, RFC 6901
+func escapePointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+// schemaValidator resolves references and validates values
+type schemaValidator struct {
+	schemas map[string]*jsonSchema
+}
+
+// compile prepares the regular expressions of the schema and its children
+func (e *schemaValidator) compile(schema *jsonSchema) (err error) {
+	if schema == nil {
+		return
+	}
+
+	if schema.Pattern != "" && schema.pattern == nil {
+		if schema.pattern, err = regexp.Compile(schema.Pattern); err != nil {
+			return
+		}
+	}
+
+	if err = e.compile(schema.Items); err != nil {
+		return
+	}
+
+	for _, property := range schema.Properties {
+		if err = e.compile(property); err != nil {
+			return
+		}
+	}
+
+	for _, option := range schema.OneOf {
+		if err = e.compile(option); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// resolve follows the $ref of the schema
+func (e *schemaValidator) resolve(schema *jsonSchema) (*jsonSchema, error) {
+	for schema.Ref != "" {
+		name := strings.TrimPrefix(schema.Ref, "#/components/schemas/")
+		resolved, found := e.schemas[name]
+		if !found {
+			return nil, fmt.Errorf("schema reference not found: %v", schema.Ref)
+		}
+		schema = resolved
+	}
+	return schema, nil
+}
+
+// typeOf json type of a value decoded by encoding/json
+func typeOf(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return "unknown"
+}
+
+// validate returns all errors of the value, each one with the json pointer of the invalid value
+func (e *schemaValidator) validate(schema *jsonSchema, value any, pointer string) (errs []SchemaError) {
+	schema, err := e.resolve(schema)
+	if err != nil {
+		return []SchemaError{{Pointer: pointer, Message: err.Error()}}
+	}
+
+	if len(schema.Type) != 0 {
+		valueType := typeOf(value)
+		accepted := false
+		for _, schemaType := range schema.Type {
+			if schemaType == valueType || (schemaType == "number" && valueType == "integer") {
+				accepted = true
+				break
+			}
+		}
+
+		if !accepted {
+			return []SchemaError{{Pointer: pointer, Message: fmt.Sprintf("must be %v, found %v", strings.Join(schema.Type, " or "), valueType)}}
+		}
+	}
+
+	if len(schema.Enum) != 0 {
+		found := false
+		for _, option := range schema.Enum {
+			if fmt.Sprint(option) == fmt.Sprint(value) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			errs = append(errs, SchemaError{Pointer: pointer, Message: fmt.Sprintf("must be one of %v", schema.Enum)})
+		}
+	}
+
+	if len(schema.OneOf) != 0 {
+		matches := 0
+		for _, option := range schema.OneOf {
+			if len(e.validate(option, value, pointer)) == 0 {
+				matches += 1
+			}
+		}
+
+		if matches != 1 {
+			errs = append(errs, SchemaError{Pointer: pointer, Message: "must match exactly one schema"})
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		length := len([]rune(v))
+		if schema.MinLength != nil && length < *schema.MinLength {
+			errs = append(errs, SchemaError{Pointer: pointer, Message: fmt.Sprintf("must have at least %v characters", *schema.MinLength)})
+		}
+		if schema.MaxLength != nil && length > *schema.MaxLength {
+			errs = append(errs, SchemaError{Pointer: pointer, Message: fmt.Sprintf("must have at most %v characters", *schema.MaxLength)})
+		}
+		if schema.pattern != nil && !schema.pattern.MatchString(v) {
+			errs = append(errs, SchemaError{Pointer: pointer, Message: fmt.Sprintf("must match the pattern %v", schema.Pattern)})
+		}
+
+	case float64:
+		if schema.Minimum != nil && v < *schema.Minimum {
+			errs = append(errs, SchemaError{Pointer: pointer, Message: fmt.Sprintf("must be greater than or equal to %v", *schema.Minimum)})
+		}
+		if schema.Maximum != nil && v > *schema.Maximum {
+			errs = append(errs, SchemaError{Pointer: pointer, Message: fmt.Sprintf("must be less than or equal to %v", *schema.Maximum)})
+		}
+
+	case []any:
+		if schema.MinItems != nil && len(v) < *schema.MinItems {
+			errs = append(errs, SchemaError{Pointer: pointer, Message: fmt.Sprintf("must have at least %v items", *schema.MinItems)})
+		}
+		if schema.MaxItems != nil && len(v) > *schema.MaxItems {
+			errs = append(errs, SchemaError{Pointer: pointer, Message: fmt.Sprintf("must have at most %v items", *schema.MaxItems)})
+		}
+		if schema.Items != nil {
+			for k, item := range v {
+				errs = append(errs, e.validate(schema.Items, item, pointer+"/"+strconv.Itoa(k))...)
+			}
+		}
+
+	case map[string]any:
+		for _, name := range schema.Required {
+			if _, found := v[name]; !found {
+				errs = append(errs, SchemaError{Pointer: pointer + "/" + escapePointer(name), Message: "is required"})
+			}
+		}
+		for name, property := range v {
+			propertySchema, found := schema.Properties[name]
+			if !found {
+				if schema.AdditionalProperties != nil && !*schema.AdditionalProperties {
+					errs = append(errs, SchemaError{Pointer: pointer + "/" + escapePointer(name), Message: "is not allowed"})
+				}
+				continue
+			}
+			errs = append(errs, e.validate(propertySchema, property, pointer+"/"+escapePointer(name))...)
+		}
+	}
+
+	return
+}
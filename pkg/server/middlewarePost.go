@@ -1,10 +1,7 @@
 package server
 
 import (
-	"encoding/json"
 	"flights/pkg/types"
-	"fmt"
-	"log"
 	"net/http"
 )
 
@@ -14,15 +11,8 @@ func MiddlewarePost(next http.Handler) http.Handler {
 		w.Header().Add("Content-Type", "application/json")
 
 		if r.Method != http.MethodPost {
-
-			var rest types.RestFul
-			rest.AddError(fmt.Errorf("Method not allowed"))
-			w.WriteHeader(http.StatusMethodNotAllowed)
-			err := json.NewEncoder(w).Encode(rest)
-			if err != nil {
-				log.Printf("myMiddlewarePost().json.NewEncoder(w).Encode(rest).Error: %v", err)
-			}
-
+			w.Header().Set("Allow", http.MethodPost)
+			writeRestError(w, r, http.StatusMethodNotAllowed, types.NewError(types.ErrorCodeMethodNotAllowed, "", "Method not allowed"))
 			return
 		}
 
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"flights/pkg/cache"
+	"flights/pkg/export"
+	"flights/pkg/jobs"
+	"net/http"
+)
+
+// Config optional features of the server. Nil fields are disabled
+type Config struct {
+	// Auth [optional] api key and jwt authentication
+	Auth *Auth
+
+	// RateLimiter [optional] rate limit and concurrency quota per client
+	RateLimiter *RateLimiter
+
+	// Cache [optional] memoization cache of the sub routes
+	Cache *cache.SubRoutes
+
+	// Idempotency [optional] replay of the responses of POST requests with the Idempotency-Key header
+	Idempotency *Idempotency
+
+	// Jobs [optional] asynchronous sub routes jobs, published under /v1/jobs
+	Jobs *jobs.Manager
+
+	// Airports [optional] coordinates of the airports of the geojson and svg formats. Default export.DefaultAirports()
+	Airports export.Airports
+
+	// CORS [optional] cross-origin resource sharing for browser clients
+	CORS *CORS
+
+	// Compression configuration of the compression middleware
+	Compression CompressionConfig
+}
+
+// NewMux returns the handler of the server with all endpoints and middlewares.
+// Endpoints are published under /v1, and /calculate is kept as an alias of /v1/calculate for older clients.
+func NewMux(config Config) (router *Router, err error) {
+	router = NewRouter()
+
+	validator, err := NewOpenAPIValidator()
+	if err != nil {
+		return
+	}
+
+	router.HandleFunc(http.MethodGet, "/openapi.json", OpenAPIDocument)
+	router.HandleFunc(http.MethodGet, "/docs", OpenAPIDocs)
+
+	if config.Airports == nil {
+		config.Airports = export.DefaultAirports()
+	}
+
+	subRoutesHandler := SubRoutesHandler{Cache: config.Cache, Airports: config.Airports}
+	if config.Cache != nil {
+		router.Handle(http.MethodGet, "/v1/cache/statistics", CacheStatistics(config.Cache))
+	}
+
+	// protect applies the authentication, the rate limit and the idempotency keys to an endpoint
+	protect := func(scope, endpoint string, idempotent bool, handler http.Handler) http.Handler {
+		if config.Idempotency != nil && idempotent {
+			handler = config.Idempotency.Middleware(handler)
+		}
+
+		// the limiter runs before the idempotency keys, so a rejection isn't stored and replayed for the key, and after
+		// the authentication to identify the client by api key or jwt subject
+		if config.RateLimiter != nil && endpoint != "" {
+			handler = config.RateLimiter.Middleware(endpoint, handler)
+		}
+
+		if config.Auth != nil {
+			handler = config.Auth.Middleware(scope, handler)
+		}
+
+		return MiddlewareClientIdentity(handler)
+	}
+
+	// compressed request bodies are decompressed before the rate limit estimates the cost of the payload
+	// the etag is calculated over the compressed response, so each content encoding has its own etag
+	calculateHandler := protect(ScopeRoutesCalculate, "/calculate", true, validator.Middleware("/v1/calculate", subRoutesHandler))
+	router.Handle(http.MethodPost, "/v1/calculate", MiddlewareETag(config.Compression.Middleware(calculateHandler)))
+	router.Alias("/calculate", "/v1/calculate")
+
+	// the streams aren't compressed, the compression middleware would hold the events until the end of the stream
+	streamHandler := StreamHandler{}
+	if config.CORS != nil {
+		streamHandler.CheckOrigin = config.CORS.AllowOrigin
+	}
+	streamEvents := validator.Middleware("/v1/calculate/events", http.HandlerFunc(streamHandler.Events))
+	router.Handle(http.MethodPost, "/v1/calculate/events", protect(ScopeRoutesCalculate, "/calculate", false, streamEvents))
+	router.Handle(http.MethodGet, "/v1/calculate/ws", protect(ScopeRoutesCalculate, "/calculate", false, http.HandlerFunc(streamHandler.WebSocket)))
+
+	if config.Jobs != nil {
+		jobsHandler := JobsHandler{Manager: config.Jobs}
+		createJob := validator.Middleware("/v1/jobs", http.HandlerFunc(jobsHandler.Create))
+
+		// only the creation costs tokens, clients poll the status without being limited
+		router.Handle(http.MethodPost, "/v1/jobs", config.Compression.Middleware(protect(ScopeRoutesBatch, "/jobs", true, createJob)))
+		router.Handle(http.MethodGet, "/v1/jobs/{id}", protect(ScopeRoutesBatch, "", false, http.HandlerFunc(jobsHandler.Status)))
+		router.Handle(http.MethodDelete, "/v1/jobs/{id}", protect(ScopeRoutesBatch, "", false, http.HandlerFunc(jobsHandler.Cancel)))
+		router.Handle(http.MethodGet, "/v1/jobs/{id}/result", config.Compression.Middleware(protect(ScopeRoutesBatch, "", false, http.HandlerFunc(jobsHandler.Result))))
+		router.Alias("/jobs", "/v1/jobs")
+		router.Alias("/jobs/{id}", "/v1/jobs/{id}")
+		router.Alias("/jobs/{id}/result", "/v1/jobs/{id}/result")
+	}
+
+	// preflight requests are answered before the routing, so the POST-only endpoints are never called with OPTIONS
+	if config.CORS != nil {
+		router.Use(config.CORS.Middleware)
+	}
+	return
+}
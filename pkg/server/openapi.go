@@ -0,0 +1,214 @@
+package server
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"flights/pkg/types"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// openAPIAssets OpenAPI document and documentation page, embedded in the binary
+//
+//go:embed openapi/openapi.json openapi/index.html
+var openAPIAssets embed.FS
+
+// maxSchemaErrors maximum number of validation errors returned to the client
+const maxSchemaErrors = 20
+
+// openAPIOperation part of the operation used by the server
+type openAPIOperation struct {
+	RequestBody *struct {
+		Required bool `json:"required"`
+		Content  map[string]struct {
+			Schema *jsonSchema `json:"schema"`
+		} `json:"content"`
+	} `json:"requestBody"`
+
+	Responses map[string]openAPIResponse `json:"responses"`
+}
+
+// openAPIResponse response of the operation, or a reference to a response of the components
+type openAPIResponse struct {
+	Ref     string `json:"$ref"`
+	Content map[string]struct {
+		Schema *jsonSchema `json:"schema"`
+	} `json:"content"`
+}
+
+// openAPIDocument part of the OpenAPI document used by the server
+type openAPIDocument struct {
+	OpenAPI    string                                 `json:"openapi"`
+	Paths      map[string]map[string]openAPIOperation `json:"paths"`
+	Components struct {
+		Schemas   map[string]*jsonSchema     `json:"schemas"`
+		Responses map[string]openAPIResponse `json:"responses"`
+	} `json:"components"`
+}
+
+// OpenAPIDocument this endpoint publishes the OpenAPI 3.1 document of the server
+func OpenAPIDocument(w http.ResponseWriter, r *http.Request) {
+	data, _ := openAPIAssets.ReadFile("openapi/openapi.json")
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(data)
+}
+
+// OpenAPIDocs this endpoint publishes the documentation page of the OpenAPI document
+func OpenAPIDocs(w http.ResponseWriter, r *http.Request) {
+	data, _ := openAPIAssets.ReadFile("openapi/index.html")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(data)
+}
+
+// OpenAPIValidator validates requests against the schemas of the OpenAPI document
+type OpenAPIValidator struct {
+	document  openAPIDocument
+	validator *schemaValidator
+}
+
+// NewOpenAPIValidator parses the embedded OpenAPI document
+func NewOpenAPIValidator() (validator *OpenAPIValidator, err error) {
+	data, err := openAPIAssets.ReadFile("openapi/openapi.json")
+	if err != nil {
+		return
+	}
+
+	validator = &OpenAPIValidator{}
+	if err = json.Unmarshal(data, &validator.document); err != nil {
+		return nil, fmt.Errorf("openapi.json: %v", err)
+	}
+
+	validator.validator = &schemaValidator{schemas: validator.document.Components.Schemas}
+	for name, schema := range validator.document.Components.Schemas {
+		if err = validator.validator.compile(schema); err != nil {
+			return nil, fmt.Errorf("openapi.json, schema %v: %v", name, err)
+		}
+	}
+
+	return
+}
+
+// Operations returns the methods of each path of the document. Ex.: {"/calculate": ["POST"]}
+func (e *OpenAPIValidator) Operations() (operations map[string][]string) {
+	operations = make(map[string][]string)
+	for path, methods := range e.document.Paths {
+		for method := range methods {
+			operations[path] = append(operations[path], strings.ToUpper(method))
+		}
+	}
+	return
+}
+
+// operation returns the operation of the path and method
+func (e *OpenAPIValidator) operation(path, method string) (operation openAPIOperation, found bool) {
+	methods, found := e.document.Paths[path]
+	if !found {
+		return
+	}
+
+	operation, found = methods[strings.ToLower(method)]
+	return
+}
+
+// ValidateValue validates a decoded json value against a schema of the document
+func (e *OpenAPIValidator) ValidateValue(schemaName string, value any) []SchemaError {
+	return e.validator.validate(&jsonSchema{Ref: "#/components/schemas/" + schemaName}, value, "")
+}
+
+// ValidateRequestBody validates the json body of the operation. The body is kept in the request
+func (e *OpenAPIValidator) ValidateRequestBody(path string, r *http.Request) (errs []SchemaError, err error) {
+	operation, found := e.operation(path, r.Method)
+	if !found || operation.RequestBody == nil {
+		return
+	}
+
+	content, found := operation.RequestBody.Content["application/json"]
+	if !found || content.Schema == nil {
+		return
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(data))
+
+	if len(bytes.TrimSpace(data)) == 0 {
+		if operation.RequestBody.Required {
+			errs = append(errs, SchemaError{Message: "the request body is required"})
+		}
+		return
+	}
+
+	var value any
+	if err = json.Unmarshal(data, &value); err != nil {
+		return nil, types.NewError(types.ErrorCodeMalformedPayload, "", err.Error())
+	}
+
+	errs = e.validator.validate(content.Schema, value, "")
+	return
+}
+
+// ValidateResponse validates the json body of a response of the operation against its schema
+func (e *OpenAPIValidator) ValidateResponse(path, method string, statusCode int, body []byte) (errs []SchemaError, err error) {
+	operation, found := e.operation(path, method)
+	if !found {
+		return nil, fmt.Errorf("operation %v %v not found", method, path)
+	}
+
+	response, found := operation.Responses[strconv.Itoa(statusCode)]
+	if !found {
+		return nil, fmt.Errorf("response %v of the operation %v %v not found", statusCode, method, path)
+	}
+
+	if ref := response.Ref; ref != "" {
+		response, found = e.document.Components.Responses[strings.TrimPrefix(ref, "#/components/responses/")]
+		if !found {
+			return nil, fmt.Errorf("response reference not found: %v", ref)
+		}
+	}
+
+	content, found := response.Content["application/json"]
+	if !found || content.Schema == nil {
+		return
+	}
+
+	var value any
+	if err = json.Unmarshal(body, &value); err != nil {
+		return
+	}
+
+	errs = e.validator.validate(content.Schema, value, "")
+	return
+}
+
+// Middleware validates the request body against the schema of the operation of the path.
+// Invalid requests receive 400 with one error per invalid value, each one starting with the json pointer of the value.
+func (e *OpenAPIValidator) Middleware(path string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		errs, err := e.ValidateRequestBody(path, r)
+		if err != nil {
+			writeRestError(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		if len(errs) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if len(errs) > maxSchemaErrors {
+			errs = errs[:maxSchemaErrors]
+		}
+
+		list := make([]error, len(errs))
+		for k := range errs {
+			list[k] = types.NewError(types.ErrorCodeInvalidPayload, errs[k].Pointer, errs[k].Message)
+		}
+		writeRestErrors(w, r, http.StatusBadRequest, list...)
+	})
+}
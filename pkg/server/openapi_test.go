@@ -0,0 +1,126 @@
+package server
+
+import (
+	"encoding/json"
+	"flights/pkg/cache"
+	"flights/pkg/jobs"
+	"flights/pkg/types"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// TestOpenAPI_Drift fails when the handlers and the OpenAPI document drift apart
+func TestOpenAPI_Drift(t *testing.T) {
+	manager := jobs.NewManager(jobs.Config{Workers: 1})
+	defer manager.Close()
+
+	mux, err := NewMux(Config{Cache: cache.NewSubRoutes(cache.NewLRU(10, 0, 0)), Jobs: manager})
+	if err != nil {
+		t.Logf("NewMux().error: %v", err)
+		t.FailNow()
+	}
+
+	validator, _ := NewOpenAPIValidator()
+
+	registered := make([]string, 0)
+	for _, route := range mux.Routes() {
+		registered = append(registered, route.Method+" "+route.Path)
+	}
+
+	documented := make([]string, 0)
+	for path, methods := range validator.Operations() {
+		for _, method := range methods {
+			documented = append(documented, method+" "+path)
+		}
+	}
+
+	sort.Strings(registered)
+	sort.Strings(documented)
+	if strings.Join(registered, ", ") != strings.Join(documented, ", ") {
+		t.Logf("the routes of the server are different from the OpenAPI document.\nserver:  %v\ndocument: %v", registered, documented)
+		t.FailNow()
+	}
+
+	tests := []struct {
+		method     string
+		path       string
+		body       string
+		statusCode int
+
+		// operation [optional] path of the operation in the document, when the path has parameters
+		operation string
+	}{
+		{method: http.MethodPost, path: "/calculate", body: `[["IND","EWR"],["SFO","ATL"],["GSO","IND"],["ATL","GSO"]]`, statusCode: http.StatusOK},
+		{method: http.MethodPost, path: "/calculate", body: `[["IND","EWR"],["SFO"]]`, statusCode: http.StatusBadRequest},
+		{method: http.MethodPost, path: "/calculate", body: `[]`, statusCode: http.StatusBadRequest},
+		{method: http.MethodGet, path: "/calculate", statusCode: http.StatusMethodNotAllowed},
+		{method: http.MethodPost, path: "/v1/calculate", body: `[["IND","EWR"],["EWR","SFO"],["GRU","JFK"]]`, statusCode: http.StatusUnprocessableEntity},
+		{method: http.MethodGet, path: "/v1/cache/statistics", statusCode: http.StatusOK},
+		{method: http.MethodGet, path: "/openapi.json", statusCode: http.StatusOK},
+		{method: http.MethodGet, path: "/docs", statusCode: http.StatusOK},
+		{method: http.MethodPost, path: "/v1/calculate/events", body: `[["IND","EWR"],["EWR","SFO"]]`, statusCode: http.StatusOK},
+		{method: http.MethodPost, path: "/v1/calculate/events", body: `[["IND","EWR"],["EWR","SFO"],["GRU","JFK"]]`, statusCode: http.StatusUnprocessableEntity},
+		{method: http.MethodPost, path: "/v1/jobs", body: `[["IND","EWR"],["SFO","ATL"],["GSO","IND"],["ATL","GSO"]]`, statusCode: http.StatusAccepted},
+		{method: http.MethodPost, path: "/v1/jobs", body: `[["IND","EWR"],["EWR","SFO"],["GRU","JFK"]]`, statusCode: http.StatusUnprocessableEntity},
+		{method: http.MethodGet, path: "/v1/jobs/unknown", statusCode: http.StatusNotFound, operation: "/v1/jobs/{id}"},
+		{method: http.MethodDelete, path: "/v1/jobs/unknown", statusCode: http.StatusNotFound, operation: "/v1/jobs/{id}"},
+		{method: http.MethodGet, path: "/v1/jobs/unknown/result", statusCode: http.StatusNotFound, operation: "/v1/jobs/{id}/result"},
+		{method: http.MethodGet, path: "/v1/jobs/unknown/result?limit=0", statusCode: http.StatusBadRequest, operation: "/v1/jobs/{id}/result"},
+		{method: http.MethodPost, path: "/jobs", body: `[["IND","EWR"],["EWR","SFO"]]`, statusCode: http.StatusAccepted},
+		{method: http.MethodGet, path: "/jobs/unknown", statusCode: http.StatusNotFound, operation: "/jobs/{id}"},
+		{method: http.MethodDelete, path: "/jobs/unknown", statusCode: http.StatusNotFound, operation: "/jobs/{id}"},
+		{method: http.MethodGet, path: "/jobs/unknown/result", statusCode: http.StatusNotFound, operation: "/jobs/{id}/result"},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+
+		if w.Code != test.statusCode {
+			t.Logf("%v %v: status code %v, expected %v. body: %s", test.method, test.path, w.Code, test.statusCode, w.Body.Bytes())
+			t.FailNow()
+		}
+
+		// the response of the 405 is sent for methods without operation in the document
+		method := test.method
+		if w.Code == http.StatusMethodNotAllowed {
+			method = http.MethodPost
+		}
+
+		operation := test.path
+		if test.operation != "" {
+			operation = test.operation
+		}
+
+		errs, err := validator.ValidateResponse(operation, method, w.Code, w.Body.Bytes())
+		if err != nil || len(errs) != 0 {
+			t.Logf("%v %v: the response doesn't match the document. error: %v, errors: %v", test.method, test.path, err, errs)
+			t.FailNow()
+		}
+	}
+}
+
+func TestOpenAPIValidator_Middleware(t *testing.T) {
+	validator, err := NewOpenAPIValidator()
+	if err != nil {
+		t.Logf("NewOpenAPIValidator().error: %v", err)
+		t.FailNow()
+	}
+
+	handler := validator.Middleware("/calculate", http.HandlerFunc(GeneratesSubRoutesOfRoute))
+
+	r := httptest.NewRequest(http.MethodPost, "/calculate", strings.NewReader(`[["IND","EWR"],["SFO","ATL"],["GSO","IND"],["ATL",1]]`))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	var rest types.RestFul
+	_ = json.Unmarshal(w.Body.Bytes(), &rest)
+	if w.Code != http.StatusBadRequest || len(rest.Meta.Error) != 1 || !strings.HasPrefix(rest.Meta.Error[0], "/3/1: ") {
+		t.Logf("the error must point to the invalid airport. status code: %v, errors: %v", w.Code, rest.Meta.Error)
+		t.FailNow()
+	}
+}
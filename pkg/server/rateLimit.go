@@ -0,0 +1,282 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited the client exceeded the rate limit or the concurrency quota
+var ErrRateLimited = errors.New("too many requests")
+
+// RateLimitRule limits of one endpoint, applied to each client
+type RateLimitRule struct {
+	// Rate tokens added to the bucket per second
+	Rate float64 `json:"rate"`
+
+	// Burst bucket size, maximum number of tokens accumulated
+	Burst int `json:"burst"`
+
+	// MaxConcurrent [optional] maximum number of simultaneous requests of the client. Zero means no limit
+	MaxConcurrent int `json:"maxConcurrent"`
+
+	// SubRoutesPerToken [optional] when informed, the request costs one token plus one token for each
+	// SubRoutesPerToken sub routes estimated from the payload. A list of n flights generates n(n+1)/2 sub routes.
+	SubRoutesPerToken int `json:"subRoutesPerToken"`
+
+	// MaxBodyBytes [optional] maximum payload read to estimate the cost. A bigger payload costs Burst. Default 10MB
+	MaxBodyBytes int64 `json:"maxBodyBytes"`
+
+	// ConcurrentRetryAfter [optional] seconds sent in Retry-After when MaxConcurrent is reached. The end of the
+	// requests in progress can't be predicted, so it is a fixed value. Default 1
+	ConcurrentRetryAfter int `json:"concurrentRetryAfter"`
+}
+
+// RateLimitConfig rate limit configuration of the server
+type RateLimitConfig struct {
+	// Default rule used by endpoints without a specific rule
+	Default *RateLimitRule `json:"default"`
+
+	// Endpoints rules by endpoint. Ex.: {"/calculate": {"rate": 5, "burst": 10}}
+	Endpoints map[string]RateLimitRule `json:"endpoints"`
+}
+
+// LoadRateLimitConfig reads the rate limit configuration from a json file
+func LoadRateLimitConfig(path string) (config RateLimitConfig, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(data, &config)
+	return
+}
+
+// tokenBucket tokens available to a client
+type tokenBucket struct {
+	rule       RateLimitRule
+	tokens     float64
+	lastRefill time.Time
+	concurrent int
+}
+
+// level returns the tokens of the bucket at now
+func (e *tokenBucket) level(now time.Time) float64 {
+	return math.Min(float64(e.rule.Burst), e.tokens+now.Sub(e.lastRefill).Seconds()*e.rule.Rate)
+}
+
+// RateLimiter token bucket rate limit and concurrency quota per client and endpoint
+type RateLimiter struct {
+	config RateLimitConfig
+	now    func() time.Time
+
+	mutex     sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+// NewRateLimiter returns a rate limiter with the configuration
+func NewRateLimiter(config RateLimitConfig) (limiter *RateLimiter, err error) {
+	check := func(name string, rule RateLimitRule) error {
+		if rule.Rate <= 0 || rule.Burst <= 0 {
+			return fmt.Errorf("the rate limit of '%v' must have rate and burst greater than zero", name)
+		}
+		if rule.ConcurrentRetryAfter < 0 {
+			return fmt.Errorf("the rate limit of '%v' must not have a negative concurrentRetryAfter", name)
+		}
+		return nil
+	}
+
+	if config.Default != nil {
+		if err = check("default", *config.Default); err != nil {
+			return
+		}
+	}
+
+	for endpoint, rule := range config.Endpoints {
+		if err = check(endpoint, rule); err != nil {
+			return
+		}
+	}
+
+	limiter = &RateLimiter{
+		config:  config,
+		now:     time.Now,
+		buckets: make(map[string]*tokenBucket),
+	}
+	return
+}
+
+// rule returns the rule of the endpoint
+func (e *RateLimiter) rule(endpoint string) (rule RateLimitRule, found bool) {
+	if rule, found = e.config.Endpoints[endpoint]; found {
+		return
+	}
+
+	if e.config.Default != nil {
+		return *e.config.Default, true
+	}
+
+	return
+}
+
+// ClientKey identifies the client by api key, jwt subject, client certificate or ip address, in this order
+func ClientKey(r *http.Request) string {
+	if principal, ok := PrincipalFromContext(r.Context()); ok {
+		return principal.Method + ":" + principal.Subject
+	}
+
+	if identity, ok := ClientIdentityFromContext(r.Context()); ok {
+		return "cert:" + identity.CommonName
+	}
+
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	return "ip:" + ip
+}
+
+// estimateCost reads the payload to estimate the number of sub routes and puts the payload back in the request
+func (e *RateLimiter) estimateCost(rule RateLimitRule, r *http.Request) (cost float64, err error) {
+	cost = 1
+	if rule.SubRoutesPerToken <= 0 || r.Body == nil {
+		return
+	}
+
+	maxBodyBytes := rule.MaxBodyBytes
+	if maxBodyBytes == 0 {
+		maxBodyBytes = 10 * 1024 * 1024
+	}
+
+	// one byte more than the limit tells a truncated payload apart
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxBodyBytes+1))
+	if err != nil {
+		return
+	}
+	r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(data), r.Body))
+
+	// a payload over the limit can't be estimated and is one of the most expensive, it costs the whole bucket
+	if int64(len(data)) > maxBodyBytes {
+		return float64(rule.Burst), nil
+	}
+
+	// an invalid payload costs one token and the error is returned by the endpoint
+	var flights []json.RawMessage
+	if json.Unmarshal(data, &flights) != nil {
+		return
+	}
+
+	n := float64(len(flights))
+	cost += math.Floor(n * (n + 1) / 2 / float64(rule.SubRoutesPerToken))
+	return
+}
+
+// take removes the tokens of the bucket and increments the concurrency counter.
+// Returns the remaining tokens or how long the client must wait.
+func (e *RateLimiter) take(key string, rule RateLimitRule, cost float64) (remaining float64, retryAfter time.Duration, ok bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	now := e.now()
+	e.sweep(now)
+
+	bucket, found := e.buckets[key]
+	if !found {
+		bucket = &tokenBucket{rule: rule, tokens: float64(rule.Burst), lastRefill: now}
+		e.buckets[key] = bucket
+	}
+
+	bucket.tokens = bucket.level(now)
+	bucket.lastRefill = now
+
+	// a payload bigger than the bucket consumes the whole bucket, otherwise it would never be accepted
+	cost = math.Min(cost, float64(rule.Burst))
+
+	if rule.MaxConcurrent > 0 && bucket.concurrent >= rule.MaxConcurrent {
+		retryAfter = time.Second
+		if rule.ConcurrentRetryAfter > 0 {
+			retryAfter = time.Duration(rule.ConcurrentRetryAfter) * time.Second
+		}
+		return bucket.tokens, retryAfter, false
+	}
+
+	if bucket.tokens < cost {
+		wait := (cost - bucket.tokens) / rule.Rate
+		return bucket.tokens, time.Duration(wait * float64(time.Second)), false
+	}
+
+	bucket.tokens -= cost
+	bucket.concurrent += 1
+	return bucket.tokens, 0, true
+}
+
+// release decrements the concurrency counter
+func (e *RateLimiter) release(key string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if bucket, found := e.buckets[key]; found && bucket.concurrent > 0 {
+		bucket.concurrent -= 1
+	}
+}
+
+// sweep removes idle buckets once a minute to keep the memory bounded.
+// Only full buckets are removed, idle for at least burst/rate, because a removed bucket comes back full.
+func (e *RateLimiter) sweep(now time.Time) {
+	if now.Sub(e.lastSweep) < time.Minute {
+		return
+	}
+	e.lastSweep = now
+
+	for key, bucket := range e.buckets {
+		if bucket.concurrent == 0 && bucket.level(now) >= float64(bucket.rule.Burst) {
+			delete(e.buckets, key)
+		}
+	}
+}
+
+// Middleware applies the rule of the endpoint to each client.
+// Rejected requests receive 429 with Retry-After and RateLimit-* headers.
+func (e *RateLimiter) Middleware(endpoint string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rule, found := e.rule(endpoint)
+		if !found {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cost, err := e.estimateCost(rule, r)
+		if err != nil {
+			writeRestError(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		key := endpoint + "|" + ClientKey(r)
+		remaining, retryAfter, ok := e.take(key, rule, cost)
+
+		reset := math.Ceil((float64(rule.Burst) - remaining) / rule.Rate)
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(rule.Burst))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(int(math.Floor(remaining))))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(int(reset)))
+
+		if !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			writeRestError(w, r, http.StatusTooManyRequests, ErrRateLimited)
+			return
+		}
+		defer e.release(key)
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,196 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_Middleware(t *testing.T) {
+	limiter, err := NewRateLimiter(RateLimitConfig{
+		Endpoints: map[string]RateLimitRule{
+			"/calculate": {Rate: 1, Burst: 3, SubRoutesPerToken: 5},
+		},
+	})
+	if err != nil {
+		t.Logf("NewRateLimiter().error: %v", err)
+		t.FailNow()
+	}
+
+	now := time.Unix(1000, 0)
+	limiter.now = func() time.Time { return now }
+
+	handler := limiter.Middleware("/calculate", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	send := func(remoteAddr, payload string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/calculate", strings.NewReader(payload))
+		r.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	small := `[["IND","EWR"]]`
+	for i := 0; i != 3; i += 1 {
+		if w := send("10.0.0.1:1000", small); w.Code != http.StatusOK {
+			t.Logf("request %v must be accepted. status code: %v", i, w.Code)
+			t.FailNow()
+		}
+	}
+
+	w := send("10.0.0.1:1000", small)
+	if w.Code != http.StatusTooManyRequests {
+		t.Logf("the bucket must be empty. status code: %v", w.Code)
+		t.FailNow()
+	}
+
+	if w.Header().Get("Retry-After") != "1" || w.Header().Get("RateLimit-Remaining") != "0" || w.Header().Get("RateLimit-Limit") != "3" {
+		t.Logf("rate limit headers error: %v", w.Header())
+		t.FailNow()
+	}
+
+	// other clients have their own bucket
+	if w := send("10.0.0.2:1000", small); w.Code != http.StatusOK {
+		t.Logf("other client must be accepted. status code: %v", w.Code)
+		t.FailNow()
+	}
+
+	// four flights generate 10 sub routes and cost 1 + 10/5 tokens
+	now = now.Add(3 * time.Second)
+	big := `[["IND","EWR"],["SFO","ATL"],["GSO","IND"],["ATL","GSO"]]`
+	if w := send("10.0.0.1:1000", big); w.Code != http.StatusOK || w.Header().Get("RateLimit-Remaining") != "0" {
+		t.Logf("the big payload must cost three tokens. status code: %v, headers: %v", w.Code, w.Header())
+		t.FailNow()
+	}
+}
+
+func TestRateLimiter_MaxConcurrent(t *testing.T) {
+	limiter, _ := NewRateLimiter(RateLimitConfig{
+		Default: &RateLimitRule{Rate: 100, Burst: 100, MaxConcurrent: 2},
+	})
+
+	release := make(chan struct{})
+	var started sync.WaitGroup
+	started.Add(2)
+
+	handler := limiter.Middleware("/calculate", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started.Done()
+		<-release
+	}))
+
+	send := func() int {
+		r := httptest.NewRequest(http.MethodPost, "/calculate", nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	var done sync.WaitGroup
+	for i := 0; i != 2; i += 1 {
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			send()
+		}()
+	}
+	started.Wait()
+
+	if code := send(); code != http.StatusTooManyRequests {
+		t.Logf("the third concurrent request must be rejected. status code: %v", code)
+		t.FailNow()
+	}
+
+	close(release)
+	done.Wait()
+
+	started.Add(1)
+	if code := send(); code != http.StatusOK {
+		t.Logf("the request must be accepted after the others finish. status code: %v", code)
+		t.FailNow()
+	}
+}
+
+func TestRateLimiter_sweep(t *testing.T) {
+	// the bucket takes 1000 seconds to be full again
+	limiter, _ := NewRateLimiter(RateLimitConfig{Default: &RateLimitRule{Rate: 1, Burst: 1000}})
+
+	now := time.Unix(1000, 0)
+	limiter.now = func() time.Time { return now }
+
+	if _, _, ok := limiter.take("client", RateLimitRule{Rate: 1, Burst: 1000}, 1000); !ok {
+		t.Log("the first request must drain the bucket")
+		t.FailNow()
+	}
+	limiter.release("client")
+
+	// an idle bucket that isn't full must be kept, otherwise it would come back full
+	now = now.Add(2 * time.Minute)
+	if remaining, _, ok := limiter.take("client", RateLimitRule{Rate: 1, Burst: 1000}, 1000); ok || remaining != 120 {
+		t.Logf("the bucket must not be removed before it is full. remaining: %v", remaining)
+		t.FailNow()
+	}
+
+	// a full bucket is removed
+	now = now.Add(1000 * time.Second)
+	limiter.sweep(now)
+	if len(limiter.buckets) != 0 {
+		t.Logf("the full bucket must be removed: %v", limiter.buckets)
+		t.FailNow()
+	}
+}
+
+func TestRateLimiter_ConcurrentRetryAfter(t *testing.T) {
+	if _, err := NewRateLimiter(RateLimitConfig{Default: &RateLimitRule{Rate: 1, Burst: 1, ConcurrentRetryAfter: -1}}); err == nil {
+		t.Log("a negative concurrentRetryAfter must be rejected")
+		t.FailNow()
+	}
+
+	rule := RateLimitRule{Rate: 100, Burst: 100, MaxConcurrent: 1, ConcurrentRetryAfter: 5}
+	limiter, _ := NewRateLimiter(RateLimitConfig{Default: &rule})
+
+	if _, _, ok := limiter.take("client", rule, 1); !ok {
+		t.Log("the first request must be accepted")
+		t.FailNow()
+	}
+
+	if _, retryAfter, ok := limiter.take("client", rule, 1); ok || retryAfter != 5*time.Second {
+		t.Logf("the concurrent request must wait concurrentRetryAfter. retryAfter: %v", retryAfter)
+		t.FailNow()
+	}
+}
+
+func TestRateLimiter_estimateCost(t *testing.T) {
+	rule := RateLimitRule{Rate: 1, Burst: 50, SubRoutesPerToken: 5, MaxBodyBytes: 50}
+	limiter, _ := NewRateLimiter(RateLimitConfig{Default: &rule})
+
+	tests := []struct {
+		payload string
+		cost    float64
+	}{
+		{payload: `[["IND","EWR"]]`, cost: 1},
+		{payload: `[["IND","EWR"],["SFO","ATL"],["GSO","IND"]]`, cost: 2},
+		{payload: `invalid`, cost: 1},
+
+		// the payload over maxBodyBytes costs the whole bucket
+		{payload: `[["IND","EWR"],["SFO","ATL"],["GSO","IND"],["ATL","GSO"]]`, cost: 50},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/calculate", strings.NewReader(test.payload))
+		cost, err := limiter.estimateCost(rule, r)
+		if err != nil || cost != test.cost {
+			t.Logf("%v: cost %v, expected %v, error: %v", test.payload, cost, test.cost, err)
+			t.FailNow()
+		}
+
+		// the payload is put back in the request
+		if body, _ := io.ReadAll(r.Body); string(body) != test.payload {
+			t.Logf("the payload must be put back in the request: %s", body)
+			t.FailNow()
+		}
+	}
+}
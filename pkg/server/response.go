@@ -0,0 +1,95 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"flights/pkg/jobs"
+	"flights/pkg/types"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// errorCodes machine-readable code of the errors of the server that aren't types.Error
+var errorCodes = map[error]string{
+	ErrUnauthorized:        types.ErrorCodeUnauthorized,
+	ErrForbidden:           types.ErrorCodeForbidden,
+	ErrRateLimited:         types.ErrorCodeRateLimited,
+	ErrUnsupportedEncoding: types.ErrorCodeUnsupportedEncoding,
+
+	ErrIdempotencyKeyReused:  types.ErrorCodeIdempotencyKeyReused,
+	ErrIdempotencyInProgress: types.ErrorCodeIdempotencyInProgress,
+
+	jobs.ErrJobNotFound:     types.ErrorCodeNotFound,
+	jobs.ErrQueueFull:       types.ErrorCodeQueueFull,
+	jobs.ErrManagerClosed:   types.ErrorCodeQueueFull,
+	jobs.ErrJobFinished:     types.ErrorCodeJobFinished,
+	jobs.ErrJobNotSucceeded: types.ErrorCodeJobNotSucceeded,
+}
+
+// withCode returns the error as types.Error, keeping the code when it already has one
+func withCode(err error) error {
+	var typed types.Error
+	if errors.As(err, &typed) {
+		return err
+	}
+
+	for sentinel, code := range errorCodes {
+		if errors.Is(err, sentinel) {
+			return types.NewError(code, "", err.Error())
+		}
+	}
+
+	return err
+}
+
+// acceptsProblem returns true when the client asked for problem details, RFC 7807
+func acceptsProblem(r *http.Request) bool {
+	if r == nil {
+		return false
+	}
+
+	for _, mediaRange := range strings.Split(r.Header.Get("Accept"), ",") {
+		fields := strings.Split(mediaRange, ";")
+		if strings.TrimSpace(fields[0]) != types.ContentTypeProblem {
+			continue
+		}
+
+		for _, param := range fields[1:] {
+			if strings.ReplaceAll(param, " ", "") == "q=0" {
+				return false
+			}
+		}
+		return true
+	}
+
+	return false
+}
+
+// writeRestError writes the error in the RestFul json data output pattern
+func writeRestError(w http.ResponseWriter, r *http.Request, statusCode int, err error) {
+	writeRestErrors(w, r, statusCode, err)
+}
+
+// writeRestErrors writes the list of errors in the RestFul json data output pattern, or as problem details when the
+// client sends "Accept: application/problem+json"
+func writeRestErrors(w http.ResponseWriter, r *http.Request, statusCode int, errs ...error) {
+	var rest types.RestFul
+	for _, err := range errs {
+		rest.AddError(withCode(err))
+	}
+
+	var output any = rest
+	w.Header().Set("Content-Type", "application/json")
+
+	if acceptsProblem(r) {
+		output = rest.ToProblem(statusCode, r.URL.RequestURI())
+		w.Header().Set("Content-Type", types.ContentTypeProblem)
+	}
+
+	w.WriteHeader(statusCode)
+	err := json.NewEncoder(w).Encode(output)
+	if err != nil {
+		log.Printf("writeRestErrors().json.NewEncoder(w).Encode(rest).Error: %v", err)
+	}
+}
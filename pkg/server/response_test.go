@@ -0,0 +1,65 @@
+package server
+
+import (
+	"encoding/json"
+	"flights/pkg/types"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGeneratesSubRoutesOfRoute_Errors(t *testing.T) {
+	handler := MiddlewarePost(http.HandlerFunc(GeneratesSubRoutesOfRoute))
+
+	tests := []struct {
+		name       string
+		body       string
+		accept     string
+		statusCode int
+		code       string
+		pointer    string
+	}{
+		{name: "malformed", body: `[["IND","EWR"`, statusCode: http.StatusBadRequest, code: types.ErrorCodeMalformedPayload},
+		{name: "disconnected", body: `[["IND","EWR"],["EWR","SFO"],["GRU","JFK"]]`, statusCode: http.StatusUnprocessableEntity, code: types.ErrorCodeDisconnectedChain, pointer: "/2"},
+		{name: "problem", body: `[["IND","EWR"],["EWR","SFO"],["EWR","JFK"]]`, accept: "application/problem+json", statusCode: http.StatusUnprocessableEntity, code: types.ErrorCodeFork, pointer: "/2"},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/calculate", strings.NewReader(test.body))
+		if test.accept != "" {
+			r.Header.Set("Accept", test.accept)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if w.Code != test.statusCode {
+			t.Logf("%v: status code %v, expected %v", test.name, w.Code, test.statusCode)
+			t.FailNow()
+		}
+
+		var detail types.ErrorDetail
+		if test.accept != "" {
+			var problem types.Problem
+			_ = json.Unmarshal(w.Body.Bytes(), &problem)
+			if w.Header().Get("Content-Type") != types.ContentTypeProblem || problem.Status != test.statusCode || len(problem.Errors) != 1 {
+				t.Logf("%v: problem details error: %s", test.name, w.Body.Bytes())
+				t.FailNow()
+			}
+			detail = problem.Errors[0]
+		} else {
+			var rest types.RestFul
+			_ = json.Unmarshal(w.Body.Bytes(), &rest)
+			if len(rest.Meta.Error) != 1 || len(rest.Meta.Details) != 1 {
+				t.Logf("%v: restful error: %s", test.name, w.Body.Bytes())
+				t.FailNow()
+			}
+			detail = rest.Meta.Details[0]
+		}
+
+		if detail.Code != test.code || detail.Pointer != test.pointer {
+			t.Logf("%v: expected code %v and pointer %q, found %+v", test.name, test.code, test.pointer, detail)
+			t.FailNow()
+		}
+	}
+}
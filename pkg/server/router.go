@@ -0,0 +1,238 @@
+package server
+
+import (
+	"context"
+	"flights/pkg/types"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Route method and path registered in the server
+type Route struct {
+	Method string
+	Path   string
+}
+
+// routeEntry handlers of a path, by method
+type routeEntry struct {
+	path     string
+	segments []string
+	handlers map[string]http.Handler
+}
+
+// static number of segments without parameters, used to prefer /jobs/list over /jobs/{id}
+func (e *routeEntry) static() (static int) {
+	for _, segment := range e.segments {
+		if !isParam(segment) {
+			static += 1
+		}
+	}
+	return
+}
+
+// match returns the parameters of the path when the path matches the template of the entry
+func (e *routeEntry) match(segments []string) (params map[string]string, ok bool) {
+	if len(segments) != len(e.segments) {
+		return nil, false
+	}
+
+	for k, segment := range e.segments {
+		if isParam(segment) {
+			if segments[k] == "" {
+				return nil, false
+			}
+			if params == nil {
+				params = make(map[string]string)
+			}
+			params[segment[1:len(segment)-1]] = segments[k]
+			continue
+		}
+
+		if segment != segments[k] {
+			return nil, false
+		}
+	}
+
+	return params, true
+}
+
+// allow returns the value of the Allow header of the path
+func (e *routeEntry) allow() string {
+	methods := make([]string, 0, len(e.handlers)+2)
+	for method := range e.handlers {
+		methods = append(methods, method)
+	}
+
+	if _, found := e.handlers[http.MethodGet]; found {
+		if _, found = e.handlers[http.MethodHead]; !found {
+			methods = append(methods, http.MethodHead)
+		}
+	}
+	methods = append(methods, http.MethodOptions)
+
+	sort.Strings(methods)
+	return strings.Join(methods, ", ")
+}
+
+// isParam returns true for template segments like {id}
+func isParam(segment string) bool {
+	return len(segment) > 2 && segment[0] == '{' && segment[len(segment)-1] == '}'
+}
+
+// splitPath splits the path into segments. Ex.: /v1/jobs/10 is [v1 jobs 10]
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+// pathParamsKey context key of the path parameters
+type pathParamsKey struct{}
+
+// PathParam returns the value of a path parameter. Ex.: "id" of /v1/jobs/{id}
+func PathParam(r *http.Request, name string) string {
+	params, _ := r.Context().Value(pathParamsKey{}).(map[string]string)
+	return params[name]
+}
+
+// Router method-aware router.
+// Paths can have parameters, like /v1/jobs/{id}, read with PathParam().
+// Wrong methods receive 405 with the Allow header and OPTIONS is answered automatically.
+type Router struct {
+	entries []*routeEntry
+	exact   map[string]*routeEntry
+	routes  []Route
+	handler http.Handler
+
+	// Options [optional] handler of OPTIONS requests, called with the Allow header already set.
+	// The default handler answers 204 No Content.
+	Options http.Handler
+}
+
+// NewRouter returns an empty router
+func NewRouter() *Router {
+	return &Router{
+		exact: make(map[string]*routeEntry),
+	}
+}
+
+// entry returns the entry of the path template, creating it when necessary
+func (e *Router) entry(path string) *routeEntry {
+	for _, entry := range e.entries {
+		if entry.path == path {
+			return entry
+		}
+	}
+
+	entry := &routeEntry{
+		path:     path,
+		segments: splitPath(path),
+		handlers: make(map[string]http.Handler),
+	}
+	e.entries = append(e.entries, entry)
+
+	// the most specific templates are tested first
+	sort.SliceStable(e.entries, func(i, j int) bool {
+		return e.entries[i].static() > e.entries[j].static()
+	})
+
+	if !strings.Contains(path, "{") {
+		e.exact[path] = entry
+	}
+
+	return entry
+}
+
+// Handle registers the handler of the method and path
+func (e *Router) Handle(method, path string, handler http.Handler) {
+	e.entry(path).handlers[method] = handler
+	e.routes = append(e.routes, Route{Method: method, Path: path})
+}
+
+// HandleFunc registers the handler function of the method and path
+func (e *Router) HandleFunc(method, path string, handler http.HandlerFunc) {
+	e.Handle(method, path, handler)
+}
+
+// Alias makes the alias path answer with the handlers of the path, for all methods registered so far.
+// Ex.: router.Alias("/calculate", "/v1/calculate") keeps the legacy path working
+func (e *Router) Alias(alias, path string) {
+	for method, handler := range e.entry(path).handlers {
+		e.Handle(method, alias, handler)
+	}
+}
+
+// Routes returns the registered routes
+func (e *Router) Routes() []Route {
+	return e.routes
+}
+
+// Use wraps the router with the middleware, so it runs before the routing, even for unknown paths and methods.
+// Each call wraps the middlewares added before
+func (e *Router) Use(middleware func(http.Handler) http.Handler) {
+	if e.handler == nil {
+		e.handler = http.HandlerFunc(e.dispatch)
+	}
+	e.handler = middleware(e.handler)
+}
+
+// find returns the entry and the parameters of the path
+func (e *Router) find(path string) (entry *routeEntry, params map[string]string) {
+	if entry = e.exact[path]; entry != nil {
+		return
+	}
+
+	segments := splitPath(path)
+	for _, candidate := range e.entries {
+		var ok bool
+		if params, ok = candidate.match(segments); ok {
+			return candidate, params
+		}
+	}
+
+	return nil, nil
+}
+
+// ServeHTTP runs the middlewares and dispatches the request
+func (e *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if e.handler != nil {
+		e.handler.ServeHTTP(w, r)
+		return
+	}
+	e.dispatch(w, r)
+}
+
+// dispatch dispatches the request by path and method
+func (e *Router) dispatch(w http.ResponseWriter, r *http.Request) {
+	entry, params := e.find(r.URL.Path)
+	if entry == nil {
+		writeRestError(w, r, http.StatusNotFound, types.NewError(types.ErrorCodeNotFound, "", "Page not found"))
+		return
+	}
+
+	if params != nil {
+		r = r.WithContext(context.WithValue(r.Context(), pathParamsKey{}, params))
+	}
+
+	handler, found := entry.handlers[r.Method]
+	if !found && r.Method == http.MethodHead {
+		handler, found = entry.handlers[http.MethodGet]
+	}
+
+	if found {
+		handler.ServeHTTP(w, r)
+		return
+	}
+
+	w.Header().Set("Allow", entry.allow())
+
+	if r.Method == http.MethodOptions {
+		if e.Options != nil {
+			e.Options.ServeHTTP(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	writeRestError(w, r, http.StatusMethodNotAllowed, types.NewError(types.ErrorCodeMethodNotAllowed, "", "Method not allowed"))
+}
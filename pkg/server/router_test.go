@@ -0,0 +1,65 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouter_ServeHTTP(t *testing.T) {
+	router := NewRouter()
+
+	write := func(text string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(text + PathParam(r, "id")))
+		}
+	}
+
+	router.HandleFunc(http.MethodPost, "/v1/calculate", write("calculate"))
+	router.HandleFunc(http.MethodPost, "/v1/jobs", write("create"))
+	router.HandleFunc(http.MethodGet, "/v1/jobs/{id}", write("job "))
+	router.HandleFunc(http.MethodDelete, "/v1/jobs/{id}", write("cancel "))
+	router.HandleFunc(http.MethodGet, "/v1/jobs/statistics", write("statistics"))
+	router.Alias("/calculate", "/v1/calculate")
+
+	tests := []struct {
+		method     string
+		path       string
+		statusCode int
+		body       string
+		allow      string
+	}{
+		{method: http.MethodPost, path: "/v1/calculate", statusCode: http.StatusOK, body: "calculate"},
+		{method: http.MethodPost, path: "/calculate", statusCode: http.StatusOK, body: "calculate"},
+		{method: http.MethodGet, path: "/calculate", statusCode: http.StatusMethodNotAllowed, allow: "OPTIONS, POST"},
+		{method: http.MethodOptions, path: "/v1/calculate", statusCode: http.StatusNoContent, allow: "OPTIONS, POST"},
+		{method: http.MethodGet, path: "/v1/jobs/10", statusCode: http.StatusOK, body: "job 10"},
+		{method: http.MethodHead, path: "/v1/jobs/10", statusCode: http.StatusOK, body: "job 10"},
+		{method: http.MethodDelete, path: "/v1/jobs/10", statusCode: http.StatusOK, body: "cancel 10"},
+		{method: http.MethodPut, path: "/v1/jobs/10", statusCode: http.StatusMethodNotAllowed, allow: "DELETE, GET, HEAD, OPTIONS"},
+		{method: http.MethodGet, path: "/v1/jobs/statistics", statusCode: http.StatusOK, body: "statistics"},
+		{method: http.MethodGet, path: "/v1/jobs/10/result", statusCode: http.StatusNotFound},
+		{method: http.MethodGet, path: "/v2/calculate", statusCode: http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(test.method, test.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		if w.Code != test.statusCode {
+			t.Logf("%v %v: status code %v, expected %v", test.method, test.path, w.Code, test.statusCode)
+			t.FailNow()
+		}
+
+		if test.body != "" && w.Body.String() != test.body {
+			t.Logf("%v %v: body %q, expected %q", test.method, test.path, w.Body.String(), test.body)
+			t.FailNow()
+		}
+
+		if w.Header().Get("Allow") != test.allow {
+			t.Logf("%v %v: Allow %q, expected %q", test.method, test.path, w.Header().Get("Allow"), test.allow)
+			t.FailNow()
+		}
+	}
+}
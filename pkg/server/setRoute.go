@@ -2,6 +2,8 @@ package server
 
 import (
 	"encoding/json"
+	"flights/pkg/cache"
+	"flights/pkg/export"
 	"flights/pkg/types"
 	"io"
 	"log"
@@ -11,25 +13,100 @@ import (
 // GeneratesSubRoutesOfRoute this endpoint generates subroutes from a main route
 // Entrada: POST [["IND", "EWR"], ["SFO", "ATL"], ["GSO", "IND"], ["ATL", "GSO"]]
 func GeneratesSubRoutesOfRoute(w http.ResponseWriter, r *http.Request) {
+	SubRoutesHandler{}.ServeHTTP(w, r)
+}
+
+// readFlights reads the flight list of the request body, without validating the chain
+func readFlights(r *http.Request) (flights types.Flights, err error) {
+	flights = types.Flights{}
+	data, err := io.ReadAll(r.Body)
+	if err == nil {
+		err = json.Unmarshal(data, &flights)
+	}
+	if err != nil {
+		return nil, types.NewError(types.ErrorCodeMalformedPayload, "", err.Error())
+	}
+	return
+}
+
+// decodeFlights reads and validates the flight list of the request body.
+// Returns the status code of the error: 400 for malformed json and 422 for a list that isn't a single chain
+func decodeFlights(r *http.Request) (flights types.Flights, statusCode int, err error) {
+	if flights, err = readFlights(r); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	// a disconnected chain can't be sorted
+	if err = flights.Validate(); err != nil {
+		return nil, http.StatusUnprocessableEntity, err
+	}
+
+	return flights, http.StatusOK, nil
+}
+
+// SubRoutesHandler this endpoint generates subroutes from a main route, using the memoization cache when informed
+type SubRoutesHandler struct {
+	// Cache [optional] memoization cache of the sub routes
+	Cache *cache.SubRoutes
+
+	// Airports [optional] coordinates of the airports of the geojson and svg formats. Default export.DefaultAirports()
+	Airports export.Airports
+}
+
+// ServeHTTP generates the subroutes, or exports the flights when the query has ?format=dot, geojson or svg
+// Entrada: POST [["IND", "EWR"], ["SFO", "ATL"], ["GSO", "IND"], ["ATL", "GSO"]]
+func (e SubRoutesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
 
 	var rest types.RestFul
 
-	flights := types.Flights{}
-	data, err := io.ReadAll(r.Body)
-	err = json.Unmarshal(data, &flights)
+	format, err := queryFormat(r)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		rest.AddError(err)
-		err = json.NewEncoder(w).Encode(rest)
-		if err != nil {
-			log.Printf("setFlight().json.NewEncoder(w).Encode(rest).Error: %v", err)
-		}
+		writeRestError(w, r, http.StatusBadRequest, err)
 		return
 	}
 
-	rest.Success(flights.GetSubRoutes())
+	if format != FormatJSON {
+		e.export(w, r, format)
+		return
+	}
+
+	flights, statusCode, err := decodeFlights(r)
+	if err != nil {
+		writeRestError(w, r, statusCode, err)
+		return
+	}
+
+	if e.Cache != nil {
+		rest.Success(e.Cache.GetSubRoutes(flights))
+	} else {
+		rest.Success(flights.GetSubRoutes())
+	}
+
 	err = json.NewEncoder(w).Encode(rest)
 	if err != nil {
 		log.Printf("setFlight().json.NewEncoder(w).Encode(rest).Error: %v", err)
 	}
 }
+
+// export writes the flights in the export format. Flights with forks or disconnected fragments are exported, to
+// show why they can't be sorted
+func (e SubRoutesHandler) export(w http.ResponseWriter, r *http.Request, format string) {
+	flights, err := readFlights(r)
+	if err != nil {
+		writeRestError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err = flights.Validate(); !exportable(err) {
+		writeRestError(w, r, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	airports := e.Airports
+	if airports == nil {
+		airports = export.DefaultAirports()
+	}
+
+	writeExport(w, format, flights, airports)
+}
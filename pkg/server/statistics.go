@@ -0,0 +1,23 @@
+package server
+
+import (
+	"encoding/json"
+	"flights/pkg/cache"
+	"flights/pkg/types"
+	"log"
+	"net/http"
+)
+
+// CacheStatistics this endpoint returns the hit and miss counters of the memoization cache
+func CacheStatistics(subRoutesCache *cache.SubRoutes) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var rest types.RestFul
+
+		w.Header().Set("Content-Type", "application/json")
+		rest.Success(subRoutesCache.Statistics())
+		err := json.NewEncoder(w).Encode(rest)
+		if err != nil {
+			log.Printf("CacheStatistics().json.NewEncoder(w).Encode(rest).Error: %v", err)
+		}
+	}
+}
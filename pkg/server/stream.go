@@ -0,0 +1,260 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flights/pkg/types"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+const (
+	// StreamEventRoutes event with a batch of sub routes
+	StreamEventRoutes = "routes"
+
+	// StreamEventDone last event, sent after all sub routes
+	StreamEventDone = "done"
+
+	// StreamEventError event with the error of the itinerary, only sent by the websocket
+	StreamEventError = "error"
+)
+
+// maxStreamBatch maximum number of sub routes per event
+const maxStreamBatch = 1000
+
+// StreamEvent message sent by the websocket. The server-sent events use the same names and data
+type StreamEvent struct {
+	Event string `json:"event"`
+	Data  any    `json:"data"`
+}
+
+// StreamDone data of the done event
+type StreamDone struct {
+	// Count number of sub routes sent
+	Count int64 `json:"count"`
+}
+
+// StreamHandler endpoints that push the sub routes as they are generated, by server-sent events or websocket.
+// The generation runs in the goroutine that writes to the client, so a slow client pauses the generation instead of
+// accumulating sub routes in memory, and a client that disconnects stops it.
+type StreamHandler struct {
+	// WriteTimeout [optional] maximum time to send one websocket message. Default 30s
+	WriteTimeout time.Duration
+
+	// ReadTimeout [optional] maximum time to receive the itinerary by websocket. Default 10s
+	ReadTimeout time.Duration
+
+	// CheckOrigin [optional] validates the Origin header of the websocket handshake. Ex.: CORS.AllowOrigin.
+	// Default only the origin of the server host, because browsers don't apply the same-origin policy to websockets
+	CheckOrigin func(origin string) bool
+}
+
+// streamBatches sends the sub routes in batches until the end of the itinerary, the context is cancelled or send
+// returns an error
+func streamBatches(ctx context.Context, flights types.Flights, batch int, send func(routes [][][]string) error) (count int64, err error) {
+	routes := make([][][]string, 0, batch)
+	flights.WalkSubRoutes(func(route [][]string) bool {
+		if err = ctx.Err(); err != nil {
+			return false
+		}
+
+		routes = append(routes, route)
+		if len(routes) < batch {
+			return true
+		}
+
+		if err = send(routes); err != nil {
+			return false
+		}
+		count += int64(len(routes))
+		routes = routes[:0]
+		return true
+	})
+
+	if err == nil && len(routes) != 0 {
+		if err = send(routes); err == nil {
+			count += int64(len(routes))
+		}
+	}
+
+	return
+}
+
+// Events this endpoint sends the sub routes as server-sent events, one event per batch of sub routes.
+// Query: batch, number of sub routes per event, default 1.
+// Entrada: POST [["IND", "EWR"], ["SFO", "ATL"], ["GSO", "IND"], ["ATL", "GSO"]]
+func (e StreamHandler) Events(w http.ResponseWriter, r *http.Request) {
+	batch, err := queryInt(r, "batch", 1, 1, maxStreamBatch)
+	if err != nil {
+		writeRestError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	flights, statusCode, err := decodeFlights(r)
+	if err != nil {
+		writeRestError(w, r, statusCode, err)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeRestError(w, r, http.StatusInternalServerError, fmt.Errorf("the connection doesn't support streaming"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	var buffer bytes.Buffer
+	var id int64
+	write := func(event string, data any) (err error) {
+		buffer.Reset()
+		fmt.Fprintf(&buffer, "id: %v\nevent: %v\ndata: ", id, event)
+		if err = json.NewEncoder(&buffer).Encode(data); err != nil {
+			return
+		}
+		buffer.WriteByte('\n')
+
+		if _, err = w.Write(buffer.Bytes()); err != nil {
+			return
+		}
+		flusher.Flush()
+		return
+	}
+
+	count, err := streamBatches(r.Context(), flights, int(batch), func(routes [][][]string) error {
+		err := write(StreamEventRoutes, routes)
+		id += int64(len(routes))
+		return err
+	})
+	if err != nil {
+		// the client disconnected
+		return
+	}
+
+	if err = write(StreamEventDone, StreamDone{Count: count}); err != nil {
+		log.Printf("StreamHandler.Events().write().Error: %v", err)
+	}
+}
+
+// WebSocket this endpoint receives the itinerary as the first message of the websocket and sends the sub routes as
+// StreamEvent messages, one per batch of sub routes, followed by the done event.
+// Query: batch, number of sub routes per event, default 1.
+func (e StreamHandler) WebSocket(w http.ResponseWriter, r *http.Request) {
+	batch, err := queryInt(r, "batch", 1, 1, maxStreamBatch)
+	if err != nil {
+		writeRestError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if e.WriteTimeout == 0 {
+		e.WriteTimeout = 30 * time.Second
+	}
+
+	if e.ReadTimeout == 0 {
+		e.ReadTimeout = 10 * time.Second
+	}
+
+	server := websocket.Server{
+		Handshake: func(config *websocket.Config, r *http.Request) error {
+			// clients that aren't browsers don't send the origin
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				return nil
+			}
+
+			allowed := sameOrigin(origin, r.Host)
+			if e.CheckOrigin != nil {
+				allowed = e.CheckOrigin(origin)
+			}
+
+			if !allowed {
+				return fmt.Errorf("%w: origin not allowed", ErrForbidden)
+			}
+			return nil
+		},
+		Handler: func(ws *websocket.Conn) {
+			defer ws.Close()
+			e.serveWebSocket(ws, int(batch))
+		},
+	}
+
+	server.ServeHTTP(w, r)
+}
+
+// sameOrigin returns true when the host of the origin is the host of the request
+func sameOrigin(origin, host string) bool {
+	originUrl, err := url.Parse(origin)
+	return err == nil && originUrl.Host != "" && strings.EqualFold(originUrl.Host, host)
+}
+
+// serveWebSocket reads the itinerary and sends the sub routes
+func (e StreamHandler) serveWebSocket(ws *websocket.Conn, batch int) {
+	send := func(event string, data any) error {
+		_ = ws.SetWriteDeadline(time.Now().Add(e.WriteTimeout))
+		return websocket.JSON.Send(ws, StreamEvent{Event: event, Data: data})
+	}
+
+	sendError := func(err error) {
+		var rest types.RestFul
+		rest.AddError(err)
+		_ = send(StreamEventError, rest.Meta.Details[0])
+	}
+
+	_ = ws.SetReadDeadline(time.Now().Add(e.ReadTimeout))
+	var data []byte
+	if err := websocket.Message.Receive(ws, &data); err != nil {
+		return
+	}
+	_ = ws.SetReadDeadline(time.Time{})
+
+	flights := types.Flights{}
+	if err := json.Unmarshal(data, &flights); err != nil {
+		sendError(types.NewError(types.ErrorCodeMalformedPayload, "", err.Error()))
+		return
+	}
+
+	if err := flights.Validate(); err != nil {
+		sendError(err)
+		return
+	}
+
+	// the client doesn't send other messages, so a read error means the client closed the connection
+	ctx, cancel := context.WithCancel(ws.Request().Context())
+	defer cancel()
+	go func() {
+		defer cancel()
+		var ignored []byte
+		for {
+			if err := websocket.Message.Receive(ws, &ignored); err != nil {
+				return
+			}
+		}
+	}()
+
+	count, err := streamBatches(ctx, flights, batch, func(routes [][][]string) error {
+		return send(StreamEventRoutes, routes)
+	})
+	if err != nil {
+		if !errors.Is(err, context.Canceled) && !errors.Is(err, io.EOF) {
+			log.Printf("StreamHandler.WebSocket().send().Error: %v", err)
+		}
+		return
+	}
+
+	if err = send(StreamEventDone, StreamDone{Count: count}); err != nil {
+		log.Printf("StreamHandler.WebSocket().send().Error: %v", err)
+	}
+}
@@ -0,0 +1,192 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flights/pkg/types"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/websocket"
+)
+
+const streamFlights = `[["IND","EWR"],["SFO","ATL"],["GSO","IND"],["ATL","GSO"]]`
+
+func TestStreamHandler_Events(t *testing.T) {
+	mux, err := NewMux(Config{})
+	if err != nil {
+		t.Logf("NewMux().error: %v", err)
+		t.FailNow()
+	}
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	response, err := http.Post(server.URL+"/v1/calculate/events?batch=3", "application/json", strings.NewReader(streamFlights))
+	if err != nil {
+		t.Logf("http.Post().error: %v", err)
+		t.FailNow()
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK || response.Header.Get("Content-Type") != "text/event-stream" {
+		t.Logf("invalid response. status code: %v, content type: %v", response.StatusCode, response.Header.Get("Content-Type"))
+		t.FailNow()
+	}
+
+	routes := make([][][]string, 0)
+	events := make([]string, 0)
+	var done StreamDone
+
+	scanner := bufio.NewScanner(response.Body)
+	var event string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+			events = append(events, event)
+		case strings.HasPrefix(line, "data: ") && event == StreamEventRoutes:
+			var batch [][][]string
+			_ = json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &batch)
+			routes = append(routes, batch...)
+		case strings.HasPrefix(line, "data: ") && event == StreamEventDone:
+			_ = json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &done)
+		}
+	}
+
+	flights := types.Flights{{"IND", "EWR"}, {"SFO", "ATL"}, {"GSO", "IND"}, {"ATL", "GSO"}}
+	if !reflect.DeepEqual(routes, flights.GetSubRoutes()) || done.Count != 10 {
+		t.Logf("the events must have all sub routes. routes: %v, done: %+v", routes, done)
+		t.FailNow()
+	}
+
+	// 10 sub routes in batches of 3 are 4 events, plus the done event
+	if strings.Join(events, ",") != "routes,routes,routes,routes,done" {
+		t.Logf("invalid events: %v", events)
+		t.FailNow()
+	}
+
+	// errors of the itinerary are sent before the stream starts
+	response, err = http.Post(server.URL+"/v1/calculate/events", "application/json", strings.NewReader(`[["IND","EWR"],["EWR","SFO"],["GRU","JFK"]]`))
+	if err != nil || response.StatusCode != http.StatusUnprocessableEntity {
+		t.Logf("a disconnected chain must return 422. error: %v", err)
+		t.FailNow()
+	}
+	_ = response.Body.Close()
+}
+
+func TestStreamHandler_WebSocket(t *testing.T) {
+	mux, err := NewMux(Config{})
+	if err != nil {
+		t.Logf("NewMux().error: %v", err)
+		t.FailNow()
+	}
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/v1/calculate/ws?batch=4"
+
+	tests := []struct {
+		flights string
+		events  string
+		count   int
+	}{
+		{flights: streamFlights, events: "routes,routes,routes,done", count: 10},
+		{flights: `[["IND","EWR"],["EWR","SFO"],["GRU","JFK"]]`, events: "error"},
+		{flights: `[["IND"`, events: "error"},
+	}
+
+	for _, test := range tests {
+		ws, err := websocket.Dial(url, "", server.URL)
+		if err != nil {
+			t.Logf("websocket.Dial().error: %v", err)
+			t.FailNow()
+		}
+
+		if err = websocket.Message.Send(ws, test.flights); err != nil {
+			t.Logf("websocket.Message.Send().error: %v", err)
+			t.FailNow()
+		}
+
+		events := make([]string, 0)
+		count := 0
+		for {
+			var event struct {
+				Event string          `json:"event"`
+				Data  json.RawMessage `json:"data"`
+			}
+			if err = websocket.JSON.Receive(ws, &event); err != nil {
+				break
+			}
+			events = append(events, event.Event)
+
+			if event.Event == StreamEventRoutes {
+				var batch [][][]string
+				_ = json.Unmarshal(event.Data, &batch)
+				count += len(batch)
+			}
+		}
+		_ = ws.Close()
+
+		if strings.Join(events, ",") != test.events || count != test.count {
+			t.Logf("%v: invalid events: %v, sub routes: %v", test.flights, events, count)
+			t.FailNow()
+		}
+	}
+}
+
+func TestStreamHandler_WebSocketOrigin(t *testing.T) {
+	mux, err := NewMux(Config{})
+	if err != nil {
+		t.Logf("NewMux().error: %v", err)
+		t.FailNow()
+	}
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/v1/calculate/ws"
+
+	// without cors only the origin of the server is accepted
+	if _, err = websocket.Dial(url, "", "https://attacker.example.org"); err == nil {
+		t.Log("the handshake of a foreign origin must be rejected")
+		t.FailNow()
+	}
+
+	ws, err := websocket.Dial(url, "", server.URL)
+	if err != nil {
+		t.Logf("the handshake of the same origin must be accepted: %v", err)
+		t.FailNow()
+	}
+	_ = ws.Close()
+}
+
+func TestStreamBatches_Cancel(t *testing.T) {
+	flights := make(types.Flights, 200)
+	for k := range flights {
+		flights[k] = []string{fmt.Sprintf("A%03d", k), fmt.Sprintf("A%03d", k+1)}
+	}
+
+	// the client disconnects after the second batch
+	ctx, cancel := context.WithCancel(context.Background())
+	batches := 0
+	count, err := streamBatches(ctx, flights, 10, func(routes [][][]string) error {
+		batches += 1
+		if batches == 2 {
+			cancel()
+		}
+		return nil
+	})
+
+	if err != context.Canceled || count != 20 || batches != 2 {
+		t.Logf("the generation must stop when the client disconnects. error: %v, count: %v, batches: %v", err, count, batches)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,274 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// TLSConfig files used to enable tls and mutual tls on the server
+type TLSConfig struct {
+	// CertFile pem file with the server certificate chain
+	CertFile string
+
+	// KeyFile pem file with the server private key
+	KeyFile string
+
+	// ClientCAFile [optional] pem bundle with the CAs used to verify client certificates. When set, the server requires
+	// a valid client certificate (mTLS)
+	ClientCAFile string
+}
+
+// Enabled returns true when the certificate and the key are informed, and an error when only one of them is, or when
+// the client CA is informed without them, so a misconfigured server doesn't start on plain http
+func (e TLSConfig) Enabled() (bool, error) {
+	if e.CertFile == "" && e.KeyFile == "" {
+		if e.ClientCAFile != "" {
+			return false, fmt.Errorf("the client CA file requires the certificate and the key files")
+		}
+		return false, nil
+	}
+
+	if e.CertFile == "" || e.KeyFile == "" {
+		return false, fmt.Errorf("tls requires both the certificate and the key files")
+	}
+	return true, nil
+}
+
+// fileVersion identifies a version of a file on disk, used to detect changes without reading the file
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+// getFileVersion returns the current version of a file on disk
+func getFileVersion(path string) (version fileVersion, err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+
+	version.modTime = info.ModTime()
+	version.size = info.Size()
+	return
+}
+
+// CertificateReloader keeps the server certificate in memory and reloads it when the files change on disk, so the
+// certificate can be renewed without restarting the server
+type CertificateReloader struct {
+	certFile string
+	keyFile  string
+
+	mutex       sync.RWMutex
+	certificate *tls.Certificate
+	certVersion fileVersion
+	keyVersion  fileVersion
+}
+
+// NewCertificateReloader loads the certificate and returns a reloader ready to be used in tls.Config.GetCertificate
+func NewCertificateReloader(certFile, keyFile string) (reloader *CertificateReloader, err error) {
+	reloader = &CertificateReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+
+	err = reloader.reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+// reload reads the certificate files from disk
+func (e *CertificateReloader) reload() (err error) {
+	certVersion, err := getFileVersion(e.certFile)
+	if err != nil {
+		return
+	}
+
+	keyVersion, err := getFileVersion(e.keyFile)
+	if err != nil {
+		return
+	}
+
+	certificate, err := tls.LoadX509KeyPair(e.certFile, e.keyFile)
+	if err != nil {
+		return
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.certificate = &certificate
+	e.certVersion = certVersion
+	e.keyVersion = keyVersion
+	return
+}
+
+// changed returns true when the certificate or the key file changed on disk since the last load
+func (e *CertificateReloader) changed() bool {
+	certVersion, err := getFileVersion(e.certFile)
+	if err != nil {
+		return false
+	}
+
+	keyVersion, err := getFileVersion(e.keyFile)
+	if err != nil {
+		return false
+	}
+
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	return certVersion != e.certVersion || keyVersion != e.keyVersion
+}
+
+// GetCertificate returns the current certificate, reloading it first when the files changed on disk.
+// If the new files are invalid, for example, when only one of them was written, the previous certificate is kept.
+func (e *CertificateReloader) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if e.changed() {
+		_ = e.reload()
+	}
+
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	return e.certificate, nil
+}
+
+// clientCAReloader keeps the client CA bundle in memory and reloads it when the file changes on disk
+type clientCAReloader struct {
+	caFile string
+
+	mutex   sync.RWMutex
+	pool    *x509.CertPool
+	version fileVersion
+}
+
+// reload reads the CA bundle from disk
+func (e *clientCAReloader) reload() (err error) {
+	version, err := getFileVersion(e.caFile)
+	if err != nil {
+		return
+	}
+
+	data, err := os.ReadFile(e.caFile)
+	if err != nil {
+		return
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return fmt.Errorf("no certificate found in the client CA file %v", e.caFile)
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.pool = pool
+	e.version = version
+	return
+}
+
+// getPool returns the current CA pool, reloading it first when the file changed on disk
+func (e *clientCAReloader) getPool() *x509.CertPool {
+	version, err := getFileVersion(e.caFile)
+
+	e.mutex.RLock()
+	changed := err == nil && version != e.version
+	e.mutex.RUnlock()
+
+	if changed {
+		_ = e.reload()
+	}
+
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	return e.pool
+}
+
+// NewTLSConfig returns the tls configuration of the server.
+// Certificates and the client CA bundle are reloaded when they change on disk.
+func NewTLSConfig(config TLSConfig) (tlsConfig *tls.Config, err error) {
+	reloader, err := NewCertificateReloader(config.CertFile, config.KeyFile)
+	if err != nil {
+		return
+	}
+
+	tlsConfig = &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	if config.ClientCAFile == "" {
+		return
+	}
+
+	caReloader := &clientCAReloader{caFile: config.ClientCAFile}
+	err = caReloader.reload()
+	if err != nil {
+		return nil, err
+	}
+
+	// the CA pool is read on every handshake, so a new bundle has effect without restarting the server. The config of
+	// the handshake is a copy of the base config, so it keeps the NextProtos (http2) set by http.Server, the cipher
+	// suites and the curves
+	base := tlsConfig
+	tlsConfig.GetConfigForClient = func(_ *tls.ClientHelloInfo) (*tls.Config, error) {
+		config := base.Clone()
+		config.GetConfigForClient = nil
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+		config.ClientCAs = caReloader.getPool()
+		return config, nil
+	}
+
+	return
+}
+
+// ClientIdentity verified identity of a client authenticated by certificate (mTLS)
+type ClientIdentity struct {
+	CommonName   string   `json:"commonName"`
+	Organization []string `json:"organization"`
+	SerialNumber string   `json:"serialNumber"`
+	DNSNames     []string `json:"dnsNames"`
+	Emails       []string `json:"emails"`
+}
+
+// clientIdentityKey context key of the client identity
+type clientIdentityKey struct{}
+
+// MiddlewareClientIdentity puts the identity of the verified client certificate in the request context.
+// Use ClientIdentityFromContext() to read it inside the handler.
+func MiddlewareClientIdentity(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		certificate := r.TLS.VerifiedChains[0][0]
+		identity := ClientIdentity{
+			CommonName:   certificate.Subject.CommonName,
+			Organization: certificate.Subject.Organization,
+			SerialNumber: certificate.SerialNumber.String(),
+			DNSNames:     certificate.DNSNames,
+			Emails:       certificate.EmailAddresses,
+		}
+
+		ctx := context.WithValue(r.Context(), clientIdentityKey{}, identity)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// ClientIdentityFromContext returns the verified client identity, if the client was authenticated by certificate
+func ClientIdentityFromContext(ctx context.Context) (identity ClientIdentity, ok bool) {
+	identity, ok = ctx.Value(clientIdentityKey{}).(ClientIdentity)
+	return
+}
@@ -0,0 +1,249 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCertificate struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+	certPem     []byte
+	keyPem      []byte
+}
+
+// newTestCertificate generates a certificate signed by parent, or self-signed when parent is nil
+func newTestCertificate(t *testing.T, serial int64, commonName string, isCA bool, parent *testCertificate) testCertificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Logf("ecdsa.GenerateKey().error: %v", err)
+		t.FailNow()
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: commonName, Organization: []string{"flights"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.certificate, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Logf("x509.CreateCertificate().error: %v", err)
+		t.FailNow()
+	}
+
+	certificate, _ := x509.ParseCertificate(der)
+	keyDer, _ := x509.MarshalECPrivateKey(key)
+
+	return testCertificate{
+		certificate: certificate,
+		key:         key,
+		certPem:     pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPem:      pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}),
+	}
+}
+
+func writeTestFile(t *testing.T, path string, data []byte, modTime time.Time) {
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Logf("os.WriteFile().error: %v", err)
+		t.FailNow()
+	}
+
+	// guarantees a new modification time even on file systems with low time resolution
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Logf("os.Chtimes().error: %v", err)
+		t.FailNow()
+	}
+}
+
+func newTestTLSServer(t *testing.T, handler http.Handler) (server *httptest.Server, ca, client testCertificate, dir string) {
+	dir = t.TempDir()
+
+	ca = newTestCertificate(t, 1, "flights test ca", true, nil)
+	serverCertificate := newTestCertificate(t, 2, "localhost", false, &ca)
+	client = newTestCertificate(t, 3, "partner-a", false, &ca)
+
+	now := time.Now()
+	writeTestFile(t, filepath.Join(dir, "ca.pem"), ca.certPem, now)
+	writeTestFile(t, filepath.Join(dir, "cert.pem"), serverCertificate.certPem, now)
+	writeTestFile(t, filepath.Join(dir, "key.pem"), serverCertificate.keyPem, now)
+
+	tlsConfig, err := NewTLSConfig(TLSConfig{
+		CertFile:     filepath.Join(dir, "cert.pem"),
+		KeyFile:      filepath.Join(dir, "key.pem"),
+		ClientCAFile: filepath.Join(dir, "ca.pem"),
+	})
+	if err != nil {
+		t.Logf("NewTLSConfig().error: %v", err)
+		t.FailNow()
+	}
+
+	server = httptest.NewUnstartedServer(MiddlewareClientIdentity(handler))
+	server.TLS = tlsConfig
+	server.StartTLS()
+	return
+}
+
+func newTestClient(ca testCertificate, client *testCertificate) *http.Client {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.certificate)
+
+	tlsConfig := &tls.Config{RootCAs: pool}
+	if client != nil {
+		certificate, _ := tls.X509KeyPair(client.certPem, client.keyPem)
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+}
+
+func TestNewTLSConfig_ClientIdentity(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, ok := ClientIdentityFromContext(r.Context())
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(identity.CommonName))
+	})
+
+	server, ca, client, _ := newTestTLSServer(t, handler)
+	defer server.Close()
+
+	resp, err := newTestClient(ca, &client).Get(server.URL)
+	if err != nil {
+		t.Logf("client.Get().error: %v", err)
+		t.FailNow()
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "partner-a" {
+		t.Logf("client identity error: %s", body)
+		t.FailNow()
+	}
+
+	// a client without certificate must be rejected during the handshake
+	_, err = newTestClient(ca, nil).Get(server.URL)
+	if err == nil {
+		t.Log("client without certificate was accepted")
+		t.FailNow()
+	}
+}
+
+func TestNewTLSConfig_Reload(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	server, ca, client, dir := newTestTLSServer(t, handler)
+	defer server.Close()
+
+	getServerSerial := func() int64 {
+		httpClient := newTestClient(ca, &client)
+		httpClient.Transport.(*http.Transport).DisableKeepAlives = true
+
+		resp, err := httpClient.Get(server.URL)
+		if err != nil {
+			t.Logf("client.Get().error: %v", err)
+			t.FailNow()
+		}
+		defer resp.Body.Close()
+
+		return resp.TLS.PeerCertificates[0].SerialNumber.Int64()
+	}
+
+	if serial := getServerSerial(); serial != 2 {
+		t.Logf("server certificate error. serial: %v", serial)
+		t.FailNow()
+	}
+
+	renewed := newTestCertificate(t, 4, "localhost", false, &ca)
+	modTime := time.Now().Add(time.Minute)
+	writeTestFile(t, filepath.Join(dir, "cert.pem"), renewed.certPem, modTime)
+	writeTestFile(t, filepath.Join(dir, "key.pem"), renewed.keyPem, modTime)
+
+	if serial := getServerSerial(); serial != 4 {
+		t.Logf("the server certificate was not reloaded. serial: %v", serial)
+		t.FailNow()
+	}
+}
+
+func TestNewTLSConfig_GetConfigForClient(t *testing.T) {
+	server, _, _, dir := newTestTLSServer(t, http.NotFoundHandler())
+	server.Close()
+
+	tlsConfig, err := NewTLSConfig(TLSConfig{
+		CertFile:     filepath.Join(dir, "cert.pem"),
+		KeyFile:      filepath.Join(dir, "key.pem"),
+		ClientCAFile: filepath.Join(dir, "ca.pem"),
+	})
+	if err != nil {
+		t.Logf("NewTLSConfig().error: %v", err)
+		t.FailNow()
+	}
+
+	// http.Server adds h2 to the base config before serving
+	tlsConfig.NextProtos = []string{"h2", "http/1.1"}
+	tlsConfig.CipherSuites = []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}
+	tlsConfig.CurvePreferences = []tls.CurveID{tls.X25519}
+
+	config, err := tlsConfig.GetConfigForClient(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Logf("GetConfigForClient().error: %v", err)
+		t.FailNow()
+	}
+
+	if len(config.NextProtos) != 2 || config.NextProtos[0] != "h2" || len(config.CipherSuites) != 1 || len(config.CurvePreferences) != 1 ||
+		config.MinVersion != tls.VersionTLS12 || config.ClientAuth != tls.RequireAndVerifyClientCert || config.ClientCAs == nil || config.GetConfigForClient != nil {
+		t.Logf("GetConfigForClient() must keep the base config: %+v", config)
+		t.FailNow()
+	}
+}
+
+func TestTLSConfig_Enabled(t *testing.T) {
+	tests := []struct {
+		config  TLSConfig
+		enabled bool
+		err     bool
+	}{
+		{config: TLSConfig{}},
+		{config: TLSConfig{CertFile: "cert.pem", KeyFile: "key.pem"}, enabled: true},
+		{config: TLSConfig{CertFile: "cert.pem", KeyFile: "key.pem", ClientCAFile: "ca.pem"}, enabled: true},
+		{config: TLSConfig{CertFile: "cert.pem"}, err: true},
+		{config: TLSConfig{KeyFile: "key.pem"}, err: true},
+		{config: TLSConfig{ClientCAFile: "ca.pem"}, err: true},
+	}
+
+	for _, test := range tests {
+		enabled, err := test.config.Enabled()
+		if enabled != test.enabled || (err != nil) != test.err {
+			t.Logf("%+v: enabled %v, error: %v", test.config, enabled, err)
+			t.FailNow()
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package types
+
+// machine-readable error codes, sent in Meta.Details and in the problem details
+const (
+	// ErrorCodeInternal unexpected error
+	ErrorCodeInternal = "internal"
+
+	// ErrorCodeMalformedPayload the payload is not a valid json
+	ErrorCodeMalformedPayload = "malformed_payload"
+
+	// ErrorCodeInvalidPayload the payload doesn't match the schema of the endpoint
+	ErrorCodeInvalidPayload = "invalid_payload"
+
+	// ErrorCodeEmptyItinerary the flight list is empty
+	ErrorCodeEmptyItinerary = "empty_itinerary"
+
+	// ErrorCodeInvalidFlight the flight is not in the [src, dst] format
+	ErrorCodeInvalidFlight = "invalid_flight"
+
+	// ErrorCodeFork more than one flight leaves or arrives at the same airport
+	ErrorCodeFork = "fork"
+
+	// ErrorCodeDisconnectedChain the flights don't form a single chain
+	ErrorCodeDisconnectedChain = "disconnected_chain"
+
+	// ErrorCodeMethodNotAllowed the endpoint doesn't accept the method
+	ErrorCodeMethodNotAllowed = "method_not_allowed"
+
+	// ErrorCodeNotFound the endpoint doesn't exist
+	ErrorCodeNotFound = "not_found"
+
+	// ErrorCodeUnauthorized missing or invalid credentials
+	ErrorCodeUnauthorized = "unauthorized"
+
+	// ErrorCodeForbidden the client doesn't have the scope required by the endpoint
+	ErrorCodeForbidden = "forbidden"
+
+	// ErrorCodeRateLimited the client exceeded the rate limit
+	ErrorCodeRateLimited = "rate_limited"
+
+	// ErrorCodeUnsupportedEncoding the request body uses an unknown content encoding
+	ErrorCodeUnsupportedEncoding = "unsupported_encoding"
+
+	// ErrorCodeInvalidParameter a query or path parameter is invalid
+	ErrorCodeInvalidParameter = "invalid_parameter"
+
+	// ErrorCodeQueueFull the job queue is full, the client must try again later
+	ErrorCodeQueueFull = "queue_full"
+
+	// ErrorCodeJobFinished the job already finished and can't be cancelled
+	ErrorCodeJobFinished = "job_finished"
+
+	// ErrorCodeIdempotencyKeyReused the idempotency key was already used with a different payload
+	ErrorCodeIdempotencyKeyReused = "idempotency_key_reused"
+
+	// ErrorCodeIdempotencyInProgress the first request with the idempotency key is still running
+	ErrorCodeIdempotencyInProgress = "idempotency_in_progress"
+
+	// ErrorCodeJobNotSucceeded the job is still running, failed or was cancelled, so it doesn't have a result
+	ErrorCodeJobNotSucceeded = "job_not_succeeded"
+)
+
+// Error error with a machine-readable code and a json pointer to the invalid value of the payload
+type Error struct {
+	// Code machine-readable error code. Ex.: ErrorCodeDisconnectedChain
+	Code string
+
+	// Pointer [optional] json pointer, RFC 6901, of the invalid value. Ex.: /3/1 is the destination of the fourth flight
+	Pointer string
+
+	// Message human-readable description
+	Message string
+}
+
+// NewError returns an error with code and pointer
+func NewError(code, pointer, message string) Error {
+	return Error{Code: code, Pointer: pointer, Message: message}
+}
+
+// Error the text keeps the format of the previous versions of the api, with the pointer before the message
+func (e Error) Error() string {
+	if e.Pointer == "" {
+		return e.Message
+	}
+	return e.Pointer + ": " + e.Message
+}
@@ -1,6 +1,12 @@
 package types
 
-import "reflect"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"sort"
+	"strings"
+)
 
 const (
 	// source of flight
@@ -72,7 +78,42 @@ func (e *Flights) GetSubRoutes() (routes [][][]string) {
 	return
 }
 
+// WalkSubRoutes calls walk for each sub route, in the same order as GetSubRoutes(), without keeping all the sub
+// routes in memory. The walk stops when the function returns false.
+// The sub routes share the memory of the sorted list and must not be changed.
+func (e *Flights) WalkSubRoutes(walk func(route [][]string) bool) {
+	e.Sort()
+	for start := range *e {
+		for end := start + 1; end <= len(*e); end += 1 {
+			if !walk((*e)[start:end:end]) {
+				return
+			}
+		}
+	}
+}
+
+// CountSubRoutes returns the number of sub routes of a list of n flights, n(n+1)/2
+func CountSubRoutes(n int) int64 {
+	return int64(n) * int64(n+1) / 2
+}
+
+// Hash returns a canonical hash of the flight list, independent of the order of the flights.
+// Lists with the same flights in any order have the same hash.
+func (e Flights) Hash() string {
+	legs := make([]string, len(e))
+	for k, flight := range e {
+		// the unit separator can't be part of an airport code, so different lists can't be joined into the same text
+		legs[k] = strings.Join(flight, "\x1f")
+	}
+	sort.Strings(legs)
+
+	sum := sha256.Sum256([]byte(strings.Join(legs, "\x1e")))
+	return hex.EncodeToString(sum[:])
+}
+
 // Sort sort the flight connections list
+// The list must form a single chain, use Validate() before sorting data received from the user.
+// A closed loop starts at the smallest airport, so the same flights in any order are sorted the same way.
 func (e *Flights) Sort() {
 	sorted := make([][]string, 0)
 	sorted = append(sorted, (*e)[0])
@@ -82,8 +123,8 @@ func (e *Flights) Sort() {
 
 	for {
 
-		// prevents an infinite loop bug
-		security := 10
+		// prevents an infinite loop when no flight connects to the sorted chain
+		connected := false
 
 		for flightKey, flightData := range *e {
 
@@ -92,6 +133,7 @@ func (e *Flights) Sort() {
 				// em cima
 				sorted = append([][]string{flightData}, sorted...)
 				*e = append((*e)[:flightKey], (*e)[flightKey+1:]...)
+				connected = true
 				break
 			}
 
@@ -99,20 +141,35 @@ func (e *Flights) Sort() {
 			if flightData[kSrc] == sorted[len(sorted)-1][kDst] {
 				sorted = append(sorted, flightData)
 				*e = append((*e)[:flightKey], (*e)[flightKey+1:]...)
+				connected = true
 				break
 			}
 		}
 
 		if len(*e) == 0 {
-			*e = sorted
-			security = 20
+			*e = rotateLoop(sorted)
 			return
 		}
 
-		// security counter to prevent infinite loop bug
-		security -= 1
-		if security == 0 {
-			panic("houston! we have a infinite loop bug")
+		// the list is not a single chain, see Validate()
+		if !connected {
+			panic("houston! the flights don't form a single chain")
+		}
+	}
+}
+
+// rotateLoop returns the closed loop starting at the flight that leaves the smallest airport. Other chains are
+// returned as they are
+func rotateLoop(sorted [][]string) [][]string {
+	if sorted[0][kSrc] != sorted[len(sorted)-1][kDst] {
+		return sorted
+	}
+
+	start := 0
+	for k, flight := range sorted {
+		if flight[kSrc] < sorted[start][kSrc] {
+			start = k
 		}
 	}
+	return append(sorted[start:], sorted[:start]...)
 }
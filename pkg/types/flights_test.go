@@ -6,7 +6,6 @@ import (
 	"os"
 	"reflect"
 	"testing"
-	"time"
 )
 
 type flightsList struct {
@@ -26,8 +25,35 @@ func TestFlights_GetSubRoutes(t *testing.T) {
 	}
 }
 
+func TestFlights_WalkSubRoutes(t *testing.T) {
+	var flights = Flights{{"IND", "EWR"}, {"SFO", "ATL"}, {"GSO", "IND"}, {"ATL", "GSO"}}
+	var walked = Flights{{"IND", "EWR"}, {"SFO", "ATL"}, {"GSO", "IND"}, {"ATL", "GSO"}}
+
+	routes := make([][][]string, 0)
+	walked.WalkSubRoutes(func(route [][]string) bool {
+		routes = append(routes, route)
+		return true
+	})
+
+	if !reflect.DeepEqual(routes, flights.GetSubRoutes()) || int64(len(routes)) != CountSubRoutes(4) {
+		t.Log("the walk must return the same sub routes of GetSubRoutes()")
+		t.FailNow()
+	}
+
+	count := 0
+	walked.WalkSubRoutes(func(route [][]string) bool {
+		count += 1
+		return count < 3
+	})
+
+	if count != 3 {
+		t.Logf("the walk must stop when the function returns false. count: %v", count)
+		t.FailNow()
+	}
+}
+
 func TestFlightInList_Sort(t *testing.T) {
-	rand.Seed(time.Now().UnixNano())
+	random := rand.New(rand.NewSource(testSeed(t)))
 
 	// opens the flight list used in the test
 	data, err := os.ReadFile("./flights_test.json")
@@ -53,7 +79,7 @@ func TestFlightInList_Sort(t *testing.T) {
 		}
 
 		// sort the list in random order, because the list can be received out of order
-		rand.Shuffle(len(flights), func(i, j int) {
+		random.Shuffle(len(flights), func(i, j int) {
 			flights[i], flights[j] = flights[j], flights[i]
 		})
 
@@ -75,7 +101,8 @@ func TestFlightInList_Sort(t *testing.T) {
 			continue
 		}
 
-		for i := 0; i != len(flights)-2; i += 1 {
+		// every pair of consecutive flights, including the last one
+		for i := 0; i != len(flights)-1; i += 1 {
 			if flights[i][kDst] != flights[i+1][kSrc] {
 				t.Logf("sort algorithm error")
 				t.FailNow()
@@ -85,3 +112,35 @@ func TestFlightInList_Sort(t *testing.T) {
 	}
 
 }
+
+func TestFlights_SortLoop(t *testing.T) {
+	expected := Flights{{"A", "B"}, {"B", "C"}, {"C", "A"}}
+	for _, flights := range []Flights{
+		{{"A", "B"}, {"B", "C"}, {"C", "A"}},
+		{{"B", "C"}, {"C", "A"}, {"A", "B"}},
+		{{"C", "A"}, {"A", "B"}, {"B", "C"}},
+		{{"C", "A"}, {"B", "C"}, {"A", "B"}},
+	} {
+		flights.Sort()
+		if !reflect.DeepEqual(flights, expected) {
+			t.Logf("a closed loop must start at the smallest airport: %v", flights)
+			t.FailNow()
+		}
+	}
+}
+
+func TestFlights_Hash(t *testing.T) {
+	flights := Flights{{"IND", "EWR"}, {"SFO", "ATL"}, {"GSO", "IND"}, {"ATL", "GSO"}}
+	shuffled := Flights{{"ATL", "GSO"}, {"IND", "EWR"}, {"GSO", "IND"}, {"SFO", "ATL"}}
+	other := Flights{{"IND", "EWR"}, {"SFO", "ATL"}, {"GSO", "IND"}, {"ATL", "GSX"}}
+
+	if flights.Hash() != shuffled.Hash() {
+		t.Log("the hash must be independent of the order of the flights")
+		t.FailNow()
+	}
+
+	if flights.Hash() == other.Hash() {
+		t.Log("different flights must have different hashes")
+		t.FailNow()
+	}
+}
@@ -0,0 +1,57 @@
+package types
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+// maxFuzzFlights limits the size of the fuzzed lists, GetSubRoutes() is quadratic on the number of flights
+const maxFuzzFlights = 64
+
+// FuzzFlights decodes the json payload as the server does, then validates, sorts and generates the sub routes.
+// Valid lists must be sorted into a single chain with n(n+1)/2 sub routes, and no input can panic.
+// The hand-written seeds of the edge cases, seed_*, and the crashers found by the fuzzer are kept in
+// testdata/fuzz/FuzzFlights and run with go test
+func FuzzFlights(f *testing.F) {
+	f.Add([]byte(`[["IND","EWR"],["SFO","ATL"],["GSO","IND"],["ATL","GSO"]]`))
+	f.Add([]byte(`[["JFK","GRU"],["GRU","JFK"]]`))
+	f.Add([]byte(`[["IND","EWR"],["EWR","SFO"],["GRU","JFK"]]`))
+	f.Add([]byte(`[["IND","EWR"],["EWR","SFO"],["EWR","ATL"]]`))
+
+	// the chains of the fixture of TestFlightInList_Sort
+	if data, err := os.ReadFile("./flights_test.json"); err == nil {
+		var list []flightsList
+		_ = json.Unmarshal(data, &list)
+		for _, item := range list {
+			flights := make(Flights, 0)
+			for i := 0; i < len(item.Airports)-1; i += 1 {
+				flights = append(flights, []string{item.Airports[i], item.Airports[i+1]})
+			}
+			seed, _ := json.Marshal(flights)
+			f.Add(seed)
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		flights := Flights{}
+		if err := json.Unmarshal(data, &flights); err != nil {
+			return
+		}
+
+		// invalid lists can't be sorted, Validate() protects Sort() from them
+		if err := flights.Validate(); err != nil || len(flights) > maxFuzzFlights {
+			return
+		}
+
+		original := append(Flights{}, flights...)
+		flights.Sort()
+		checkChain(t, original, flights)
+
+		routes := flights.GetSubRoutes()
+		if int64(len(routes)) != CountSubRoutes(len(flights)) {
+			t.Logf("%v flights must have n(n+1)/2 sub routes, found %v", len(flights), len(routes))
+			t.FailNow()
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package types
+
+import "net/http"
+
+// ContentTypeProblem media type of the problem details, RFC 7807
+const ContentTypeProblem = "application/problem+json"
+
+// Problem problem details json output pattern, RFC 7807.
+// It is an alternative to RestFul for clients that send "Accept: application/problem+json"
+type Problem struct {
+	// Type uri that identifies the problem. Ex.: urn:flights:problem:disconnected_chain
+	Type string `json:"type"`
+
+	// Title short summary of the problem
+	Title string `json:"title"`
+
+	// Status http status code
+	Status int `json:"status"`
+
+	// Detail explanation of this occurrence of the problem
+	Detail string `json:"detail"`
+
+	// Instance [optional] uri of the request
+	Instance string `json:"instance,omitempty"`
+
+	// Code machine-readable code of the first error
+	Code string `json:"code"`
+
+	// Errors all errors, each one with code and json pointer
+	Errors []ErrorDetail `json:"errors"`
+}
+
+// ProblemTypePrefix prefix of Problem.Type, followed by the error code
+const ProblemTypePrefix = "urn:flights:problem:"
+
+// ToProblem converts the errors of the RestFul into problem details
+func (e RestFul) ToProblem(status int, instance string) (problem Problem) {
+	problem = Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Instance: instance,
+		Errors:   e.Meta.Details,
+	}
+
+	if problem.Errors == nil {
+		problem.Errors = make([]ErrorDetail, 0)
+	}
+
+	if len(e.Meta.Details) != 0 {
+		problem.Code = e.Meta.Details[0].Code
+		problem.Type = ProblemTypePrefix + problem.Code
+	}
+
+	if len(e.Meta.Error) != 0 {
+		problem.Detail = e.Meta.Error[0]
+	}
+
+	return
+}
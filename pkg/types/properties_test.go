@@ -0,0 +1,164 @@
+package types
+
+import (
+	"math/rand"
+	"os"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// testSeed returns the seed of the random tests, from the TEST_SEED env var or from the clock.
+// The seed is logged, so a failure can be reproduced with TEST_SEED=<seed> go test ./pkg/types
+func testSeed(t *testing.T) int64 {
+	seed := time.Now().UnixNano()
+	if value := os.Getenv("TEST_SEED"); value != "" {
+		var err error
+		if seed, err = strconv.ParseInt(value, 10, 64); err != nil {
+			t.Logf("strconv.ParseInt(TEST_SEED).error: %v", err)
+			t.FailNow()
+		}
+	}
+
+	t.Logf("seed: %v", seed)
+	return seed
+}
+
+// randomChain returns a chain of n flights between distinct airports, closed in a loop when loop is true
+func randomChain(random *rand.Rand, n int, loop bool) Flights {
+	airports := random.Perm(n + 1)
+	if loop {
+		airports[n] = airports[0]
+	}
+
+	flights := make(Flights, n)
+	for k := range flights {
+		flights[k] = []string{"A" + strconv.Itoa(airports[k]), "A" + strconv.Itoa(airports[k+1])}
+	}
+	return flights
+}
+
+// shuffled returns a copy of the flights in random order
+func shuffled(random *rand.Rand, flights Flights) Flights {
+	list := make(Flights, len(flights))
+	for k, v := range random.Perm(len(flights)) {
+		list[v] = flights[k]
+	}
+	return list
+}
+
+// reversed returns the flights in the opposite direction, from the last to the first
+func reversed(flights Flights) Flights {
+	list := make(Flights, len(flights))
+	for k, flight := range flights {
+		list[len(flights)-1-k] = []string{flight[kDst], flight[kSrc]}
+	}
+	return list
+}
+
+// checkChain fails when the sorted flights aren't a single chain with the same flights of the original list
+func checkChain(t *testing.T, original, sorted Flights) {
+	if len(sorted) != len(original) || sorted.Hash() != original.Hash() {
+		t.Logf("the sorted list must have the same flights of the original list.\noriginal: %v\nsorted: %v", original, sorted)
+		t.FailNow()
+	}
+
+	for k := 0; k < len(sorted)-1; k += 1 {
+		if sorted[k][kDst] != sorted[k+1][kSrc] {
+			t.Logf("the flight %v doesn't connect to the next one: %v", k, sorted)
+			t.FailNow()
+		}
+	}
+}
+
+func TestFlights_SortProperties(t *testing.T) {
+	random := rand.New(rand.NewSource(testSeed(t)))
+
+	for i := 0; i < 500; i += 1 {
+		n := 1 + random.Intn(40)
+		loop := n > 1 && random.Intn(4) == 0
+		chain := randomChain(random, n, loop)
+
+		flights := shuffled(random, chain)
+		if err := flights.Validate(); err != nil {
+			t.Logf("%v: a generated chain must be valid: %v", flights, err)
+			t.FailNow()
+		}
+
+		original := append(Flights{}, flights...)
+		flights.Sort()
+		checkChain(t, original, flights)
+
+		// a line has a single order, a loop can start at any airport
+		if !loop && !reflect.DeepEqual(flights, chain) {
+			t.Logf("the line must be sorted from the airport without arrival.\nexpected: %v\nsorted: %v", chain, flights)
+			t.FailNow()
+		}
+		if loop && flights[0][kSrc] != flights[len(flights)-1][kDst] {
+			t.Logf("the loop must end at the first airport: %v", flights)
+			t.FailNow()
+		}
+
+		// sorting a sorted line doesn't change the order, a sorted loop can be rotated
+		again := append(Flights{}, flights...)
+		again.Sort()
+		checkChain(t, original, again)
+		if !loop && !reflect.DeepEqual(again, flights) {
+			t.Logf("the sort must be idempotent.\nfirst: %v\nsecond: %v", flights, again)
+			t.FailNow()
+		}
+
+		// the flights in the opposite direction are sorted in the opposite order
+		if !loop {
+			back := shuffled(random, reversed(chain))
+			back.Sort()
+			if !reflect.DeepEqual(back, reversed(chain)) {
+				t.Logf("the reversed flights must be sorted in the reversed order.\nexpected: %v\nsorted: %v", reversed(chain), back)
+				t.FailNow()
+			}
+		}
+	}
+}
+
+func TestFlights_SubRoutesProperties(t *testing.T) {
+	random := rand.New(rand.NewSource(testSeed(t)))
+
+	for i := 0; i < 100; i += 1 {
+		n := 1 + random.Intn(20)
+		flights := shuffled(random, randomChain(random, n, n > 1 && random.Intn(4) == 0))
+		walked := append(Flights{}, flights...)
+
+		routes := flights.GetSubRoutes()
+		if int64(len(routes)) != CountSubRoutes(n) || CountSubRoutes(n) != int64(n*(n+1)/2) {
+			t.Logf("%v flights must have n(n+1)/2 sub routes, found %v", n, len(routes))
+			t.FailNow()
+		}
+
+		// each sub route is a contiguous part of the sorted chain, in the order of the start and of the length
+		k := 0
+		for start := 0; start < n; start += 1 {
+			for end := start + 1; end <= n; end += 1 {
+				if !reflect.DeepEqual(routes[k], [][]string(flights[start:end])) {
+					t.Logf("the sub route %v must be the flights %v to %v of %v, found %v", k, start, end, flights, routes[k])
+					t.FailNow()
+				}
+				k += 1
+			}
+		}
+
+		count := 0
+		walked.WalkSubRoutes(func(route [][]string) bool {
+			if !reflect.DeepEqual(route, routes[count]) {
+				t.Logf("the walk must return the sub routes of GetSubRoutes(). %v: %v, expected %v", count, route, routes[count])
+				t.FailNow()
+			}
+			count += 1
+			return true
+		})
+		if count != len(routes) {
+			t.Logf("the walk returned %v sub routes, expected %v", count, len(routes))
+			t.FailNow()
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package types
 
+import "errors"
+
 // RestFul json data output pattern
 type RestFul struct {
 	Meta Meta `json:"meta"`
@@ -10,6 +12,16 @@ type RestFul struct {
 type Meta struct {
 	Success bool     `json:"success"`
 	Error   []string `json:"error"`
+
+	// Details machine-readable version of Error, in the same order
+	Details []ErrorDetail `json:"details,omitempty"`
+}
+
+// ErrorDetail machine-readable error
+type ErrorDetail struct {
+	Code    string `json:"code"`
+	Pointer string `json:"pointer,omitempty"`
+	Message string `json:"message"`
 }
 
 // AddError prepare the error response
@@ -22,6 +34,16 @@ func (e *RestFul) AddError(err error) {
 
 	e.Meta.Error = append(e.Meta.Error, err.Error())
 
+	// errors without code are reported as internal errors
+	detail := ErrorDetail{Code: ErrorCodeInternal, Message: err.Error()}
+
+	var typed Error
+	if errors.As(err, &typed) {
+		detail = ErrorDetail{Code: typed.Code, Pointer: typed.Pointer, Message: typed.Message}
+	}
+
+	e.Meta.Details = append(e.Meta.Details, detail)
+
 	// returning a blank array makes it easy for iOS
 	e.Data = []int{}
 }
@@ -0,0 +1,73 @@
+package types
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Validate checks if the flights form a single chain, the condition for Sort() and GetSubRoutes().
+// Each airport can have one departure and one arrival, so the flights form a line or a closed loop.
+// The returned error is an Error with the code and the json pointer of the first invalid flight.
+func (e Flights) Validate() error {
+	if len(e) == 0 {
+		return NewError(ErrorCodeEmptyItinerary, "", "the flight list is empty")
+	}
+
+	for k, flight := range e {
+		pointer := "/" + strconv.Itoa(k)
+
+		if len(flight) != 2 {
+			return NewError(ErrorCodeInvalidFlight, pointer, fmt.Sprintf("the flight must be in the [src, dst] format, found %v airports", len(flight)))
+		}
+
+		if flight[kSrc] == "" {
+			return NewError(ErrorCodeInvalidFlight, pointer+"/0", "the source airport is empty")
+		}
+
+		if flight[kDst] == "" {
+			return NewError(ErrorCodeInvalidFlight, pointer+"/1", "the destination airport is empty")
+		}
+
+		if flight[kSrc] == flight[kDst] {
+			return NewError(ErrorCodeInvalidFlight, pointer, fmt.Sprintf("the flight leaves and arrives at %v", flight[kSrc]))
+		}
+	}
+
+	// index of the flight that leaves and arrives at each airport
+	departures := make(map[string]int, len(e))
+	arrivals := make(map[string]int, len(e))
+	for k, flight := range e {
+		if first, found := departures[flight[kSrc]]; found {
+			return NewError(ErrorCodeFork, "/"+strconv.Itoa(k), fmt.Sprintf("the airport %v has more than one departure, see flight %v", flight[kSrc], first))
+		}
+		departures[flight[kSrc]] = k
+
+		if first, found := arrivals[flight[kDst]]; found {
+			return NewError(ErrorCodeFork, "/"+strconv.Itoa(k), fmt.Sprintf("the airport %v has more than one arrival, see flight %v", flight[kDst], first))
+		}
+		arrivals[flight[kDst]] = k
+	}
+
+	// the chain starts at the airport without arrival, or anywhere in a closed loop
+	start := 0
+	for k, flight := range e {
+		if _, found := arrivals[flight[kSrc]]; !found {
+			start = k
+			break
+		}
+	}
+
+	// follows the chain from the start and looks for flights out of it
+	visited := make([]bool, len(e))
+	for k, found := start, true; found && !visited[k]; k, found = departures[e[k][kDst]] {
+		visited[k] = true
+	}
+
+	for k := range e {
+		if !visited[k] {
+			return NewError(ErrorCodeDisconnectedChain, "/"+strconv.Itoa(k), fmt.Sprintf("the flight %v-%v is not connected to the chain that starts at %v", e[k][kSrc], e[k][kDst], e[start][kSrc]))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package types
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFlights_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		flights Flights
+		code    string
+		pointer string
+	}{
+		{name: "chain", flights: Flights{{"IND", "EWR"}, {"SFO", "ATL"}, {"GSO", "IND"}, {"ATL", "GSO"}}},
+		{name: "single flight", flights: Flights{{"GRU", "JFK"}}},
+		{name: "round trip", flights: Flights{{"JFK", "GRU"}, {"GRU", "JFK"}}},
+		{name: "empty", flights: Flights{}, code: ErrorCodeEmptyItinerary},
+		{name: "three airports", flights: Flights{{"IND", "EWR"}, {"SFO", "ATL", "GSO"}}, code: ErrorCodeInvalidFlight, pointer: "/1"},
+		{name: "empty destination", flights: Flights{{"IND", "EWR"}, {"EWR", ""}}, code: ErrorCodeInvalidFlight, pointer: "/1/1"},
+		{name: "same airport", flights: Flights{{"IND", "IND"}}, code: ErrorCodeInvalidFlight, pointer: "/0"},
+		{name: "fork", flights: Flights{{"IND", "EWR"}, {"EWR", "SFO"}, {"EWR", "ATL"}}, code: ErrorCodeFork, pointer: "/2"},
+		{name: "merge", flights: Flights{{"IND", "EWR"}, {"SFO", "EWR"}}, code: ErrorCodeFork, pointer: "/1"},
+		{name: "disconnected", flights: Flights{{"IND", "EWR"}, {"EWR", "SFO"}, {"GRU", "JFK"}, {"ATL", "GSO"}}, code: ErrorCodeDisconnectedChain, pointer: "/2"},
+		{name: "line and loop", flights: Flights{{"IND", "EWR"}, {"GRU", "JFK"}, {"JFK", "GRU"}}, code: ErrorCodeDisconnectedChain, pointer: "/1"},
+	}
+
+	for _, test := range tests {
+		err := test.flights.Validate()
+		if test.code == "" {
+			if err != nil {
+				t.Logf("%v: unexpected error: %v", test.name, err)
+				t.FailNow()
+			}
+
+			test.flights.Sort()
+			continue
+		}
+
+		var typed Error
+		if !errors.As(err, &typed) || typed.Code != test.code || typed.Pointer != test.pointer {
+			t.Logf("%v: expected code %v and pointer %q, found: %#v", test.name, test.code, test.pointer, err)
+			t.FailNow()
+		}
+	}
+}
+
+func TestRestFul_AddError(t *testing.T) {
+	var rest RestFul
+	rest.AddError(NewError(ErrorCodeDisconnectedChain, "/3", "not connected"))
+	rest.AddError(errors.New("plain error"))
+
+	// the string list of the previous versions of the api is kept
+	if len(rest.Meta.Error) != 2 || rest.Meta.Error[0] != "/3: not connected" || rest.Meta.Error[1] != "plain error" {
+		t.Logf("meta.error error: %v", rest.Meta.Error)
+		t.FailNow()
+	}
+
+	if rest.Meta.Details[0].Code != ErrorCodeDisconnectedChain || rest.Meta.Details[0].Pointer != "/3" || rest.Meta.Details[1].Code != ErrorCodeInternal {
+		t.Logf("meta.details error: %+v", rest.Meta.Details)
+		t.FailNow()
+	}
+
+	problem := rest.ToProblem(422, "/calculate")
+	if problem.Type != ProblemTypePrefix+ErrorCodeDisconnectedChain || problem.Status != 422 || len(problem.Errors) != 2 {
+		t.Logf("problem error: %+v", problem)
+		t.FailNow()
+	}
+}